@@ -11,9 +11,12 @@ import (
 	"github.com/Saaghh/wallet/internal/currconv"
 	"github.com/Saaghh/wallet/internal/jwtgenerator"
 	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/outbox"
 	"github.com/Saaghh/wallet/internal/prometrics"
 	"github.com/Saaghh/wallet/internal/service"
 	"github.com/Saaghh/wallet/internal/store"
+	"github.com/Saaghh/wallet/internal/tracing"
+	"github.com/Saaghh/wallet/internal/webhook"
 	migrate "github.com/rubenv/sql-migrate"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -32,7 +35,28 @@ func main() {
 	//nolint: errcheck
 	defer zap.L().Sync()
 
-	pgStore, err := store.New(ctx, cfg)
+	shutdownTracing, err := tracing.Init(ctx, tracing.Config{
+		ServiceName:  "wallet",
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("tracing.Init")
+	}
+
+	defer func() {
+		// the signal context is done by now, spans get a fresh one to flush
+		//nolint: contextcheck
+		if err := shutdownTracing(context.Background()); err != nil {
+			zap.L().With(zap.Error(err)).Warn("shutdownTracing")
+		}
+	}()
+
+	metrics := prometrics.New()
+
+	pgStore, err := store.New(ctx, cfg, metrics)
 	if err != nil {
 		zap.L().With(zap.Error(err)).Panic("pgStore.New")
 	}
@@ -43,27 +67,120 @@ func main() {
 
 	zap.L().Info("successful migration")
 
-	metrics := prometrics.New()
-	converter := currconv.New(cfg.XRBindAddr, metrics)
-	serviceLayer := service.New(pgStore, converter)
+	providers, err := currconv.NewProviders(
+		cfg.XRURLs,
+		currconv.Config{
+			AuthHeader: cfg.XRAuthHeader,
+			AuthToken:  cfg.XRAuthToken,
+			TLS: currconv.TLSConfig{
+				CAFile:             cfg.XRTLSCAFile,
+				CertFile:           cfg.XRTLSCertFile,
+				KeyFile:            cfg.XRTLSKeyFile,
+				InsecureSkipVerify: cfg.XRTLSSkipVerify,
+			},
+			Timeout:          cfg.XRTimeout,
+			RetryAttempts:    cfg.XRRetryAttempts,
+			RetryBaseDelay:   cfg.XRRetryBaseDelay,
+			RetryMaxDelay:    cfg.XRRetryMaxDelay,
+			BreakerThreshold: cfg.XRBreakerThreshold,
+			BreakerCooldown:  cfg.XRBreakerCooldown,
+		},
+		metrics)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("currconv.NewProviders")
+	}
+
+	converter := currconv.NewCache(
+		currconv.NewFailover(providers, cfg.XRMaxDeviation, metrics),
+		currconv.CacheConfig{TTL: cfg.XRCacheTTL, MaxStaleness: cfg.XRCacheMaxStaleness},
+		metrics)
+	serviceLayer := service.New(pgStore, converter, metrics)
 	jwtGenerator := jwtgenerator.NewJWTGenerator()
 	server := apiserver.New(
-		apiserver.Config{BindAddress: cfg.BindAddress},
+		apiserver.Config{
+			BindAddress:     cfg.BindAddress,
+			ValidateOpenAPI: cfg.OpenAPIValidation,
+			MaxBodyBytes:    cfg.MaxBodyBytes,
+			DrainDelay:      cfg.ShutdownDrainDelay,
+		},
 		serviceLayer,
 		jwtGenerator.GetPublicKey(),
 		metrics)
+	grpcServer := apiserver.NewGRPC(
+		apiserver.GRPCConfig{BindAddress: cfg.GRPCBindAddress},
+		serviceLayer,
+		jwtGenerator.GetPublicKey())
+
+	publisher, err := outbox.NewPublisher(cfg.OutboxPublisher, cfg.OutboxFilePath, cfg.OutboxHTTPURL)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("outbox.NewPublisher")
+	}
+
+	relay := outbox.NewRelay(
+		outbox.Config{PollInterval: cfg.OutboxPollInterval, BatchSize: cfg.OutboxBatchSize},
+		pgStore,
+		publisher)
+
+	dispatcher := webhook.NewDispatcher(
+		webhook.Config{
+			PollInterval: cfg.WebhookPollInterval,
+			BatchSize:    cfg.WebhookBatchSize,
+			Timeout:      cfg.WebhookTimeout,
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			BaseBackoff:  cfg.WebhookBaseBackoff,
+			MaxBackoff:   cfg.WebhookMaxBackoff,
+
+			AllowPrivateNetworks: cfg.WebhookAllowPrivate,
+		},
+		pgStore)
 
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
-		err = server.Run(ctx)
+		if err := server.Run(ctx); err != nil {
+			return fmt.Errorf("server.Run(ctx): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		if err := grpcServer.Run(ctx); err != nil {
+			return fmt.Errorf("grpcServer.Run(ctx): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		if err := serviceLayer.ArchiverRun(ctx); err != nil {
+			return fmt.Errorf("serviceLayer.ArchiverRun(ctx): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		if err := serviceLayer.StreamRun(ctx); err != nil {
+			return fmt.Errorf("serviceLayer.StreamRun(ctx): %w", err)
+		}
+
+		return nil
+	})
+
+	eg.Go(func() error {
+		if err := relay.Run(ctx); err != nil {
+			return fmt.Errorf("relay.Run(ctx): %w", err)
+		}
 
-		return fmt.Errorf("server.Run(ctx): %w", err)
+		return nil
 	})
 
 	eg.Go(func() error {
-		err = serviceLayer.ArchiverRun(ctx)
+		if err := dispatcher.Run(ctx); err != nil {
+			return fmt.Errorf("dispatcher.Run(ctx): %w", err)
+		}
 
-		return fmt.Errorf("serviceLayer.ArchiverRun(ctx): %w", err)
+		return nil
 	})
 
 	if err = eg.Wait(); err != nil {
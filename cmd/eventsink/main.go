@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/Saaghh/wallet/internal/config"
+	"github.com/Saaghh/wallet/internal/eventsink"
+	"github.com/Saaghh/wallet/internal/logger"
+	"go.uber.org/zap"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer cancel()
+
+	cfg := config.New()
+
+	logger.InitLogger(logger.Config{Level: cfg.LogLevel})
+
+	// no error handling for now
+	// check https://github.com/uber-go/zap/issues/991
+	//nolint: errcheck
+	defer zap.L().Sync()
+
+	s := eventsink.New(cfg.EventSinkBindAddr)
+
+	if err := s.Run(ctx); err != nil {
+		zap.L().With(zap.Error(err)).Panic("error running event sink")
+	}
+}
@@ -7,7 +7,11 @@ import (
 
 	"github.com/Saaghh/wallet/internal/config"
 	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/prometrics"
+	"github.com/Saaghh/wallet/internal/tracing"
+	"github.com/Saaghh/wallet/internal/xrserver/feed"
 	"github.com/Saaghh/wallet/internal/xrserver/server"
+	"github.com/Saaghh/wallet/internal/xrserver/storage"
 	"go.uber.org/zap"
 )
 
@@ -24,7 +28,56 @@ func main() {
 	//nolint: errcheck
 	defer zap.L().Sync()
 
-	s := server.New(cfg.XRBindAddr)
+	shutdownTracing, err := tracing.Init(ctx, tracing.Config{
+		ServiceName:  "xrserver",
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("tracing.Init")
+	}
+
+	defer func() {
+		// the signal context is done by now, spans get a fresh one to flush
+		//nolint: contextcheck
+		if err := shutdownTracing(context.Background()); err != nil {
+			zap.L().With(zap.Error(err)).Warn("shutdownTracing")
+		}
+	}()
+
+	rateStorage, err := storage.New(ctx, cfg.XRStorage, cfg.XRStorageFilePath, cfg.XRStorageDSN)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Panic("storage.New")
+	}
+
+	defer rateStorage.Close()
+
+	providers := make([]feed.Provider, 0, len(cfg.XRFeedSources))
+
+	for _, source := range cfg.XRFeedSources {
+		provider, err := feed.New(source, cfg.XRFeedTimeout)
+		if err != nil {
+			zap.L().With(zap.Error(err)).Panic("feed.New")
+		}
+
+		providers = append(providers, provider)
+	}
+
+	s := server.New(
+		server.Config{
+			BindAddr:     cfg.XRBindAddr,
+			AdminToken:   cfg.XRAdminToken,
+			Spread:       cfg.XRSpread,
+			Spreads:      cfg.XRSpreads,
+			FeedInterval: cfg.XRFeedInterval,
+			FeedMaxAge:   cfg.XRFeedMaxAge,
+			FeedAnchor:   cfg.XRFeedAnchor,
+		},
+		rateStorage,
+		providers,
+		prometrics.NewXRServer())
 
 	if err := s.Run(ctx); err != nil {
 		zap.L().With(zap.Error(err)).Panic("error running server")
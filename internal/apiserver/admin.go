@@ -0,0 +1,227 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func (s *APIServer) searchUsers(w http.ResponseWriter, r *http.Request) {
+	params, err := model.ValuesToGetParams(r.URL.Query())
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("searchUsers/model.ValuesToGetParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
+
+		return
+	}
+
+	users, err := s.service.SearchUsers(r.Context(), r.URL.Query().Get("email"), *params)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("searchUsers/s.service.SearchUsers(...): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, users)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/admin/users", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) getUserWallets(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	params, err := model.ValuesToGetParams(r.URL.Query())
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getUserWallets/model.ValuesToGetParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
+
+		return
+	}
+
+	wallets, err := s.service.GetUserWallets(r.Context(), id, *params)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getUserWallets/s.service.GetUserWallets(r.Context(), id, *params): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, wallets)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/admin/users/{id}/wallets", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) getUserTransactions(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	params, err := model.ValuesToGetParams(r.URL.Query())
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getUserTransactions/model.ValuesToGetParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
+
+		return
+	}
+
+	transactions, err := s.service.GetUserTransactions(r.Context(), id, *params)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getUserTransactions/s.service.GetUserTransactions(r.Context(), id, *params): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, transactions)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/admin/users/{id}/transactions", zap.String("client", r.RemoteAddr))
+}
+
+type walletAdminAction func(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error)
+
+func (s *APIServer) freezeWallet(w http.ResponseWriter, r *http.Request) {
+	s.handleWalletAdminAction(w, r, s.service.FreezeWallet)
+}
+
+func (s *APIServer) unfreezeWallet(w http.ResponseWriter, r *http.Request) {
+	s.handleWalletAdminAction(w, r, s.service.UnfreezeWallet)
+}
+
+func (s *APIServer) enableWallet(w http.ResponseWriter, r *http.Request) {
+	s.handleWalletAdminAction(w, r, s.service.EnableWallet)
+}
+
+func (s *APIServer) handleWalletAdminAction(w http.ResponseWriter, r *http.Request, action walletAdminAction) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	var request model.AdminActionRequest
+
+	if err := s.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	wallet, err := action(r.Context(), id, request.Reason)
+
+	writeWalletStatusResponse(w, r, wallet, err)
+}
+
+func (s *APIServer) setWalletStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	var request model.WalletStatusRequest
+
+	if err := s.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	wallet, err := s.service.SetWalletStatus(r.Context(), id, request)
+
+	writeWalletStatusResponse(w, r, wallet, err)
+}
+
+func writeWalletStatusResponse(w http.ResponseWriter, r *http.Request, wallet *model.Wallet, err error) {
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("writeWalletStatusResponse/wallet status change: %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, wallet)
+
+	logger.FromContext(r.Context()).Debug("successful PUT:"+r.URL.Path, zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) adjustBalance(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	var adjustment model.BalanceAdjustment
+
+	if err := s.decodeJSON(w, r, &adjustment); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	adjustment.WalletID = id
+
+	if err := adjustment.Validate(); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	transactionID, err := s.service.AdjustBalance(r.Context(), adjustment)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("adjustBalance/s.service.AdjustBalance(r.Context(), adjustment): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, TransferResponse{TransactionID: *transactionID})
+
+	logger.FromContext(r.Context()).Debug("successful PUT:/admin/wallets/{id}/adjust", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) getSpreadIncome(w http.ResponseWriter, r *http.Request) {
+	params, err := model.ValuesToReportParams(r.URL.Query())
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getSpreadIncome/model.ValuesToReportParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
+
+		return
+	}
+
+	reports, err := s.service.GetSpreadIncome(r.Context(), *params)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getSpreadIncome/s.service.GetSpreadIncome(r.Context(), *params): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, reports)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/admin/reports/spread-income", zap.String("client", r.RemoteAddr))
+}
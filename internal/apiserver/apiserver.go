@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/Saaghh/wallet/internal/tracing"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
@@ -20,25 +23,48 @@ type APIServer struct {
 	service service
 	key     *rsa.PublicKey
 	metrics metrics
+
+	// shutdown is closed when the server starts shutting down, so that
+	// long-lived streams let go of their connections
+	shutdown chan struct{}
+
+	// stopping turns readiness off before the listener closes
+	stopping atomic.Bool
 }
 
 type metrics interface {
-	TrackHTTPRequest(start time.Time, r *http.Request)
+	TrackHTTPRequestStart()
+	TrackHTTPRequest(start time.Time, r *http.Request, status, size int)
 }
 
 type Config struct {
 	BindAddress string
+
+	// ValidateOpenAPI turns on OpenAPIValidation for /api/v1
+	ValidateOpenAPI bool
+
+	// MaxBodyBytes limits request bodies, 1 MiB when zero
+	MaxBodyBytes int64
+
+	// DrainDelay keeps serving with readiness off before shutting down, so
+	// that load balancers probing slower than that stop sending requests
+	DrainDelay time.Duration
 }
 
 func New(cfg Config, service service, key *rsa.PublicKey, metrics metrics) *APIServer {
 	router := chi.NewRouter()
 
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
 	return &APIServer{
-		cfg:     cfg,
-		service: service,
-		router:  router,
-		key:     key,
-		metrics: metrics,
+		cfg:      cfg,
+		service:  service,
+		router:   router,
+		key:      key,
+		metrics:  metrics,
+		shutdown: make(chan struct{}),
 		server: &http.Server{
 			Addr:              cfg.BindAddress,
 			ReadHeaderTimeout: 5 * time.Second,
@@ -51,15 +77,25 @@ func (s *APIServer) Run(ctx context.Context) error {
 	zap.L().Debug("starting api server")
 	defer zap.L().Info("server stopped")
 
-	s.configRouter()
+	if err := s.configRouter(ctx); err != nil {
+		return fmt.Errorf("s.configRouter(ctx): %w", err)
+	}
 
 	zap.L().Debug("configured router")
 
+	s.server.RegisterOnShutdown(func() { close(s.shutdown) })
+
 	go func() {
 		<-ctx.Done()
 
 		zap.L().Debug("closing server")
 
+		s.stopping.Store(true)
+
+		zap.L().Debug("draining", zap.Duration("delay", s.cfg.DrainDelay))
+
+		time.Sleep(s.cfg.DrainDelay)
+
 		gfCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
@@ -82,16 +118,30 @@ func (s *APIServer) Run(ctx context.Context) error {
 	return nil
 }
 
-func (s *APIServer) configRouter() {
+func (s *APIServer) configRouter(ctx context.Context) error {
 	zap.L().Debug("configuring router")
 
+	_, openAPIRouter, err := LoadOpenAPI(ctx)
+	if err != nil {
+		return fmt.Errorf("LoadOpenAPI(ctx): %w", err)
+	}
+
 	s.router.Route("/api", func(r chi.Router) {
+		r.Use(middleware.RequestID)
+		r.Use(tracing.Middleware)
+		r.Use(s.RequestInfo)
 		r.Use(s.Metrics)
-		r.Use(s.JWTAuth)
 
-		r.Route("/v1", func(r chi.Router) {
+		r.Get("/openapi.json", s.getOpenAPI)
+
+		r.With(s.JWTAuth).Route("/v1", func(r chi.Router) {
+			if s.cfg.ValidateOpenAPI {
+				r.Use(s.OpenAPIValidation(openAPIRouter))
+			}
+
 			r.Post("/wallets", s.createWallet)
 			r.Get("/wallets", s.getWallets)
+			r.Get("/wallets/stream", s.streamWalletEvents)
 			r.Get("/wallets/{id}", s.getWalletByID)
 			r.Delete("/wallets/{id}", s.deleteWallet)
 			r.Patch("/wallets/{id}", s.updateWallet)
@@ -101,8 +151,41 @@ func (s *APIServer) configRouter() {
 			r.Put("/wallets/withdraw", s.withdraw)
 
 			r.Get("/wallets/transactions", s.getTransactions)
+
+			r.Get("/audit", s.getAuditLog)
+
+			r.Get("/currencies", s.getCurrencies)
+			r.Get("/currencies/{code}", s.getCurrency)
+
+			r.Post("/webhooks", s.createWebhook)
+			r.Get("/webhooks", s.getWebhooks)
+			r.Delete("/webhooks/{id}", s.deleteWebhook)
+			r.Get("/webhooks/{id}/deliveries", s.getWebhookDeliveries)
+			r.Put("/webhooks/deliveries/{id}/retry", s.retryWebhookDelivery)
+
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(s.AdminOnly)
+
+				r.Get("/users", s.searchUsers)
+				r.Get("/users/{id}/wallets", s.getUserWallets)
+				r.Get("/users/{id}/transactions", s.getUserTransactions)
+
+				r.Put("/wallets/{id}/freeze", s.freezeWallet)
+				r.Put("/wallets/{id}/unfreeze", s.unfreezeWallet)
+				r.Put("/wallets/{id}/enable", s.enableWallet)
+				r.Put("/wallets/{id}/status", s.setWalletStatus)
+				r.Put("/wallets/{id}/adjust", s.adjustBalance)
+
+				r.Put("/currencies/{code}", s.upsertCurrency)
+
+				r.Get("/reports/spread-income", s.getSpreadIncome)
+			})
 		})
 	})
 
 	s.router.Get("/metrics", promhttp.Handler().ServeHTTP)
+	s.router.Get("/healthz", s.getHealth)
+	s.router.Get("/readyz", s.getReadiness)
+
+	return nil
 }
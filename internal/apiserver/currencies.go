@@ -0,0 +1,61 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+func (s *APIServer) getCurrencies(w http.ResponseWriter, r *http.Request) {
+	currencies, err := s.service.GetCurrencies(r.Context())
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getCurrencies/s.service.GetCurrencies(r.Context()): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, currencies)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/currencies", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) getCurrency(w http.ResponseWriter, r *http.Request) {
+	currency, err := s.service.GetCurrency(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getCurrency/s.service.GetCurrency(r.Context(), code): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, currency)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/currencies/{code}", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) upsertCurrency(w http.ResponseWriter, r *http.Request) {
+	var currency model.Currency
+
+	if err := s.decodeJSON(w, r, &currency); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	// the path names the currency, a code in the body can't rename it
+	currency.Code = chi.URLParam(r, "code")
+
+	upserted, err := s.service.UpsertCurrency(r.Context(), currency)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("upsertCurrency/s.service.UpsertCurrency(r.Context(), currency): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, upserted)
+
+	logger.FromContext(r.Context()).Debug("successful PUT:/admin/currencies/{code}", zap.String("client", r.RemoteAddr))
+}
@@ -0,0 +1,47 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+const defaultMaxBodyBytes = 1 << 20
+
+// decodeJSON strictly decodes the request body into dst. The body must be a
+// single JSON value within the size limit, without fields dst doesn't have.
+func (s *APIServer) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	// anything after the value makes the body as malformed as a broken value
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errInvalidBody
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return errBodyTooLarge
+	case err != nil && strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+
+		return &model.FieldError{Field: field, Err: model.ErrUnknownField}
+	}
+
+	return errInvalidBody
+}
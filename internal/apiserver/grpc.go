@@ -0,0 +1,468 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	walletv1 "github.com/Saaghh/wallet/api/wallet/v1"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const requestIDMetadata = "x-request-id"
+
+type GRPCConfig struct {
+	BindAddress string
+}
+
+// GRPCServer serves the wallet operations of the REST API over gRPC.
+type GRPCServer struct {
+	walletv1.UnimplementedWalletServiceServer
+
+	cfg     GRPCConfig
+	service service
+	key     *rsa.PublicKey
+	server  *grpc.Server
+}
+
+func NewGRPC(cfg GRPCConfig, service service, key *rsa.PublicKey) *GRPCServer {
+	s := &GRPCServer{
+		cfg:     cfg,
+		service: service,
+		key:     key,
+	}
+
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(s.requestInfoInterceptor, s.authInterceptor))
+
+	walletv1.RegisterWalletServiceServer(s.server, s)
+
+	return s
+}
+
+func (s *GRPCServer) Run(ctx context.Context) error {
+	zap.L().Debug("starting grpc server")
+	defer zap.L().Info("grpc server stopped")
+
+	listener, err := net.Listen("tcp", s.cfg.BindAddress)
+	if err != nil {
+		return fmt.Errorf("net.Listen(\"tcp\", s.cfg.BindAddress): %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		zap.L().Debug("attempting graceful grpc shutdown")
+
+		stopped := make(chan struct{})
+
+		go func() {
+			s.server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(10 * time.Second):
+			zap.L().Warn("failed to gracefully shutdown grpc server")
+			s.server.Stop()
+		}
+	}()
+
+	zap.L().Info("grpc server starting", zap.String("port", s.cfg.BindAddress))
+
+	if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("s.server.Serve(listener): %w", err)
+	}
+
+	return nil
+}
+
+// authInterceptor is the gRPC counterpart of JWTAuth: it expects the token in
+// the "authorization" metadata entry.
+func (s *GRPCServer) authInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get("authorization")
+	if len(values) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	claims, err := getClaimsFromHeader(values[0], s.key)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Debug("authInterceptor/getClaimsFromHeader(values[0], s.key)")
+
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	if claims.ExpiresAt == nil || claims.ExpiresAt.Before(time.Now()) {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	userInfo := model.UserInfo{
+		ID:   claims.UUID,
+		Role: claims.Role,
+	}
+
+	return handler(context.WithValue(ctx, model.UserInfoKey, userInfo), req)
+}
+
+// requestInfoInterceptor is the gRPC counterpart of RequestInfo.
+func (s *GRPCServer) requestInfoInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	requestInfo := model.RequestInfo{}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(requestIDMetadata); len(values) == 1 {
+		requestInfo.RequestID = values[0]
+	} else {
+		requestInfo.RequestID = uuid.NewString()
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		requestInfo.ClientIP, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestInfo.RequestID)); err != nil {
+		zap.L().With(zap.Error(err)).Warn("requestInfoInterceptor/grpc.SetHeader(...)")
+	}
+
+	return handler(context.WithValue(ctx, model.RequestInfoKey, requestInfo), req)
+}
+
+// grpcError maps service errors to gRPC statuses with the table of
+// writeProblem. The problem code is sent as the reason of an ErrorInfo detail.
+func grpcError(method string, err error) error {
+	mapping, ok := findProblemMapping(err, grpcNilUUID)
+	if !ok {
+		zap.L().With(zap.Error(err)).Warn(method)
+
+		return status.Error(codes.Internal, "internal server error")
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: mapping.code, Domain: problemDomain}}
+	message := mapping.err.Error()
+
+	if fieldErrs := fieldErrors(err); len(fieldErrs) != 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(fieldErrs))
+
+		for _, fieldErr := range fieldErrs {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldErr.Field,
+				Description: fieldErr.Err.Error(),
+			})
+		}
+
+		message = fieldErrs.Error()
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	st, detailsErr := status.New(mapping.grpcCode, message).WithDetails(details...)
+	if detailsErr != nil {
+		zap.L().With(zap.Error(detailsErr)).Warn("grpcError/status.New(...).WithDetails(details...)")
+
+		return status.Error(mapping.grpcCode, message)
+	}
+
+	return st.Err()
+}
+
+func parseID(value, field string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "can't get %s", field)
+	}
+
+	return id, nil
+}
+
+func (s *GRPCServer) CreateWallet(ctx context.Context, req *walletv1.CreateWalletRequest) (*walletv1.Wallet, error) {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, grpcError("CreateWallet", model.ErrUserInfoNotOk)
+	}
+
+	request := model.NewWallet{
+		Currency: req.GetCurrency(),
+		Name:     req.GetName(),
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, grpcError("CreateWallet/request.Validate()", err)
+	}
+
+	wallet, err := s.service.CreateWallet(ctx, model.Wallet{
+		OwnerID:  userInfo.ID,
+		Currency: request.Currency,
+		Name:     request.Name,
+	})
+	if err != nil {
+		return nil, grpcError("CreateWallet/s.service.CreateWallet(...)", err)
+	}
+
+	return walletToProto(wallet), nil
+}
+
+func (s *GRPCServer) GetWallet(ctx context.Context, req *walletv1.GetWalletRequest) (*walletv1.Wallet, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.service.GetWalletByID(ctx, id)
+
+	if err != nil {
+		// like REST, don't reveal wallets of other users
+		return nil, grpcError("GetWallet/s.service.GetWalletByID(ctx, id)", hideForeignWallet(err))
+	}
+
+	return walletToProto(wallet), nil
+}
+
+func (s *GRPCServer) ListWallets(
+	ctx context.Context,
+	req *walletv1.ListWalletsRequest,
+) (*walletv1.ListWalletsResponse, error) {
+	wallets, err := s.service.GetWallets(ctx, paramsFromProto(req.GetParams()))
+	if err != nil {
+		return nil, grpcError("ListWallets/s.service.GetWallets(...)", err)
+	}
+
+	resp := &walletv1.ListWalletsResponse{Wallets: make([]*walletv1.Wallet, 0, len(wallets))}
+
+	for _, wallet := range wallets {
+		resp.Wallets = append(resp.Wallets, walletToProto(wallet))
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCServer) UpdateWallet(ctx context.Context, req *walletv1.UpdateWalletRequest) (*walletv1.Wallet, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	request := model.UpdateWalletRequest{
+		Name:     req.Name,
+		Currency: req.Currency,
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, grpcError("UpdateWallet/request.Validate()", err)
+	}
+
+	wallet, err := s.service.UpdateWallet(ctx, id, request)
+	if err != nil {
+		return nil, grpcError("UpdateWallet/s.service.UpdateWallet(...)", err)
+	}
+
+	return walletToProto(wallet), nil
+}
+
+func (s *GRPCServer) DeleteWallet(
+	ctx context.Context,
+	req *walletv1.DeleteWalletRequest,
+) (*walletv1.DeleteWalletResponse, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var sweepTo *uuid.UUID
+
+	if req.SweepTo != nil {
+		sweepToID, err := parseID(req.GetSweepTo(), "sweep_to")
+		if err != nil {
+			return nil, err
+		}
+
+		sweepTo = &sweepToID
+	}
+
+	err = s.service.DeleteWallet(ctx, id, sweepTo)
+	if err != nil {
+		return nil, grpcError("DeleteWallet/s.service.DeleteWallet(ctx, id, sweepTo)", err)
+	}
+
+	return &walletv1.DeleteWalletResponse{}, nil
+}
+
+func (s *GRPCServer) Transfer(ctx context.Context, req *walletv1.TransferRequest) (*walletv1.TransactionResponse, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	agentWalletID, err := parseID(req.GetAgentWalletId(), "agent_wallet_id")
+	if err != nil {
+		return nil, err
+	}
+
+	targetWalletID, err := parseID(req.GetTargetWalletId(), "target_wallet_id")
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := model.Transaction{
+		ID:             id,
+		AgentWalletID:  &agentWalletID,
+		TargetWalletID: &targetWalletID,
+		Currency:       req.GetCurrency(),
+		Sum:            req.GetSum(),
+	}
+
+	if err := transaction.Validate(); err != nil {
+		return nil, grpcError("Transfer/transaction.Validate()", err)
+	}
+
+	transactionID, err := s.service.Transfer(ctx, transaction)
+	if err != nil {
+		return nil, grpcError("Transfer/s.service.Transfer(ctx, transaction)", err)
+	}
+
+	return &walletv1.TransactionResponse{TransactionId: transactionID.String()}, nil
+}
+
+func (s *GRPCServer) Deposit(
+	ctx context.Context,
+	req *walletv1.ExternalTransactionRequest,
+) (*walletv1.TransactionResponse, error) {
+	return s.externalTransaction(ctx, req, 1)
+}
+
+func (s *GRPCServer) Withdraw(
+	ctx context.Context,
+	req *walletv1.ExternalTransactionRequest,
+) (*walletv1.TransactionResponse, error) {
+	return s.externalTransaction(ctx, req, -1)
+}
+
+func (s *GRPCServer) externalTransaction(
+	ctx context.Context,
+	req *walletv1.ExternalTransactionRequest,
+	sign float64,
+) (*walletv1.TransactionResponse, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	walletID, err := parseID(req.GetWalletId(), "wallet_id")
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := model.Transaction{
+		ID:             id,
+		TargetWalletID: &walletID,
+		Currency:       req.GetCurrency(),
+		Sum:            req.GetSum(),
+	}
+
+	if err := transaction.Validate(); err != nil {
+		return nil, grpcError("externalTransaction/transaction.Validate()", err)
+	}
+
+	transaction.Sum *= sign
+
+	transactionID, err := s.service.ExternalTransaction(ctx, transaction)
+	if err != nil {
+		return nil, grpcError("externalTransaction/s.service.ExternalTransaction(ctx, transaction)", err)
+	}
+
+	return &walletv1.TransactionResponse{TransactionId: transactionID.String()}, nil
+}
+
+func (s *GRPCServer) ListTransactions(
+	ctx context.Context,
+	req *walletv1.ListTransactionsRequest,
+) (*walletv1.ListTransactionsResponse, error) {
+	transactions, err := s.service.GetTransactions(ctx, paramsFromProto(req.GetParams()))
+	if err != nil {
+		return nil, grpcError("ListTransactions/s.service.GetTransactions(...)", err)
+	}
+
+	resp := &walletv1.ListTransactionsResponse{
+		Transactions: make([]*walletv1.Transaction, 0, len(transactions)),
+	}
+
+	for _, transaction := range transactions {
+		resp.Transactions = append(resp.Transactions, transactionToProto(transaction))
+	}
+
+	return resp, nil
+}
+
+func paramsFromProto(params *walletv1.ListParams) model.GetParams {
+	result := model.GetParams{
+		Offset:     int(params.GetOffset()),
+		Limit:      int(params.GetLimit()),
+		Sorting:    params.GetSorting(),
+		Descending: params.GetDescending(),
+		Filter:     params.GetFilter(),
+	}
+
+	if result.Limit == 0 {
+		result.Limit = model.StandardPage
+	}
+
+	return result
+}
+
+func walletToProto(wallet *model.Wallet) *walletv1.Wallet {
+	return &walletv1.Wallet{
+		Id:           wallet.ID.String(),
+		OwnerId:      wallet.OwnerID.String(),
+		Currency:     wallet.Currency,
+		Balance:      wallet.Balance,
+		CreatedAt:    timestamppb.New(wallet.CreatedDate),
+		ModifiedAt:   timestamppb.New(wallet.ModifiedDate),
+		Name:         wallet.Name,
+		Status:       string(wallet.Status),
+		StatusReason: wallet.StatusReason,
+	}
+}
+
+func transactionToProto(transaction *model.Transaction) *walletv1.Transaction {
+	result := &walletv1.Transaction{
+		Id:        transaction.ID.String(),
+		CreatedAt: timestamppb.New(transaction.CreatedAt),
+		Currency:  transaction.Currency,
+		Sum:       transaction.Sum,
+		Type:      string(transaction.Type),
+	}
+
+	if transaction.AgentWalletID != nil {
+		id := transaction.AgentWalletID.String()
+		result.AgentWalletId = &id
+	}
+
+	if transaction.TargetWalletID != nil {
+		id := transaction.TargetWalletID.String()
+		result.TargetWalletId = &id
+	}
+
+	return result
+}
@@ -4,17 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/Saaghh/wallet/internal/logger"
 	"github.com/Saaghh/wallet/internal/model"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// HTTPResponse wraps successful responses, failures are written as Problem.
 type HTTPResponse struct {
-	Data  any    `json:"data,omitempty"`
-	Error string `json:"error,omitempty"`
+	Data any `json:"data,omitempty"`
 }
 
 type TransferResponse struct {
@@ -25,292 +27,267 @@ type service interface {
 	CreateWallet(ctx context.Context, wallet model.Wallet) (*model.Wallet, error)
 	GetWalletByID(ctx context.Context, walletID uuid.UUID) (*model.Wallet, error)
 	GetWallets(ctx context.Context, params model.GetParams) ([]*model.Wallet, error)
-	DeleteWallet(ctx context.Context, walletID uuid.UUID) error
+	DeleteWallet(ctx context.Context, walletID uuid.UUID, sweepTo *uuid.UUID) error
 	UpdateWallet(ctx context.Context, walletID uuid.UUID, request model.UpdateWalletRequest) (*model.Wallet, error)
 
 	GetTransactions(ctx context.Context, params model.GetParams) ([]*model.Transaction, error)
 	Transfer(ctx context.Context, wtx model.Transaction) (*uuid.UUID, error)
 	ExternalTransaction(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error)
+
+	WalletEvents(ctx context.Context, lastEventID *int64) (<-chan model.Event, error)
+
+	GetAuditLog(ctx context.Context, params model.AuditParams) ([]*model.AuditEntry, error)
+
+	GetCurrencies(ctx context.Context) ([]*model.Currency, error)
+	GetCurrency(ctx context.Context, code string) (*model.Currency, error)
+	UpsertCurrency(ctx context.Context, currency model.Currency) (*model.Currency, error)
+
+	SearchUsers(ctx context.Context, email string, params model.GetParams) ([]*model.User, error)
+	GetUserWallets(ctx context.Context, userID uuid.UUID, params model.GetParams) ([]*model.Wallet, error)
+	GetUserTransactions(ctx context.Context, userID uuid.UUID, params model.GetParams) ([]*model.Transaction, error)
+	FreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error)
+	UnfreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error)
+	EnableWallet(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error)
+	SetWalletStatus(ctx context.Context, walletID uuid.UUID, request model.WalletStatusRequest) (*model.Wallet, error)
+	AdjustBalance(ctx context.Context, adjustment model.BalanceAdjustment) (*uuid.UUID, error)
+	GetSpreadIncome(ctx context.Context, params model.ReportParams) ([]*model.SpreadIncomeReport, error)
+
+	CreateWebhook(ctx context.Context, subscription model.WebhookSubscription) (*model.WebhookSubscription, error)
+	GetWebhooks(ctx context.Context) ([]*model.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, subscriptionID uuid.UUID) error
+	GetWebhookDeliveries(
+		ctx context.Context,
+		subscriptionID uuid.UUID,
+		params model.DeliveryParams,
+	) ([]*model.WebhookDelivery, error)
+	RetryWebhookDelivery(ctx context.Context, deliveryID uuid.UUID) error
+
+	CheckReadiness(ctx context.Context) model.Readiness
 }
 
 func (s *APIServer) createWallet(w http.ResponseWriter, r *http.Request) {
-	var rWallet model.Wallet
+	var request model.NewWallet
 
 	userInfo, ok := r.Context().Value(model.UserInfoKey).(model.UserInfo)
 	if !ok {
-		zap.L().With(zap.Error(model.ErrUserInfoNotOk)).Warn(
+		logger.FromContext(r.Context()).With(zap.Error(model.ErrUserInfoNotOk)).Warn(
 			"createWallet/r.Context().Value(model.UserInfoKey).(model.UserInfo)")
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&rWallet); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to read body")
+	if err := s.decodeJSON(w, r, &request); err != nil {
+		writeProblem(w, r, err)
 
 		return
 	}
 
-	rWallet.OwnerID = userInfo.ID
-
-	wallet, err := s.service.CreateWallet(r.Context(), rWallet)
-
-	switch {
-	case errors.Is(err, model.ErrNilUUID):
-		fallthrough
-	case errors.Is(err, model.ErrDuplicateWallet):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "wallet name is already taken")
+	if err := request.Validate(); err != nil {
+		writeProblem(w, r, err)
 
 		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn(
-			"createWallet/s.service.CreateWallet(r.Context(), model.User{ID: rWallet.OwnerID}, rWallet.Currency)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	}
+
+	wallet, err := s.service.CreateWallet(r.Context(), model.Wallet{
+		OwnerID:  userInfo.ID,
+		Currency: request.Currency,
+		Name:     request.Name,
+	})
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("createWallet/s.service.CreateWallet(r.Context(), model.Wallet{...}): %w", err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusCreated, wallet)
+	writeOkResponse(w, r, http.StatusCreated, wallet)
 
-	zap.L().Debug("successful POST:/wallet", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful POST:/wallet", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) getWallets(w http.ResponseWriter, r *http.Request) {
 	params, err := model.ValuesToGetParams(r.URL.Query())
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn("getWallets/model.ValuesToGetParams(r.URL.Query())")
-		writeErrorResponse(w, http.StatusBadRequest, "error reading query params")
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getWallets/model.ValuesToGetParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
 
 		return
 	}
 
 	wallets, err := s.service.GetWallets(r.Context(), *params)
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn("getWallets/s.service.GetWallets(r.Context(), rUser)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeProblem(w, r, fmt.Errorf("getWallets/s.service.GetWallets(r.Context(), *params): %w", err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, wallets)
+	writeOkResponse(w, r, http.StatusOK, wallets)
 
-	zap.L().Debug("successful GET:/wallets", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful GET:/wallets", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) getWalletByID(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "can't get id")
+		writeProblem(w, r, errInvalidID)
 
 		return
 	}
 
 	wallet, err := s.service.GetWalletByID(r.Context(), id)
-
-	switch {
-	case errors.Is(err, model.ErrNilUUID):
-		fallthrough
-	case errors.Is(err, model.ErrNotAllowed):
-		fallthrough
-	case errors.Is(err, model.ErrWalletNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "wallet not found")
-
-		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("getWalletByID/s.service.GetWalletByID(r.Context(), walletID))")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err != nil {
+		writeProblem(w, r, hideForeignWallet(err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, wallet)
+	writeOkResponse(w, r, http.StatusOK, wallet)
 
-	zap.L().Debug("successful GET:/wallets/{id}", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful GET:/wallets/{id}", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) updateWallet(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "can't get id")
+		writeProblem(w, r, errInvalidID)
 
 		return
 	}
 
 	var updateRequest model.UpdateWalletRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to read body")
+	if err := s.decodeJSON(w, r, &updateRequest); err != nil {
+		writeProblem(w, r, err)
 
 		return
 	}
 
-	wallet, err := s.service.UpdateWallet(r.Context(), id, updateRequest)
-
-	switch {
-	case errors.Is(err, model.ErrNilUUID):
-		fallthrough
-	case errors.Is(err, model.ErrWalletNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "wallet not found")
+	if err := updateRequest.Validate(); err != nil {
+		writeProblem(w, r, err)
 
 		return
-	case errors.Is(err, model.ErrDuplicateWallet):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "duplicate names not allowed")
+	}
 
-		return
-	case errors.Is(err, model.ErrWrongCurrency):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "wrong currency")
+	wallet, err := s.service.UpdateWallet(r.Context(), id, updateRequest)
 
-		return
-	case errors.Is(err, model.ErrNotAllowed):
-		writeErrorResponse(w, http.StatusUnauthorized, "operation not allowed")
+	switch {
+	case errors.Is(err, model.ErrNilUUID):
+		writeProblem(w, r, model.ErrWalletNotFound)
 
 		return
 	case err != nil:
-		zap.L().With(zap.Error(err)).Warn(
-			"updateWallet/s.service.UpdateWallet(r.Context(), id, updateRequest)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeProblem(w, r, fmt.Errorf("updateWallet/s.service.UpdateWallet(r.Context(), id, updateRequest): %w", err),
+			notAllowedUnauthorized)
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, wallet)
+	writeOkResponse(w, r, http.StatusOK, wallet)
 
-	zap.L().Debug("successful PATCH:/wallets/{id}", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful PATCH:/wallets/{id}", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) deleteWallet(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "can't get id")
+		writeProblem(w, r, errInvalidID)
 
 		return
 	}
 
-	err = s.service.DeleteWallet(r.Context(), id)
+	var sweepTo *uuid.UUID
 
-	switch {
-	case errors.Is(err, model.ErrNilUUID):
-		fallthrough
-	case errors.Is(err, model.ErrWalletNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "wallet not found")
+	if value := r.URL.Query().Get("sweepTo"); value != "" {
+		sweepToID, err := uuid.Parse(value)
+		if err != nil {
+			writeProblem(w, r, errInvalidQuery)
 
-		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("deleteWallet/s.service.DeleteWallet(r.Context(), id)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		sweepTo = &sweepToID
+	}
+
+	err = s.service.DeleteWallet(r.Context(), id, sweepTo)
+	if err != nil {
+		writeProblem(w, r, hideForeignWallet(err))
 
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 
-	zap.L().Debug("successful DELETE:/wallets/{id}", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful DELETE:/wallets/{id}", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) deposit(w http.ResponseWriter, r *http.Request) {
 	var requestTransaction model.Transaction
 
-	err := json.NewDecoder(r.Body).Decode(&requestTransaction)
+	err := s.decodeJSON(w, r, &requestTransaction)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to read body")
+		writeProblem(w, r, err)
 
 		return
 	}
 
 	err = requestTransaction.Validate()
 	if err != nil {
-		writeErrorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		writeProblem(w, r, err)
 
 		return
 	}
 
 	transferID, err := s.service.ExternalTransaction(r.Context(), requestTransaction)
-
-	switch {
-	case errors.Is(err, model.ErrWalletNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "wallet not found")
-
-		return
-	case errors.Is(err, model.ErrWrongCurrency):
-		fallthrough
-	case errors.Is(err, model.ErrNilUUID):
-		fallthrough
-	case errors.Is(err, model.ErrNegativeSum):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "incorrect request data")
-
-		return
-	case errors.Is(err, model.ErrDuplicateTransaction):
-		writeErrorResponse(w, http.StatusTooManyRequests, "transaction already exists")
-
-		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("deposit/s.service.ExternalTransaction(r.Context(), requestTransaction)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("deposit/s.service.ExternalTransaction(r.Context(), requestTransaction): %w", err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, TransferResponse{TransactionID: *transferID})
+	writeOkResponse(w, r, http.StatusOK, TransferResponse{TransactionID: *transferID})
 
-	zap.L().Debug("successful PUT:/deposit", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful PUT:/deposit", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) transfer(w http.ResponseWriter, r *http.Request) {
 	var requestTransaction model.Transaction
 
-	err := json.NewDecoder(r.Body).Decode(&requestTransaction)
+	err := s.decodeJSON(w, r, &requestTransaction)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to read body")
+		writeProblem(w, r, err)
 
 		return
 	}
 
 	err = requestTransaction.Validate()
 	if err != nil {
-		writeErrorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		writeProblem(w, r, err)
 
 		return
 	}
 
 	transferID, err := s.service.Transfer(r.Context(), requestTransaction)
-
-	switch {
-	case errors.Is(err, model.ErrWalletNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "wallet not found")
-
-		return
-	case errors.Is(err, model.ErrNotEnoughBalance):
-		fallthrough
-	case errors.Is(err, model.ErrWrongCurrency):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "incorrect request data")
-
-		return
-	case errors.Is(err, model.ErrDuplicateTransaction):
-		writeErrorResponse(w, http.StatusTooManyRequests, "transaction already exists")
-
-		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("s.service.Transfer(r.Context(), requestTransaction)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("transfer/s.service.Transfer(r.Context(), requestTransaction): %w", err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, TransferResponse{TransactionID: *transferID})
+	writeOkResponse(w, r, http.StatusOK, TransferResponse{TransactionID: *transferID})
 
-	zap.L().Debug("successful PUT:/transfer", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful PUT:/transfer", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) withdraw(w http.ResponseWriter, r *http.Request) {
 	var requestTransaction model.Transaction
 
-	err := json.NewDecoder(r.Body).Decode(&requestTransaction)
+	err := s.decodeJSON(w, r, &requestTransaction)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "failed to read body")
+		writeProblem(w, r, err)
 
 		return
 	}
 
 	err = requestTransaction.Validate()
 	if err != nil {
-		writeErrorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		writeProblem(w, r, err)
 
 		return
 	}
@@ -318,82 +295,66 @@ func (s *APIServer) withdraw(w http.ResponseWriter, r *http.Request) {
 	requestTransaction.Sum *= -1
 
 	transferID, err := s.service.ExternalTransaction(r.Context(), requestTransaction)
-
-	switch {
-	case errors.Is(err, model.ErrWalletNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "wallet not found")
-
-		return
-	case errors.Is(err, model.ErrWrongCurrency):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "incorrect request data")
-
-		return
-	case errors.Is(err, model.ErrNotEnoughBalance):
-		writeErrorResponse(w, http.StatusUnprocessableEntity, "not enough balance")
-
-		return
-	case errors.Is(err, model.ErrDuplicateTransaction):
-		writeErrorResponse(w, http.StatusTooManyRequests, "transaction already exists")
-
-		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("withdraw/s.service.ExternalTransaction(r.Context(), requestTransaction)")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("withdraw/s.service.ExternalTransaction(r.Context(), requestTransaction): %w", err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, TransferResponse{TransactionID: *transferID})
+	writeOkResponse(w, r, http.StatusOK, TransferResponse{TransactionID: *transferID})
 
-	zap.L().Debug("successful PUT:/withdraw", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful PUT:/withdraw", zap.String("client", r.RemoteAddr))
 }
 
 func (s *APIServer) getTransactions(w http.ResponseWriter, r *http.Request) {
 	params, err := model.ValuesToGetParams(r.URL.Query())
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn("getTransactions/model.ValuesToGetParams(r.URL.Query())")
-		writeErrorResponse(w, http.StatusBadRequest, "error reading query params")
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getTransactions/model.ValuesToGetParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
 
 		return
 	}
 
 	transactions, err := s.service.GetTransactions(r.Context(), *params)
-
-	switch {
-	case errors.Is(err, model.ErrTransactionsNotFound):
-		writeErrorResponse(w, http.StatusNotFound, "transactions not found")
-
-		return
-	case err != nil:
-		zap.L().With(zap.Error(err)).Warn("getTransactions/s.service.GetTransactions(r.Context())")
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getTransactions/s.service.GetTransactions(r.Context(), *params): %w", err))
 
 		return
 	}
 
-	writeOkResponse(w, http.StatusOK, transactions)
+	writeOkResponse(w, r, http.StatusOK, transactions)
 
-	zap.L().Debug("successful GET:/wallets/transactions", zap.String("client", r.RemoteAddr))
+	logger.FromContext(r.Context()).Debug("successful GET:/wallets/transactions", zap.String("client", r.RemoteAddr))
 }
 
-func writeOkResponse(w http.ResponseWriter, statusCode int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+func (s *APIServer) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	params, err := model.ValuesToAuditParams(r.URL.Query())
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getAuditLog/model.ValuesToAuditParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
 
-	err := json.NewEncoder(w).Encode(HTTPResponse{Data: data})
+		return
+	}
+
+	entries, err := s.service.GetAuditLog(r.Context(), *params)
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn(
-			"writeOkResponse/json.NewEncoder(w).Encode(HTTPResponse{Data: data})")
+		writeProblem(w, r, fmt.Errorf("getAuditLog/s.service.GetAuditLog(r.Context(), *params): %w", err))
+
+		return
 	}
+
+	writeOkResponse(w, r, http.StatusOK, entries)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/audit", zap.String("client", r.RemoteAddr))
 }
 
-func writeErrorResponse(w http.ResponseWriter, statusCode int, description string) {
+func writeOkResponse(w http.ResponseWriter, r *http.Request, statusCode int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
-	err := json.NewEncoder(w).Encode(HTTPResponse{Error: description})
+	err := json.NewEncoder(w).Encode(HTTPResponse{Data: data})
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn(
-			"writeErrorResponse/json.NewEncoder(w).Encode(HTTPResponse{Error: data})")
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn(
+			"writeOkResponse/json.NewEncoder(w).Encode(HTTPResponse{Data: data})")
 	}
 }
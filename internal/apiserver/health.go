@@ -0,0 +1,31 @@
+package apiserver
+
+import (
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+// getHealth tells that the process is alive, dependencies aside.
+func (s *APIServer) getHealth(w http.ResponseWriter, r *http.Request) {
+	writeOkResponse(w, r, http.StatusOK, model.Readiness{Status: model.HealthOK})
+}
+
+// getReadiness reports the status of each dependency, 503 when any is down
+// or the server is shutting down.
+func (s *APIServer) getReadiness(w http.ResponseWriter, r *http.Request) {
+	if s.stopping.Load() {
+		writeOkResponse(w, r, http.StatusServiceUnavailable, model.Readiness{Status: model.HealthStopping})
+
+		return
+	}
+
+	readiness := s.service.CheckReadiness(r.Context())
+
+	statusCode := http.StatusOK
+	if readiness.Status != model.HealthOK {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeOkResponse(w, r, statusCode, readiness)
+}
@@ -3,42 +3,36 @@ package apiserver
 import (
 	"context"
 	"crypto/rsa"
-	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
-	"go.uber.org/zap"
 )
 
 func (s *APIServer) JWTAuth(next http.Handler) http.Handler {
 	var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
 		claims, err := getClaimsFromHeader(r.Header.Get("Authorization"), s.key)
-
-		switch {
-		case errors.Is(err, model.ErrInvalidAccessToken):
-			writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
-
-			return
-		case err != nil:
-			zap.L().With(zap.Error(err)).Warn("JWTAuth/getClaimsFromHeader(r.Header.Get(\"Authorization\"), s.key)")
-			writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		if err != nil {
+			writeProblem(w, r, fmt.Errorf("JWTAuth/getClaimsFromHeader(r.Header.Get(\"Authorization\"), s.key): %w", err))
 
 			return
 		}
 
 		expiresAtTime := time.Unix(claims.ExpiresAt.Unix(), 0)
 		if expiresAtTime.Before(time.Now()) {
-			writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			writeProblem(w, r, model.ErrInvalidAccessToken)
 
 			return
 		}
 
 		userInfo := model.UserInfo{
-			ID: claims.UUID,
+			ID:   claims.UUID,
+			Role: claims.Role,
 		}
 
 		r = r.WithContext(context.WithValue(r.Context(), model.UserInfoKey, userInfo))
@@ -48,6 +42,21 @@ func (s *APIServer) JWTAuth(next http.Handler) http.Handler {
 	return fn
 }
 
+func (s *APIServer) AdminOnly(next http.Handler) http.Handler {
+	var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		userInfo, ok := r.Context().Value(model.UserInfoKey).(model.UserInfo)
+		if !ok || !userInfo.IsAdmin() {
+			writeProblem(w, r, model.ErrNotAllowed)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return fn
+}
+
 func parseToken(accessToken string, key *rsa.PublicKey) (*model.Claims, error) {
 	token, err := jwt.ParseWithClaims(accessToken, &model.Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
@@ -88,12 +97,51 @@ func getClaimsFromHeader(authHeader string, key *rsa.PublicKey) (*model.Claims,
 	return claims, nil
 }
 
-func (s *APIServer) Metrics(next http.Handler) http.Handler {
+func (s *APIServer) RequestInfo(next http.Handler) http.Handler {
 	var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
-		defer s.metrics.TrackHTTPRequest(time.Now(), r)
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
 
+		requestInfo := model.RequestInfo{
+			RequestID: middleware.GetReqID(r.Context()),
+			ClientIP:  clientIP,
+		}
+
+		w.Header().Set(middleware.RequestIDHeader, requestInfo.RequestID)
+
+		r = r.WithContext(context.WithValue(r.Context(), model.RequestInfoKey, requestInfo))
 		next.ServeHTTP(w, r)
 	}
 
 	return fn
 }
+
+// Metrics tracks requests by the route they matched, the status and size of
+// the response are captured by wrapping the writer.
+func (s *APIServer) Metrics(next http.Handler) http.Handler {
+	var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		s.metrics.TrackHTTPRequestStart()
+
+		defer func() {
+			s.metrics.TrackHTTPRequest(start, r, responseStatus(ww), ww.BytesWritten())
+		}()
+
+		next.ServeHTTP(ww, r)
+	}
+
+	return fn
+}
+
+// responseStatus is the status written, 200 when the handler wrote none.
+func responseStatus(ww middleware.WrapResponseWriter) int {
+	if ww.Status() == 0 {
+		return http.StatusOK
+	}
+
+	return ww.Status()
+}
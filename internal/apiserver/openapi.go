@@ -0,0 +1,167 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"go.uber.org/zap"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the OpenAPI 3 document describing the routes of configRouter.
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// LoadOpenAPI parses and validates the OpenAPI document.
+func LoadOpenAPI(ctx context.Context) (*openapi3.T, routers.Router, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("openapi3.NewLoader().LoadFromData(openAPISpec): %w", err)
+	}
+
+	if err = doc.Validate(ctx); err != nil {
+		return nil, nil, fmt.Errorf("doc.Validate(ctx): %w", err)
+	}
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("legacy.NewRouter(doc): %w", err)
+	}
+
+	return doc, router, nil
+}
+
+func (s *APIServer) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(openAPISpec); err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getOpenAPI/w.Write(openAPISpec)")
+	}
+}
+
+// OpenAPIValidation rejects requests that don't match the OpenAPI document
+// and logs responses that don't. Authentication is left to JWTAuth.
+func (s *APIServer) OpenAPIValidation(router routers.Router) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := router.FindRoute(r)
+			if err != nil {
+				logger.FromContext(r.Context()).With(zap.Error(err)).Warn("OpenAPIValidation: route is not documented",
+					zap.String("method", r.Method), zap.String("path", r.URL.Path))
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			requestInput := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+					MultiError:         true,
+				},
+			}
+
+			if err := openapi3filter.ValidateRequest(r.Context(), requestInput); err != nil {
+				writeProblem(w, r, fmt.Errorf("%w: %w", errInvalidInput, err))
+
+				return
+			}
+
+			// streams can't be buffered for validation
+			if isStream(route) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			recorder := &bufferedResponseWriter{header: http.Header{}, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: requestInput,
+				Status:                 recorder.status,
+				Header:                 recorder.header,
+				Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+			})
+			if err != nil {
+				logger.FromContext(r.Context()).With(zap.Error(err)).Warn("OpenAPIValidation: response does not conform",
+					zap.String("method", r.Method), zap.String("path", r.URL.Path))
+			}
+
+			recorder.flushTo(w)
+		}
+
+		return fn
+	}
+}
+
+func isStream(route *routers.Route) bool {
+	for _, response := range route.Operation.Responses {
+		if response.Value != nil && response.Value.Content.Get("text/event-stream") != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufferedResponseWriter holds a response back until it has been validated.
+type bufferedResponseWriter struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+
+	b.status = status
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponseWriter) Write(data []byte) (int, error) {
+	b.wroteHeader = true
+
+	n, err := b.body.Write(data)
+	if err != nil {
+		return n, fmt.Errorf("b.body.Write(data): %w", err)
+	}
+
+	return n, nil
+}
+
+func (b *bufferedResponseWriter) flushTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+
+	w.WriteHeader(b.status)
+
+	if _, err := w.Write(b.body.Bytes()); err != nil {
+		zap.L().With(zap.Error(err)).Warn("flushTo/w.Write(b.body.Bytes())")
+	}
+}
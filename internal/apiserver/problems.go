@@ -0,0 +1,237 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemDomain      = "wallet"
+	problemTypePrefix  = "urn:" + problemDomain + ":problem:"
+
+	codeInternalError    = "internal_error"
+	codeValidationFailed = "validation_failed"
+)
+
+// Errors of the transport layer, the rest comes from model.
+var (
+	errInvalidBody   = errors.New("failed to read body")
+	errInvalidQuery  = errors.New("error reading query params")
+	errInvalidID     = errors.New("can't get id")
+	errInvalidHeader = errors.New("invalid header")
+	errInvalidInput  = errors.New("request does not match the api specification")
+	errNoStreaming   = errors.New("streaming unsupported")
+	errBodyTooLarge  = errors.New("request body is too large")
+
+	errValidationFailed = errors.New("request validation failed")
+)
+
+// Problem is an RFC 7807 error response. Code is stable and meant for clients
+// to branch on, Title is its human-readable form.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	RequestID string              `json:"requestId,omitempty"`
+	Errors    []ProblemFieldError `json:"errors,omitempty"`
+}
+
+type ProblemFieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type problemMapping struct {
+	err        error
+	httpStatus int
+	grpcCode   codes.Code
+	code       string
+}
+
+// problemMappings is the single place errors are turned into statuses, both
+// for REST and gRPC. The first matching entry wins.
+var problemMappings = []problemMapping{
+	{model.ErrWalletNotFound, http.StatusNotFound, codes.NotFound, "wallet_not_found"},
+	{model.ErrUserNotFound, http.StatusNotFound, codes.NotFound, "user_not_found"},
+	{model.ErrTransactionsNotFound, http.StatusNotFound, codes.NotFound, "transactions_not_found"},
+	{model.ErrWebhookNotFound, http.StatusNotFound, codes.NotFound, "webhook_not_found"},
+	{model.ErrDeliveryNotFound, http.StatusNotFound, codes.NotFound, "delivery_not_found"},
+	{model.ErrCurrencyNotFound, http.StatusNotFound, codes.NotFound, "currency_not_found"},
+
+	{model.ErrWrongCurrency, http.StatusUnprocessableEntity, codes.InvalidArgument, "wrong_currency"},
+	{model.ErrNegativeSum, http.StatusUnprocessableEntity, codes.InvalidArgument, "negative_sum"},
+	{model.ErrZeroSum, http.StatusUnprocessableEntity, codes.InvalidArgument, "zero_sum"},
+	{model.ErrNilUUID, http.StatusUnprocessableEntity, codes.InvalidArgument, "nil_uuid"},
+	{model.ErrSameWallet, http.StatusUnprocessableEntity, codes.InvalidArgument, "same_wallet"},
+	{model.ErrEmptyReason, http.StatusUnprocessableEntity, codes.InvalidArgument, "empty_reason"},
+	{model.ErrInvalidStatus, http.StatusUnprocessableEntity, codes.InvalidArgument, "invalid_status"},
+	{model.ErrInvalidWebhookURL, http.StatusUnprocessableEntity, codes.InvalidArgument, "invalid_webhook_url"},
+	{model.ErrUnknownEvent, http.StatusUnprocessableEntity, codes.InvalidArgument, "unknown_event"},
+	{model.ErrEmptyName, http.StatusUnprocessableEntity, codes.InvalidArgument, "empty_name"},
+	{model.ErrNameTooLong, http.StatusUnprocessableEntity, codes.InvalidArgument, "name_too_long"},
+	{model.ErrInvalidName, http.StatusUnprocessableEntity, codes.InvalidArgument, "invalid_name"},
+	{model.ErrInvalidAmount, http.StatusUnprocessableEntity, codes.InvalidArgument, "invalid_amount"},
+	{model.ErrAmountPrecision, http.StatusUnprocessableEntity, codes.InvalidArgument, "amount_precision"},
+	{model.ErrUnknownField, http.StatusUnprocessableEntity, codes.InvalidArgument, "unknown_field"},
+	{model.ErrRequired, http.StatusUnprocessableEntity, codes.InvalidArgument, "required"},
+	{model.ErrOutOfRange, http.StatusUnprocessableEntity, codes.InvalidArgument, "out_of_range"},
+	{model.ErrCurrencyDisabled, http.StatusUnprocessableEntity, codes.FailedPrecondition, "currency_disabled"},
+
+	{model.ErrNotEnoughBalance, http.StatusUnprocessableEntity, codes.FailedPrecondition, "not_enough_balance"},
+	{model.ErrWalletFrozen, http.StatusUnprocessableEntity, codes.FailedPrecondition, "wallet_frozen"},
+	{model.ErrNonZeroBalance, http.StatusUnprocessableEntity, codes.FailedPrecondition, "non_zero_balance"},
+	{model.ErrDuplicateWallet, http.StatusUnprocessableEntity, codes.AlreadyExists, "duplicate_wallet"},
+	{model.ErrDuplicateNumericCode, http.StatusConflict, codes.AlreadyExists, "duplicate_numeric_code"},
+	{model.ErrStatusTransition, http.StatusConflict, codes.FailedPrecondition, "status_transition"},
+	{model.ErrWalletWasChanged, http.StatusConflict, codes.Aborted, "wallet_changed"},
+	{model.ErrDuplicateTransaction, http.StatusTooManyRequests, codes.AlreadyExists, "duplicate_transaction"},
+
+	{model.ErrInvalidAccessToken, http.StatusUnauthorized, codes.Unauthenticated, "unauthorized"},
+	{model.ErrUserInfoNotOk, http.StatusUnauthorized, codes.Unauthenticated, "unauthorized"},
+	{model.ErrNotAllowed, http.StatusForbidden, codes.PermissionDenied, "not_allowed"},
+
+	{model.ErrGettingXR, http.StatusServiceUnavailable, codes.Unavailable, "exchange_rate_unavailable"},
+
+	{errInvalidBody, http.StatusBadRequest, codes.InvalidArgument, "invalid_body"},
+	{errInvalidQuery, http.StatusBadRequest, codes.InvalidArgument, "invalid_query"},
+	{model.ErrInvalidSorting, http.StatusBadRequest, codes.InvalidArgument, "invalid_sorting"},
+	{errInvalidID, http.StatusBadRequest, codes.InvalidArgument, "invalid_id"},
+	{errInvalidHeader, http.StatusBadRequest, codes.InvalidArgument, "invalid_header"},
+	{errInvalidInput, http.StatusBadRequest, codes.InvalidArgument, "invalid_input"},
+	{errBodyTooLarge, http.StatusRequestEntityTooLarge, codes.ResourceExhausted, "body_too_large"},
+	{errNoStreaming, http.StatusInternalServerError, codes.Unimplemented, "streaming_unsupported"},
+}
+
+// notAllowedUnauthorized keeps the historical 401 of PATCH /wallets/{id}.
+var notAllowedUnauthorized = problemMapping{
+	model.ErrNotAllowed, http.StatusUnauthorized, codes.PermissionDenied, "not_allowed",
+}
+
+// grpcNilUUID keeps gRPC reporting lookups by nil id as missing wallets.
+var grpcNilUUID = problemMapping{
+	model.ErrNilUUID, http.StatusNotFound, codes.NotFound, "wallet_not_found",
+}
+
+// findProblemMapping returns the mapping of err, looking at overrides first.
+// Field errors are validation failures whatever error they wrap.
+func findProblemMapping(err error, overrides ...problemMapping) (problemMapping, bool) {
+	var fieldErr *model.FieldError
+	if errors.As(err, &fieldErr) {
+		return problemMapping{
+			err:        errValidationFailed,
+			httpStatus: http.StatusUnprocessableEntity,
+			grpcCode:   codes.InvalidArgument,
+			code:       codeValidationFailed,
+		}, true
+	}
+
+	for _, mappings := range [][]problemMapping{overrides, problemMappings} {
+		for _, mapping := range mappings {
+			if errors.Is(err, mapping.err) {
+				return mapping, true
+			}
+		}
+	}
+
+	return problemMapping{}, false
+}
+
+// writeProblem writes err as a problem. Errors without a mapping are logged
+// and reported as internal errors without details. Overrides let a handler
+// answer differently for its endpoint, e.g. to hide foreign wallets.
+func writeProblem(w http.ResponseWriter, r *http.Request, err error, overrides ...problemMapping) {
+	mapping, ok := findProblemMapping(err, overrides...)
+	if !ok {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("writeProblem: unexpected error",
+			zap.String("method", r.Method), zap.String("route", routePattern(r)))
+
+		mapping = problemMapping{
+			err:        errors.New("internal server error"),
+			httpStatus: http.StatusInternalServerError,
+			code:       codeInternalError,
+		}
+	}
+
+	problem := Problem{
+		Type:      problemTypePrefix + mapping.code,
+		Title:     mapping.err.Error(),
+		Status:    mapping.httpStatus,
+		Instance:  r.URL.Path,
+		Code:      mapping.code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+
+	for _, fieldErr := range fieldErrors(err) {
+		problem.Errors = append(problem.Errors, fieldProblem(fieldErr))
+	}
+
+	if errors.Is(err, errInvalidInput) {
+		problem.Detail = err.Error()
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("writeProblem/json.NewEncoder(w).Encode(problem)")
+	}
+}
+
+// fieldErrors returns the field errors err holds, one or many.
+func fieldErrors(err error) model.FieldErrors {
+	var fieldErrs model.FieldErrors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs
+	}
+
+	var fieldErr *model.FieldError
+	if errors.As(err, &fieldErr) {
+		return model.FieldErrors{fieldErr}
+	}
+
+	return nil
+}
+
+func fieldProblem(fieldErr *model.FieldError) ProblemFieldError {
+	code := codeValidationFailed
+	if mapping, ok := findProblemMapping(fieldErr.Err); ok {
+		code = mapping.code
+	}
+
+	return ProblemFieldError{
+		Field:   fieldErr.Field,
+		Code:    code,
+		Message: fieldErr.Err.Error(),
+	}
+}
+
+// hideForeignWallet reports wallets the caller may not see as missing,
+// so that their existence is not revealed.
+func hideForeignWallet(err error) error {
+	if errors.Is(err, model.ErrNotAllowed) || errors.Is(err, model.ErrNilUUID) {
+		return model.ErrWalletNotFound
+	}
+
+	return err
+}
+
+func routePattern(r *http.Request) string {
+	if routeContext := chi.RouteContext(r.Context()); routeContext != nil {
+		return routeContext.RoutePattern()
+	}
+
+	return r.URL.Path
+}
@@ -0,0 +1,99 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies.
+const streamHeartbeat = 15 * time.Second
+
+// streamWalletEvents is a Server-Sent Events stream of the caller's wallet
+// events. Event ids are outbox sequences, so a reconnecting client resumes
+// with the Last-Event-ID header.
+func (s *APIServer) streamWalletEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, r, errNoStreaming)
+
+		return
+	}
+
+	var lastEventID *int64
+
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			writeProblem(w, r, fmt.Errorf("%w: Last-Event-ID", errInvalidHeader))
+
+			return
+		}
+
+		lastEventID = &id
+	}
+
+	events, err := s.service.WalletEvents(r.Context(), lastEventID)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("streamWalletEvents/s.service.WalletEvents(r.Context(), lastEventID): %w", err))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger.FromContext(r.Context()).Debug("opened GET:/wallets/stream", zap.String("client", r.RemoteAddr))
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if err := writeEvent(w, event); err != nil {
+				logger.FromContext(r.Context()).With(zap.Error(err)).Debug("streamWalletEvents/writeEvent(w, event)")
+
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-s.shutdown:
+			return
+		case <-r.Context().Done():
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event model.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(event): %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.Type, data)
+	if err != nil {
+		return fmt.Errorf("fmt.Fprintf(...): %w", err)
+	}
+
+	return nil
+}
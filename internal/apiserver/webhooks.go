@@ -0,0 +1,114 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func (s *APIServer) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var subscription model.WebhookSubscription
+
+	if err := s.decodeJSON(w, r, &subscription); err != nil {
+		writeProblem(w, r, err)
+
+		return
+	}
+
+	created, err := s.service.CreateWebhook(r.Context(), subscription)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("createWebhook/s.service.CreateWebhook(r.Context(), subscription): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusCreated, created)
+
+	logger.FromContext(r.Context()).Debug("successful POST:/webhooks", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := s.service.GetWebhooks(r.Context())
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getWebhooks/s.service.GetWebhooks(r.Context()): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, subscriptions)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/webhooks", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	err = s.service.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("deleteWebhook/s.service.DeleteWebhook(r.Context(), id): %w", err))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	logger.FromContext(r.Context()).Debug("successful DELETE:/webhooks/{id}", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	params, err := model.ValuesToDeliveryParams(r.URL.Query())
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("getWebhookDeliveries/model.ValuesToDeliveryParams(r.URL.Query())")
+		writeProblem(w, r, errInvalidQuery)
+
+		return
+	}
+
+	deliveries, err := s.service.GetWebhookDeliveries(r.Context(), id, *params)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("getWebhookDeliveries/s.service.GetWebhookDeliveries(...): %w", err))
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, deliveries)
+
+	logger.FromContext(r.Context()).Debug("successful GET:/webhooks/{id}/deliveries", zap.String("client", r.RemoteAddr))
+}
+
+func (s *APIServer) retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, errInvalidID)
+
+		return
+	}
+
+	err = s.service.RetryWebhookDelivery(r.Context(), id)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("retryWebhookDelivery/s.service.RetryWebhookDelivery(r.Context(), id): %w", err))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	logger.FromContext(r.Context()).Debug("successful PUT:/webhooks/deliveries/{id}/retry", zap.String("client", r.RemoteAddr))
+}
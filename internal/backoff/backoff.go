@@ -0,0 +1,19 @@
+// Package backoff computes delays between retries.
+package backoff
+
+import "time"
+
+// Exponential returns the delay before the attempt following the given number
+// of failed ones: base, 2*base, 4*base, ... capped at limit.
+func Exponential(failed int, base, limit time.Duration) time.Duration {
+	delay := base
+
+	for i := 1; i < failed; i++ {
+		delay *= 2
+		if delay >= limit {
+			return limit
+		}
+	}
+
+	return delay
+}
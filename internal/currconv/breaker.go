@@ -0,0 +1,83 @@
+package currconv
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+var errCircuitOpen = fmt.Errorf("circuit breaker is open: %w", model.ErrGettingXR)
+
+// breaker stops calls to the rate server after threshold consecutive failures.
+// Once cooldown has passed a single trial call is let through: its success
+// closes the breaker, its failure opens it for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a call may be made. A non-positive threshold turns
+// the breaker off.
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return errCircuitOpen
+	}
+
+	b.trial = true
+
+	return nil
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon ends a call that neither succeeded nor failed, letting another
+// trial call through if it was one.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
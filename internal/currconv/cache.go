@@ -0,0 +1,270 @@
+package currconv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheStale = "stale"
+)
+
+type converter interface {
+	GetExchangeRate(ctx context.Context, baseCurrency, targetCurrency string) (model.XRResponse, error)
+	GetExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error)
+	Ping(ctx context.Context) error
+}
+
+type cacheMetrics interface {
+	TrackXRCache(result string)
+}
+
+// CacheConfig sets how long rates are served. A rate is fresh for TTL, then
+// served stale for up to MaxStaleness while it is refreshed in the background.
+// Once that has passed too, lookups wait for the rate server and fail with it.
+type CacheConfig struct {
+	TTL          time.Duration
+	MaxStaleness time.Duration
+
+	// Now is the clock rates are aged by, time.Now when nil.
+	Now func() time.Time
+}
+
+type cachedRate struct {
+	xr        model.XRResponse
+	fetchedAt time.Time
+}
+
+// Cache keeps exchange rates of the wrapped converter in memory. Concurrent
+// lookups of a pair that isn't cached share a single request.
+type Cache struct {
+	converter converter
+	cfg       CacheConfig
+	metrics   cacheMetrics
+
+	mu    sync.RWMutex
+	rates map[string]cachedRate
+
+	group singleflight.Group
+}
+
+func NewCache(converter converter, cfg CacheConfig, metrics cacheMetrics) *Cache {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Cache{
+		converter: converter,
+		cfg:       cfg,
+		metrics:   metrics,
+		rates:     make(map[string]cachedRate),
+	}
+}
+
+// GetExchangeRate returns the cached quote of the pair, fetching it when there
+// is none or it is too old. Caching is off when TTL isn't positive.
+func (c *Cache) GetExchangeRate(
+	ctx context.Context,
+	baseCurrency, targetCurrency string,
+) (model.XRResponse, error) {
+	if c.cfg.TTL <= 0 {
+		xr, err := c.converter.GetExchangeRate(ctx, baseCurrency, targetCurrency)
+		if err != nil {
+			return model.XRResponse{}, fmt.Errorf("c.converter.GetExchangeRate(ctx, baseCurrency, targetCurrency): %w", err)
+		}
+
+		return xr, nil
+	}
+
+	key := pairKey(baseCurrency, targetCurrency)
+
+	c.mu.RLock()
+	rate, ok := c.rates[key]
+	c.mu.RUnlock()
+
+	age := c.cfg.Now().Sub(rate.fetchedAt)
+
+	switch {
+	case ok && age < c.cfg.TTL:
+		c.metrics.TrackXRCache(CacheHit)
+
+		return rate.xr, nil
+	case ok && age < c.cfg.TTL+c.cfg.MaxStaleness:
+		c.metrics.TrackXRCache(CacheStale)
+
+		c.group.DoChan(key, c.fetch(context.WithoutCancel(ctx), key, baseCurrency, targetCurrency))
+
+		return rate.xr, nil
+	}
+
+	c.metrics.TrackXRCache(CacheMiss)
+
+	// the request is shared, so it must not end with the caller that started it
+	result := c.group.DoChan(key, c.fetch(context.WithoutCancel(ctx), key, baseCurrency, targetCurrency))
+
+	select {
+	case <-ctx.Done():
+		return model.XRResponse{}, fmt.Errorf("ctx.Done(): %w: %w", model.ErrGettingXR, ctx.Err())
+	case res := <-result:
+		if res.Err != nil {
+			return model.XRResponse{}, fmt.Errorf("c.group.DoChan(key, ...): %w", res.Err)
+		}
+
+		xr, _ := res.Val.(model.XRResponse)
+
+		return xr, nil
+	}
+}
+
+// fetch returns a lookup of the rate that caches it on success. Failures
+// aren't cached.
+func (c *Cache) fetch(ctx context.Context, key, baseCurrency, targetCurrency string) func() (any, error) {
+	return func() (any, error) {
+		xr, err := c.converter.GetExchangeRate(ctx, baseCurrency, targetCurrency)
+		if err != nil {
+			zap.L().With(zap.Error(err), zap.String("pair", key)).Debug("Cache.fetch/c.converter.GetExchangeRate(...)")
+
+			return nil, fmt.Errorf("c.converter.GetExchangeRate(ctx, baseCurrency, targetCurrency): %w", err)
+		}
+
+		c.mu.Lock()
+		c.rates[key] = cachedRate{xr: xr, fetchedAt: c.cfg.Now()}
+		c.mu.Unlock()
+
+		return xr, nil
+	}
+}
+
+// GetExchangeRates quotes pairs, taking cached rates where it can and
+// fetching the others in one batch. Stale rates are refreshed in the
+// background unless the batch is needed for a missing one anyway.
+func (c *Cache) GetExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error) {
+	if c.cfg.TTL <= 0 {
+		rates, err := c.converter.GetExchangeRates(ctx, pairs)
+		if err != nil {
+			return nil, fmt.Errorf("c.converter.GetExchangeRates(ctx, pairs): %w", err)
+		}
+
+		return rates, nil
+	}
+
+	rates := make([]model.XRResponse, len(pairs))
+	outdated := make([]model.XRPair, 0, len(pairs))
+	keys := make([]string, 0, len(pairs))
+	missing := false
+
+	c.mu.RLock()
+
+	for i, pair := range pairs {
+		key := pairKey(pair.BaseCurrency, pair.TargetCurrency)
+		rate, ok := c.rates[key]
+		age := c.cfg.Now().Sub(rate.fetchedAt)
+
+		switch {
+		case ok && age < c.cfg.TTL:
+			c.metrics.TrackXRCache(CacheHit)
+
+			rates[i] = rate.xr
+
+			continue
+		case ok && age < c.cfg.TTL+c.cfg.MaxStaleness:
+			c.metrics.TrackXRCache(CacheStale)
+
+			rates[i] = rate.xr
+		default:
+			c.metrics.TrackXRCache(CacheMiss)
+
+			missing = true
+		}
+
+		outdated = append(outdated, pair)
+		keys = append(keys, key)
+	}
+
+	c.mu.RUnlock()
+
+	if len(outdated) == 0 {
+		return rates, nil
+	}
+
+	// the request is shared, so it must not end with the caller that started it;
+	// batches are keyed apart from single lookups, which share results of
+	// another type
+	batchKey := "batch:" + strings.Join(keys, ",")
+	result := c.group.DoChan(batchKey, c.fetchBatch(context.WithoutCancel(ctx), batchKey, outdated))
+
+	if !missing {
+		return rates, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("ctx.Done(): %w: %w", model.ErrGettingXR, ctx.Err())
+	case res := <-result:
+		if res.Err != nil {
+			return nil, fmt.Errorf("c.group.DoChan(batchKey, ...): %w", res.Err)
+		}
+
+		fetched, _ := res.Val.(map[string]model.XRResponse)
+
+		for i, pair := range pairs {
+			if xr, ok := fetched[pairKey(pair.BaseCurrency, pair.TargetCurrency)]; ok {
+				rates[i] = xr
+			}
+		}
+
+		return rates, nil
+	}
+}
+
+// fetchBatch returns a lookup of the rates of pairs that caches them on
+// success, the rates are returned by pair key.
+func (c *Cache) fetchBatch(ctx context.Context, batchKey string, pairs []model.XRPair) func() (any, error) {
+	return func() (any, error) {
+		rates, err := c.converter.GetExchangeRates(ctx, pairs)
+		if err != nil {
+			zap.L().With(zap.Error(err), zap.String("pairs", batchKey)).Debug(
+				"Cache.fetchBatch/c.converter.GetExchangeRates(ctx, pairs)")
+
+			return nil, fmt.Errorf("c.converter.GetExchangeRates(ctx, pairs): %w", err)
+		}
+
+		fetched := make(map[string]model.XRResponse, len(pairs))
+		fetchedAt := c.cfg.Now()
+
+		c.mu.Lock()
+
+		for i, pair := range pairs {
+			key := pairKey(pair.BaseCurrency, pair.TargetCurrency)
+
+			fetched[key] = rates[i]
+			c.rates[key] = cachedRate{xr: rates[i], fetchedAt: fetchedAt}
+		}
+
+		c.mu.Unlock()
+
+		return fetched, nil
+	}
+}
+
+// Ping checks the wrapped converter, cached rates don't make it ready.
+func (c *Cache) Ping(ctx context.Context) error {
+	if err := c.converter.Ping(ctx); err != nil {
+		return fmt.Errorf("c.converter.Ping(ctx): %w", err)
+	}
+
+	return nil
+}
+
+func pairKey(baseCurrency, targetCurrency string) string {
+	return baseCurrency + "/" + targetCurrency
+}
@@ -1,14 +1,29 @@
 package currconv
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/Saaghh/wallet/internal/apiserver"
+	"github.com/Saaghh/wallet/internal/backoff"
+	"github.com/Saaghh/wallet/internal/logger"
 	"github.com/Saaghh/wallet/internal/model"
+	"github.com/Saaghh/wallet/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -16,60 +31,385 @@ type metrics interface {
 	TrackExternalRequest(start time.Time, endpoint string)
 }
 
+// Config describes a rate server and tunes its client. URL is the full
+// address of the rate endpoint, AuthHeader with AuthToken is sent with every
+// lookup when set. Timeout bounds a single attempt, failed lookups are
+// retried up to RetryAttempts times in total.
+type Config struct {
+	URL        string
+	AuthHeader string
+	AuthToken  string
+	TLS        TLSConfig
+
+	Timeout          time.Duration
+	RetryAttempts    int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// TLSConfig is used for https rate servers. CAFile replaces the system roots,
+// CertFile and KeyFile are the client certificate for mutual TLS.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
 type RemoteCurrencyConverter struct {
 	XRAddress string
+	cfg       Config
+	client    *http.Client
+	breaker   *breaker
 	metrics   metrics
 }
 
-const xrEndpoint string = "/xr"
+// errRetryable marks failures worth another attempt: the rate server
+// couldn't be reached or was overloaded.
+var errRetryable = errors.New("retryable")
+
+var (
+	errInvalidURL     = errors.New("rate server url must be an absolute http(s) url")
+	errNoCertificates = errors.New("no certificates found")
+)
+
+func New(cfg Config, metrics metrics) (*RemoteCurrencyConverter, error) {
+	xrURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse(cfg.URL): %w", err)
+	}
+
+	if (xrURL.Scheme != "http" && xrURL.Scheme != "https") || xrURL.Host == "" {
+		return nil, fmt.Errorf("%q: %w", cfg.URL, errInvalidURL)
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("newTLSConfig(cfg.TLS): %w", err)
+	}
+
+	// the rate server is called on every conversion, keep connections to it warm
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: cfg.Timeout, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+	}
 
-func New(xrBindAddr string, metrics metrics) *RemoteCurrencyConverter {
 	return &RemoteCurrencyConverter{
-		XRAddress: "http://localhost" + xrBindAddr + xrEndpoint,
+		XRAddress: xrURL.String(),
+		cfg:       cfg,
+		client:    &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		breaker:   newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
 		metrics:   metrics,
+	}, nil
+}
+
+// Name identifies the rate server in logs and metrics. Credentials and
+// query, which may carry them too, are left out.
+func (c *RemoteCurrencyConverter) Name() string {
+	xrURL, err := url.Parse(c.XRAddress)
+	if err != nil {
+		return c.XRAddress
 	}
+
+	xrURL.User, xrURL.RawQuery = nil, ""
+
+	return xrURL.String()
 }
 
-func (c *RemoteCurrencyConverter) GetExchangeRate(baseCurrency, targetCurrency string) (float64, error) {
-	defer c.metrics.TrackExternalRequest(time.Now(), c.XRAddress)
+func newTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	//nolint: gosec
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
 
-	queryParams := fmt.Sprintf("?base=%s&target=%s", baseCurrency, targetCurrency)
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile(cfg.CAFile): %w", err)
+		}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%s: %w", cfg.CAFile, errNoCertificates)
+		}
+	}
 
-	req, err := http.NewRequestWithContext(
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile): %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// GetExchangeRate asks the rate server for the quote of how much of
+// targetCurrency one unit of baseCurrency is worth. Lookups are idempotent,
+// so failures to reach the server are retried with jittered backoff. While
+// the server keeps failing the breaker is open and lookups fail at once with
+// model.ErrGettingXR.
+func (c *RemoteCurrencyConverter) GetExchangeRate(
+	ctx context.Context,
+	baseCurrency, targetCurrency string,
+) (model.XRResponse, error) {
+	ctx, span := tracing.Child(
+		ctx,
+		"currconv.GetExchangeRate",
+		trace.WithAttributes(
+			attribute.String("xr.provider", c.Name()),
+			attribute.String("xr.base", baseCurrency),
+			attribute.String("xr.target", targetCurrency),
+		))
+
+	xr, err := c.getExchangeRate(ctx, baseCurrency, targetCurrency)
+
+	tracing.End(span, err)
+
+	return xr, err
+}
+
+func (c *RemoteCurrencyConverter) getExchangeRate(
+	ctx context.Context,
+	baseCurrency, targetCurrency string,
+) (model.XRResponse, error) {
+	xrURL, err := url.Parse(c.XRAddress)
+	if err != nil {
+		return model.XRResponse{}, fmt.Errorf("url.Parse(c.XRAddress): %w", err)
+	}
+
+	query := xrURL.Query()
+	query.Set("base", baseCurrency)
+	query.Set("target", targetCurrency)
+	xrURL.RawQuery = query.Encode()
+
+	var xrResponse model.XRResponse
+
+	err = c.withRetries(ctx, func() error {
+		return c.do(ctx, http.MethodGet, xrURL.String(), nil, &xrResponse)
+	})
+	if err != nil {
+		return model.XRResponse{}, err
+	}
+
+	return normalize(xrResponse)
+}
+
+// GetExchangeRates quotes all pairs in a single request to the batch
+// endpoint next to the rate endpoint, all from the same rates. It fails as a
+// whole when a currency is unknown.
+func (c *RemoteCurrencyConverter) GetExchangeRates(
+	ctx context.Context,
+	pairs []model.XRPair,
+) ([]model.XRResponse, error) {
+	ctx, span := tracing.Child(
 		ctx,
-		http.MethodGet,
-		c.XRAddress+queryParams,
-		nil)
+		"currconv.GetExchangeRates",
+		trace.WithAttributes(
+			attribute.String("xr.provider", c.Name()),
+			attribute.Int("xr.pairs", len(pairs)),
+		))
+
+	rates, err := c.getExchangeRates(ctx, pairs)
+
+	tracing.End(span, err)
+
+	return rates, err
+}
+
+func (c *RemoteCurrencyConverter) getExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error) {
+	xrURL, err := url.Parse(c.XRAddress)
 	if err != nil {
-		return 0, fmt.Errorf("server.NewRequestWithContext(...): %w", err)
+		return nil, fmt.Errorf("url.Parse(c.XRAddress): %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	xrURL.Path = strings.TrimSuffix(xrURL.Path, "/") + "/batch"
+
+	body, err := json.Marshal(model.XRBatchRequest{Pairs: pairs})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(...): %w", err)
+	}
+
+	var batchResponse model.XRBatchResponse
+
+	err = c.withRetries(ctx, func() error {
+		return c.do(ctx, http.MethodPost, xrURL.String(), body, &batchResponse)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(batchResponse.Rates) != len(pairs) {
+		return nil, fmt.Errorf("%d rates for %d pairs: %w", len(batchResponse.Rates), len(pairs), model.ErrGettingXR)
+	}
+
+	for i := range batchResponse.Rates {
+		if batchResponse.Rates[i], err = normalize(batchResponse.Rates[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return batchResponse.Rates, nil
+}
+
+// Ping checks that the rate server is up and ready to quote, asking the
+// readiness endpoint next to the rate endpoint, so that any path prefix a
+// gateway routes by is kept. It is a single attempt past the breaker, so that
+// probes don't keep it open.
+func (c *RemoteCurrencyConverter) Ping(ctx context.Context) error {
+	xrURL, err := url.Parse(c.XRAddress)
+	if err != nil {
+		return fmt.Errorf("url.Parse(c.XRAddress): %w", err)
+	}
+
+	xrURL.Path, xrURL.RawPath, xrURL.RawQuery = path.Join("/", path.Dir(xrURL.Path), "readyz"), "", ""
+
+	if err = c.do(ctx, http.MethodGet, xrURL.String(), nil, nil); err != nil {
+		return fmt.Errorf("c.do(...): %w", err)
+	}
+
+	return nil
+}
+
+// withRetries makes attempts while they fail with errRetryable, as long as
+// the breaker allows. Only quotes and answers about unknown currencies count
+// as the server working: a server that answers with nonsense is as broken as
+// one that doesn't answer.
+func (c *RemoteCurrencyConverter) withRetries(ctx context.Context, attempt func() error) error {
+	var err error
+
+	for attempts := 1; ; attempts++ {
+		if err = c.breaker.allow(); err != nil {
+			return err
+		}
+
+		err = attempt()
+
+		switch {
+		case err == nil, errors.Is(err, model.ErrWrongCurrency):
+			c.breaker.success()
+
+			return err
+		case ctx.Err() != nil:
+			// the caller gave up, that says nothing about the server
+			c.breaker.abandon()
+
+			return fmt.Errorf("ctx.Done(): %w: %w", model.ErrGettingXR, ctx.Err())
+		case !errors.Is(err, errRetryable):
+			c.breaker.failure()
+
+			return err
+		}
+
+		c.breaker.failure()
+
+		if attempts >= c.cfg.RetryAttempts {
+			break
+		}
+
+		delay := backoff.Exponential(attempts, c.cfg.RetryBaseDelay, c.cfg.RetryMaxDelay)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("ctx.Done(): %w: %w", model.ErrGettingXR, ctx.Err())
+		case <-time.After(jitter(delay)):
+		}
+	}
+
+	logger.FromContext(ctx).With(zap.Error(err), zap.String("provider", c.Name())).Warn("withRetries/attempt()")
+
+	return err
+}
+
+// do makes a single request and decodes the data of the response into dest.
+// Failures to get a rate are model.ErrGettingXR, those worth a retry are
+// errRetryable as well.
+func (c *RemoteCurrencyConverter) do(ctx context.Context, method, target string, body []byte, dest any) error {
+	defer c.metrics.TrackExternalRequest(time.Now(), c.Name())
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext(...): %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	tracing.Inject(req)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.cfg.AuthHeader != "" {
+		req.Header.Set(c.cfg.AuthHeader, c.cfg.AuthToken)
+	}
+
+	resp, err := c.client.Do(req)
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return fmt.Errorf("c.client.Do(req): %w: %w", model.ErrGettingXR, err)
+	case err != nil:
+		return fmt.Errorf("c.client.Do(req): %w: %w: %w", model.ErrGettingXR, errRetryable, err)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
 	defer func() {
-		err = resp.Body.Close()
+		err := resp.Body.Close()
 		if err != nil {
-			zap.L().With(zap.Error(err)).Warn("GetExchangeRate/resp.Body.Close()")
+			zap.L().With(zap.Error(err)).Warn("do/resp.Body.Close()")
 		}
 	}()
 
-	switch resp.StatusCode {
-	case http.StatusBadRequest:
-		return 0, model.ErrWrongCurrency
-	case http.StatusInternalServerError:
-		return 0, model.ErrGettingXR
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		break
+	case resp.StatusCode == http.StatusBadRequest:
+		return model.ErrWrongCurrency
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("status %d: %w: %w", resp.StatusCode, model.ErrGettingXR, errRetryable)
+	default:
+		return fmt.Errorf("status %d: %w", resp.StatusCode, model.ErrGettingXR)
 	}
 
-	var xrResponse model.XRResponse
-
-	err = json.NewDecoder(resp.Body).Decode(&apiserver.HTTPResponse{Data: &xrResponse})
+	err = json.NewDecoder(resp.Body).Decode(&apiserver.HTTPResponse{Data: dest})
 	if err != nil {
-		return 0, fmt.Errorf("json.NewDecoder(resp.Body).Decode(...): %w", err)
+		return fmt.Errorf("json.NewDecoder(resp.Body).Decode(...): %w: %w", model.ErrGettingXR, err)
+	}
+
+	return nil
+}
+
+// normalize checks a quote, quoting the mid-rate on both sides when the
+// server has no spreads.
+func normalize(xrResponse model.XRResponse) (model.XRResponse, error) {
+	if xrResponse.Bid == 0 && xrResponse.Ask == 0 {
+		xrResponse.Bid, xrResponse.Ask = xrResponse.XR, xrResponse.XR
+	}
+
+	if xrResponse.Bid <= 0 || xrResponse.Bid > xrResponse.XR || xrResponse.Ask < xrResponse.XR {
+		return model.XRResponse{}, fmt.Errorf("quote %+v: %w", xrResponse, model.ErrGettingXR)
+	}
+
+	return xrResponse, nil
+}
+
+// jitter spreads retries of concurrent lookups over [delay/2, delay).
+func jitter(delay time.Duration) time.Duration {
+	if delay < 2 {
+		return delay
 	}
 
-	return xrResponse.XR, nil
+	//nolint: gosec
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
 }
@@ -0,0 +1,235 @@
+package currconv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	ProviderOK        = "ok"
+	ProviderError     = "error"
+	ProviderDeviation = "deviation"
+)
+
+var errRateDeviation = fmt.Errorf("rate providers disagree: %w", model.ErrGettingXR)
+
+type Provider interface {
+	converter
+	Name() string
+}
+
+type providerMetrics interface {
+	TrackXRProvider(provider, result string)
+}
+
+// Failover asks rate providers in order and returns the rates of the first
+// one that answers, an unknown currency included. With a positive maxDeviation the rates are cross-checked
+// against the next provider that answers and rejected when any differ by
+// more than that fraction.
+type Failover struct {
+	providers    []Provider
+	maxDeviation float64
+	metrics      providerMetrics
+}
+
+// NewProviders returns a rate server client for each of urls, all set up
+// with cfg otherwise.
+func NewProviders(urls []string, cfg Config, metrics metrics) ([]Provider, error) {
+	providers := make([]Provider, 0, len(urls))
+
+	for _, providerURL := range urls {
+		cfg.URL = providerURL
+
+		provider, err := New(cfg, metrics)
+		if err != nil {
+			return nil, fmt.Errorf("New(cfg, metrics): %w", err)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+func NewFailover(providers []Provider, maxDeviation float64, metrics providerMetrics) *Failover {
+	return &Failover{
+		providers:    providers,
+		maxDeviation: maxDeviation,
+		metrics:      metrics,
+	}
+}
+
+type providerRate struct {
+	xr  []model.XRResponse
+	err error
+}
+
+// lookup gets quotes from a provider.
+type lookup func(ctx context.Context, provider Provider) ([]model.XRResponse, error)
+
+func (f *Failover) GetExchangeRate(
+	ctx context.Context,
+	baseCurrency, targetCurrency string,
+) (model.XRResponse, error) {
+	rates, err := f.query(ctx, func(ctx context.Context, provider Provider) ([]model.XRResponse, error) {
+		xr, err := provider.GetExchangeRate(ctx, baseCurrency, targetCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("provider.GetExchangeRate(ctx, baseCurrency, targetCurrency): %w", err)
+		}
+
+		return []model.XRResponse{xr}, nil
+	})
+	if err != nil {
+		return model.XRResponse{}, err
+	}
+
+	return rates[0], nil
+}
+
+func (f *Failover) GetExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error) {
+	return f.query(ctx, func(ctx context.Context, provider Provider) ([]model.XRResponse, error) {
+		rates, err := provider.GetExchangeRates(ctx, pairs)
+		if err != nil {
+			return nil, fmt.Errorf("provider.GetExchangeRates(ctx, pairs): %w", err)
+		}
+
+		return rates, nil
+	})
+}
+
+// Ping succeeds when any of the providers is ready.
+func (f *Failover) Ping(ctx context.Context) error {
+	errs := make([]error, 0, len(f.providers))
+
+	for _, provider := range f.providers {
+		err := provider.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+
+	return fmt.Errorf("no rate provider is ready: %w", errors.Join(errs...))
+}
+
+func (f *Failover) query(ctx context.Context, get lookup) ([]model.XRResponse, error) {
+	if f.maxDeviation > 0 {
+		return f.crossChecked(ctx, get)
+	}
+
+	rates := make([]providerRate, 0, len(f.providers))
+
+	for _, provider := range f.providers {
+		xr, err := f.ask(ctx, provider, get)
+		if err == nil || errors.Is(err, model.ErrWrongCurrency) {
+			return xr, err
+		}
+
+		rates = append(rates, providerRate{err: err})
+	}
+
+	return nil, failoverError(rates)
+}
+
+// crossChecked asks all providers at once, so that checking the rates costs
+// no more time than the slowest of them. Mid-rates are compared, spreads are
+// up to each provider.
+func (f *Failover) crossChecked(ctx context.Context, get lookup) ([]model.XRResponse, error) {
+	rates := make([]providerRate, len(f.providers))
+
+	var wg sync.WaitGroup
+
+	for i, provider := range f.providers {
+		wg.Add(1)
+
+		go func(i int, provider Provider) {
+			defer wg.Done()
+
+			rates[i].xr, rates[i].err = f.ask(ctx, provider, get)
+		}(i, provider)
+	}
+
+	wg.Wait()
+
+	primary, reference := -1, -1
+
+	for i, rate := range rates {
+		switch {
+		case primary == -1 && errors.Is(rate.err, model.ErrWrongCurrency):
+			return nil, rate.err
+		case rate.err != nil:
+			continue
+		case primary == -1:
+			primary = i
+		case reference == -1:
+			reference = i
+		}
+	}
+
+	switch {
+	case primary == -1:
+		return nil, failoverError(rates)
+	case reference == -1:
+		logger.FromContext(ctx).Warn("Failover.crossChecked: no provider to cross-check with",
+			zap.String("provider", f.providers[primary].Name()))
+
+		return rates[primary].xr, nil
+	}
+
+	xr, referenceXR := rates[primary].xr, rates[reference].xr
+
+	for i := range xr {
+		if math.Abs(xr[i].XR-referenceXR[i].XR)/referenceXR[i].XR <= f.maxDeviation {
+			continue
+		}
+
+		f.metrics.TrackXRProvider(f.providers[primary].Name(), ProviderDeviation)
+
+		logger.FromContext(ctx).Warn("Failover.crossChecked: rates deviate",
+			zap.String("provider", f.providers[primary].Name()),
+			zap.Float64("xr", xr[i].XR),
+			zap.String("referenceProvider", f.providers[reference].Name()),
+			zap.Float64("referenceXR", referenceXR[i].XR))
+
+		return nil, errRateDeviation
+	}
+
+	return xr, nil
+}
+
+// ask gets the rates from provider, tracking its health. Unknown currencies
+// are answers too, the provider is up.
+func (f *Failover) ask(ctx context.Context, provider Provider, get lookup) ([]model.XRResponse, error) {
+	xr, err := get(ctx, provider)
+
+	switch {
+	case err == nil, errors.Is(err, model.ErrWrongCurrency):
+		f.metrics.TrackXRProvider(provider.Name(), ProviderOK)
+	default:
+		f.metrics.TrackXRProvider(provider.Name(), ProviderError)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get(ctx, provider): %w", err)
+	}
+
+	return xr, nil
+}
+
+func failoverError(rates []providerRate) error {
+	errs := make([]error, 0, len(rates))
+
+	for _, rate := range rates {
+		errs = append(errs, rate.err)
+	}
+
+	return fmt.Errorf("all rate providers failed: %w: %w", model.ErrGettingXR, errors.Join(errs...))
+}
@@ -0,0 +1,82 @@
+// Package eventsink is a local stand-in for downstream consumers of the
+// outbox HTTP publisher. It logs every event it receives.
+package eventsink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	router *chi.Mux
+	server *http.Server
+}
+
+func New(bindAddr string) *Server {
+	router := chi.NewRouter()
+
+	return &Server{
+		router: router,
+		server: &http.Server{
+			Addr:              bindAddr,
+			ReadHeaderTimeout: 5 * time.Second,
+			Handler:           router,
+		},
+	}
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	s.router.Post("/events", s.handleEvents)
+
+	go func() {
+		<-ctx.Done()
+
+		gfCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		//nolint: contextcheck
+		if err := s.server.Shutdown(gfCtx); err != nil {
+			zap.L().With(zap.Error(err)).Warn("failed to gracefully shutdown server")
+
+			return
+		}
+	}()
+
+	zap.L().Info("event sink starting", zap.String("port", s.server.Addr))
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("s.server.ListenAndServe(): %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var events []model.Event
+
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	for _, event := range events {
+		zap.L().Info(
+			"event received",
+			zap.Int64("sequence", event.Sequence),
+			zap.String("type", event.Type),
+			zap.Int("version", event.Version),
+			zap.String("aggregateId", event.AggregateID.String()),
+			zap.ByteString("payload", event.Payload))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -38,6 +38,7 @@ func (j *JWTGenerator) GetNewTokenString(user model.User) (string, error) {
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		UUID: user.ID,
+		Role: user.Role,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS512, claims)
@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"context"
 	"os"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -25,3 +27,16 @@ func InitLogger(cfg Config) {
 
 	zap.L().Info("successful logger initialization")
 }
+
+// FromContext returns the logger with the ids of the span in ctx, so that
+// entries logged while serving a request can be found from its trace.
+func FromContext(ctx context.Context) *zap.Logger {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return zap.L()
+	}
+
+	return zap.L().With(
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()))
+}
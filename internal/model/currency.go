@@ -0,0 +1,45 @@
+package model
+
+import (
+	"math"
+	"strings"
+)
+
+const MaxCurrencyMinorUnits = 4
+
+// Currency is an entry of the currency registry. Disabled currencies keep
+// their wallets working, but no new wallets can be opened in them.
+type Currency struct {
+	Code        string `json:"code"`
+	NumericCode int    `json:"numericCode,omitempty"`
+	MinorUnits  int    `json:"minorUnits"`
+	Symbol      string `json:"symbol"`
+	IsEnabled   bool   `json:"isEnabled"`
+}
+
+func (c *Currency) Validate() error {
+	v := validation{}
+
+	v.add("code", ValidateCurrency(c.Code))
+	v.check(c.NumericCode >= 0 && c.NumericCode <= 999, "numericCode", ErrOutOfRange)
+	v.check(c.MinorUnits >= 0 && c.MinorUnits <= MaxCurrencyMinorUnits, "minorUnits", ErrOutOfRange)
+	v.check(strings.TrimSpace(c.Symbol) != "", "symbol", ErrRequired)
+
+	return v.err()
+}
+
+// ValidateAmount checks that sum has no more decimal places than the
+// currency's minor units.
+func (c *Currency) ValidateAmount(sum float64) error {
+	if err := ValidateAmount(sum); err != nil {
+		return err
+	}
+
+	// amounts are float64, allow for their representation error
+	scaled := sum * math.Pow10(c.MinorUnits)
+	if math.Abs(scaled-math.Round(scaled)) > 1e-6 {
+		return ErrAmountPrecision
+	}
+
+	return nil
+}
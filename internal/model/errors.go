@@ -20,4 +20,43 @@ var (
 	ErrNotAllowed           = errors.New("not allowed")
 	ErrUserInfoNotOk        = errors.New("user info type assertion not ok")
 	ErrGettingXR            = errors.New("error getting xr")
+	ErrEmptyReason          = errors.New("reason can't be empty")
+	ErrWalletFrozen         = errors.New("wallet is frozen")
+	ErrInvalidStatus        = errors.New("invalid wallet status")
+	ErrStatusTransition     = errors.New("wallet status transition not allowed")
+	ErrNonZeroBalance       = errors.New("wallet balance is not zero")
+	ErrSameWallet           = errors.New("source and target wallets are the same")
+	ErrUnknownPublisher     = errors.New("unknown event publisher")
+	ErrPublishingEvents     = errors.New("error publishing events")
+	ErrWebhookNotFound      = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL    = errors.New("webhook url must be an absolute http(s) url")
+	ErrUnknownEvent         = errors.New("unknown event")
+	ErrEmptyName            = errors.New("name can't be empty")
+	ErrNameTooLong          = errors.New("name is too long")
+	ErrInvalidName          = errors.New("name contains control characters")
+	ErrInvalidAmount        = errors.New("amount must be a finite number")
+	ErrAmountPrecision      = errors.New("amount has more decimal places than the currency allows")
+	ErrUnknownField         = errors.New("unknown field")
+	ErrRequired             = errors.New("value is required")
+	ErrOutOfRange           = errors.New("value is out of range")
+	ErrCurrencyNotFound     = errors.New("currency not found")
+	ErrCurrencyDisabled     = errors.New("currency is disabled")
+	ErrDuplicateNumericCode = errors.New("numeric code belongs to another currency")
+	ErrInvalidRate          = errors.New("rate must be a positive finite number")
+	ErrInvalidSorting       = errors.New("can't sort by this column")
 )
+
+// FieldError ties a validation error to the request field that caused it.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventWalletCreated     = "WalletCreated"
+	EventFundsDeposited    = "FundsDeposited"
+	EventFundsWithdrawn    = "FundsWithdrawn"
+	EventTransferCompleted = "TransferCompleted"
+	EventWalletClosed      = "WalletClosed"
+	EventBalanceAdjusted   = "BalanceAdjusted"
+	EventStatusChanged     = "WalletStatusChanged"
+)
+
+// EventSchemaVersion is the payload schema version written for new events.
+// Payload fields are only ever added within a version; anything else needs a new one.
+const EventSchemaVersion = 1
+
+// Event is the envelope every domain event is published in.
+type Event struct {
+	ID          uuid.UUID       `json:"id"`
+	Sequence    int64           `json:"sequence"`
+	Type        string          `json:"type"`
+	Version     int             `json:"version"`
+	AggregateID uuid.UUID       `json:"aggregateId"`
+	OccurredAt  time.Time       `json:"occurredAt"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type WalletCreatedV1 struct {
+	WalletID uuid.UUID `json:"walletId"`
+	OwnerID  uuid.UUID `json:"ownerId"`
+	Currency string    `json:"currency"`
+	Name     string    `json:"name"`
+}
+
+// FundsMovedV1 is the payload of both FundsDeposited and FundsWithdrawn.
+// Amount is always positive and in the wallet currency.
+type FundsMovedV1 struct {
+	TransactionID uuid.UUID `json:"transactionId"`
+	WalletID      uuid.UUID `json:"walletId"`
+	Currency      string    `json:"currency"`
+	Amount        float64   `json:"amount"`
+	Balance       float64   `json:"balance"`
+}
+
+type TransferCompletedV1 struct {
+	TransactionID uuid.UUID `json:"transactionId"`
+	FromWalletID  uuid.UUID `json:"fromWalletId"`
+	ToWalletID    uuid.UUID `json:"toWalletId"`
+	Currency      string    `json:"currency"`
+	Sum           float64   `json:"sum"`
+	SumWithdrawn  float64   `json:"sumWithdrawn"`
+	SumDeposited  float64   `json:"sumDeposited"`
+	FromBalance   float64   `json:"fromBalance"`
+	ToBalance     float64   `json:"toBalance"`
+	FromCurrency  string    `json:"fromCurrency"`
+	ToCurrency    string    `json:"toCurrency"`
+}
+
+type WalletClosedV1 struct {
+	WalletID       uuid.UUID  `json:"walletId"`
+	Currency       string     `json:"currency"`
+	SweptTo        *uuid.UUID `json:"sweptTo,omitempty"`
+	SweptAmount    float64    `json:"sweptAmount"`
+	ConversionRate float64    `json:"conversionRate"`
+}
+
+// BalanceAdjustedV1 is a manual correction made by support. Sum is signed
+// and in the wallet currency.
+type BalanceAdjustedV1 struct {
+	TransactionID uuid.UUID `json:"transactionId"`
+	WalletID      uuid.UUID `json:"walletId"`
+	Currency      string    `json:"currency"`
+	Sum           float64   `json:"sum"`
+	Balance       float64   `json:"balance"`
+	Reason        string    `json:"reason"`
+}
+
+type WalletStatusChangedV1 struct {
+	WalletID       uuid.UUID    `json:"walletId"`
+	Status         WalletStatus `json:"status"`
+	PreviousStatus WalletStatus `json:"previousStatus"`
+	Reason         string       `json:"reason"`
+}
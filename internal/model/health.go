@@ -0,0 +1,20 @@
+package model
+
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+	HealthStopping    = "stopping"
+)
+
+// DependencyHealth is the outcome of checking one dependency. The reason of
+// a failure is logged, not reported, readiness is probed unauthenticated.
+type DependencyHealth struct {
+	Status string `json:"status"`
+}
+
+// Readiness tells whether the server can take traffic. It is ok only when
+// every dependency is.
+type Readiness struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies,omitempty"`
+}
@@ -2,7 +2,9 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"net/url"
+	"reflect"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,35 +15,154 @@ import (
 type ctxKey string
 
 const (
-	UserInfoKey  ctxKey = "userInfo"
-	StandardPage int    = 10
+	UserInfoKey    ctxKey = "userInfo"
+	RequestInfoKey ctxKey = "requestInfo"
+	StandardPage   int    = 10
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	AuditActionSearchUsers      = "users.search"
+	AuditActionViewWallets      = "wallets.view"
+	AuditActionViewTransactions = "transactions.view"
+	AuditActionChangeStatus     = "wallet.changeStatus"
+	AuditActionAdjustBalance    = "wallet.adjustBalance"
+	AuditActionCreateWallet     = "wallet.create"
+	AuditActionUpdateWallet     = "wallet.update"
+	AuditActionCloseWallet      = "wallet.close"
+	AuditActionArchiveWallet    = "wallet.archive"
+	AuditActionTransferOut      = "wallet.transferOut"
+	AuditActionTransferIn       = "wallet.transferIn"
+	AuditActionDeposit          = "wallet.deposit"
+	AuditActionWithdraw         = "wallet.withdraw"
+	AuditActionUpsertCurrency   = "currency.upsert"
+	AuditEntityUser             = "user"
+	AuditEntityWallet           = "wallet"
+	AuditEntityCurrency         = "currency"
 )
 
 type Wallet struct {
-	ID           uuid.UUID `json:"id"`
-	OwnerID      uuid.UUID `json:"ownerId"`
-	Currency     string    `json:"currency"`
-	Balance      float64   `json:"balance"`
-	CreatedDate  time.Time `json:"createdDate"`
-	ModifiedDate time.Time `json:"modifiedDate"`
-	Name         string    `json:"name"`
+	ID           uuid.UUID    `json:"id"`
+	OwnerID      uuid.UUID    `json:"ownerId"`
+	Currency     string       `json:"currency"`
+	Balance      float64      `json:"balance"`
+	CreatedDate  time.Time    `json:"createdDate"`
+	ModifiedDate time.Time    `json:"modifiedDate"`
+	Name         string       `json:"name"`
+	Status       WalletStatus `json:"status"`
+	StatusReason string       `json:"statusReason,omitempty"`
+}
+
+// NewWallet is the body of a wallet creation request, everything else
+// about the wallet is assigned by the server.
+type NewWallet struct {
+	Currency string `json:"currency"`
+	Name     string `json:"name"`
+}
+
+func (w *NewWallet) Validate() error {
+	v := validation{}
+
+	v.add("currency", ValidateCurrency(w.Currency))
+	v.add("name", ValidateWalletName(w.Name))
+
+	return v.err()
+}
+
+type WalletStatus string
+
+const (
+	WalletStatusActive      WalletStatus = "active"
+	WalletStatusFrozenDebit WalletStatus = "frozen_debit"
+	WalletStatusFrozen      WalletStatus = "frozen"
+	WalletStatusClosed      WalletStatus = "closed"
+	WalletStatusArchived    WalletStatus = "archived"
+)
+
+// walletStatusTransitions lists the statuses a wallet may be moved to from each status.
+// Closing is done only through wallet deletion, so closed is never a target here.
+var walletStatusTransitions = map[WalletStatus][]WalletStatus{
+	WalletStatusActive:      {WalletStatusFrozenDebit, WalletStatusFrozen, WalletStatusArchived},
+	WalletStatusFrozenDebit: {WalletStatusActive, WalletStatusFrozen},
+	WalletStatusFrozen:      {WalletStatusActive, WalletStatusFrozenDebit},
+	WalletStatusArchived:    {WalletStatusActive},
+}
+
+func (s WalletStatus) IsValid() bool {
+	switch s {
+	case WalletStatusActive, WalletStatusFrozenDebit, WalletStatusFrozen, WalletStatusClosed, WalletStatusArchived:
+		return true
+	}
+
+	return false
+}
+
+// CanDebit reports whether money can be taken from a wallet in this status.
+func (s WalletStatus) CanDebit() bool {
+	return s == WalletStatusActive
+}
+
+// CanCredit reports whether money can be put into a wallet in this status.
+func (s WalletStatus) CanCredit() bool {
+	return s == WalletStatusActive || s == WalletStatusFrozenDebit
+}
+
+func (s WalletStatus) CanTransitionTo(target WalletStatus) bool {
+	for _, status := range walletStatusTransitions[s] {
+		if status == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+type WalletStatusRequest struct {
+	Status WalletStatus `json:"status"`
+	Reason string       `json:"reason"`
+}
+
+func (r *WalletStatusRequest) Validate() error {
+	v := validation{}
+
+	v.check(r.Status.IsValid(), "status", ErrInvalidStatus)
+	v.check(r.Reason != "", "reason", ErrEmptyReason)
+
+	return v.err()
 }
 
 type User struct {
 	ID      uuid.UUID `json:"id"`
 	Email   string    `json:"email"`
+	Role    string    `json:"role"`
 	RegDate time.Time `json:"regDate"`
 }
 
 type Transaction struct {
-	ID             uuid.UUID  `json:"id"`
-	CreatedAt      time.Time  `json:"createdAt"`
-	AgentWalletID  *uuid.UUID `json:"agentWalletId,omitempty"`
-	TargetWalletID *uuid.UUID `json:"targetWalletId,omitempty"`
-	Currency       string     `json:"currency"`
-	Sum            float64    `json:"sum"`
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	AgentWalletID  *uuid.UUID      `json:"agentWalletId,omitempty"`
+	TargetWalletID *uuid.UUID      `json:"targetWalletId,omitempty"`
+	Currency       string          `json:"currency"`
+	Sum            float64         `json:"sum"`
+	Type           TransactionType `json:"type,omitempty"`
 }
 
+type TransactionType string
+
+const (
+	TransactionTypeTransfer   TransactionType = "transfer"
+	TransactionTypeDeposit    TransactionType = "deposit"
+	TransactionTypeWithdrawal TransactionType = "withdrawal"
+	TransactionTypeAdjustment TransactionType = "adjustment"
+	TransactionTypeSweep      TransactionType = "sweep"
+	TransactionTypeClose      TransactionType = "close"
+)
+
 type Transfer struct {
 	ID            uuid.UUID
 	CreatedAt     time.Time
@@ -49,32 +170,105 @@ type Transfer struct {
 	SumToWithdraw float64
 	TargetWallet  *Wallet
 	SumToDeposit  float64
+	SpreadIncome  []SpreadIncome
+}
+
+// SpreadIncome is what converting money for a wallet earned over the
+// mid-rate, in the wallet's currency.
+type SpreadIncome struct {
+	WalletID uuid.UUID
+	Currency string
+	Amount   float64
+}
+
+// SpreadIncomeReport sums the spread income in a currency.
+type SpreadIncomeReport struct {
+	Currency    string  `json:"currency"`
+	Amount      float64 `json:"amount"`
+	Conversions int     `json:"conversions"`
+}
+
+// ReportParams limit a report to the period from From to To. Zero bounds
+// are open.
+type ReportParams struct {
+	From time.Time `schema:"from"`
+	To   time.Time `schema:"to"`
+}
+
+func ValuesToReportParams(values url.Values) (*ReportParams, error) {
+	decoder := newDecoder()
+
+	params := &ReportParams{}
+
+	err := decoder.Decode(params, values)
+	if err != nil {
+		return nil, fmt.Errorf("decoder.Decode(params, values): %w", err)
+	}
+
+	return params, nil
+}
+
+// WalletClosure describes closing a wallet. A non-zero balance is moved
+// to SweepTo at ConversionRate, earning SpreadRate per unit, or the closure
+// is refused when SweepTo is nil.
+type WalletClosure struct {
+	WalletID           uuid.UUID
+	Currency           string
+	SweepTo            *uuid.UUID
+	SweepToCurrency    string
+	ConversionRate     float64
+	SpreadRate         float64
+	SweepTransactionID uuid.UUID
+	CloseTransactionID uuid.UUID
 }
 
 type UpdateWalletRequest struct {
-	Name           *string `json:"name,omitempty"`
-	Currency       *string `json:"currency,omitempty"`
-	ConversionRate float64 `json:"conversionRate,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Currency *string `json:"currency,omitempty"`
+	// the rates are quoted from FromCurrency, the wallet's when the request
+	// was checked
+	FromCurrency   string  `json:"-"`
+	ConversionRate float64 `json:"-"`
+	SpreadRate     float64 `json:"-"`
+}
+
+func (r *UpdateWalletRequest) Validate() error {
+	v := validation{}
+
+	if r.Name != nil {
+		v.add("name", ValidateWalletName(*r.Name))
+	}
+
+	if r.Currency != nil {
+		v.add("currency", ValidateCurrency(*r.Currency))
+	}
+
+	return v.err()
 }
 
 func (t *Transaction) Validate() error {
+	v := validation{}
+
+	v.check(t.ID != uuid.Nil, "id", ErrNilUUID)
+	v.check(t.TargetWalletID != nil, "targetWalletId", ErrRequired)
+	v.add("currency", ValidateCurrency(t.Currency))
+
 	switch {
 	case t.Sum == 0:
-		return ErrZeroSum
+		v.add("sum", ErrZeroSum)
 	case t.Sum < 0:
-		return ErrNegativeSum
-	case t.TargetWalletID == nil:
-		return ErrWalletNotFound
-	case t.ID == uuid.Nil:
-		return ErrNilUUID
+		v.add("sum", ErrNegativeSum)
+	default:
+		v.add("sum", ValidateAmount(t.Sum))
 	}
 
-	return nil
+	return v.err()
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
 	UUID uuid.UUID `json:"uuid"`
+	Role string    `json:"role,omitempty"`
 }
 
 type GetParams struct {
@@ -85,9 +279,37 @@ type GetParams struct {
 	Filter     string `schema:"filter"`
 }
 
-func ValuesToGetParams(values url.Values) (*GetParams, error) {
+// newDecoder returns a query decoder that skips endpoint specific keys
+// and understands uuid and RFC 3339 time values.
+func newDecoder() *schema.Decoder {
 	decoder := schema.NewDecoder()
 
+	decoder.IgnoreUnknownKeys(true)
+
+	decoder.RegisterConverter(uuid.UUID{}, func(value string) reflect.Value {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return reflect.Value{}
+		}
+
+		return reflect.ValueOf(id)
+	})
+
+	decoder.RegisterConverter(time.Time{}, func(value string) reflect.Value {
+		moment, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return reflect.Value{}
+		}
+
+		return reflect.ValueOf(moment)
+	})
+
+	return decoder
+}
+
+func ValuesToGetParams(values url.Values) (*GetParams, error) {
+	decoder := newDecoder()
+
 	params := &GetParams{}
 
 	err := decoder.Decode(params, values)
@@ -103,14 +325,211 @@ func ValuesToGetParams(values url.Values) (*GetParams, error) {
 }
 
 type UserInfo struct {
-	ID uuid.UUID
+	ID   uuid.UUID
+	Role string
 }
 
+func (u UserInfo) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+type AdminActionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *AdminActionRequest) Validate() error {
+	v := validation{}
+
+	v.check(r.Reason != "", "reason", ErrEmptyReason)
+
+	return v.err()
+}
+
+type BalanceAdjustment struct {
+	ID       uuid.UUID `json:"id"`
+	WalletID uuid.UUID `json:"-"`
+	Sum      float64   `json:"sum"`
+	Reason   string    `json:"reason"`
+}
+
+func (a *BalanceAdjustment) Validate() error {
+	v := validation{}
+
+	v.check(a.ID != uuid.Nil, "id", ErrNilUUID)
+	v.check(a.Sum != 0, "sum", ErrZeroSum)
+	v.check(a.Reason != "", "reason", ErrEmptyReason)
+
+	// the currency is the wallet's, only the representation can be checked here
+	if a.Sum != 0 {
+		v.add("sum", ValidateAmount(a.Sum))
+	}
+
+	return v.err()
+}
+
+type RequestInfo struct {
+	RequestID string
+	ClientIP  string
+}
+
+type AuditEntry struct {
+	ID         uuid.UUID      `json:"id"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	ActorID    *uuid.UUID     `json:"actorId,omitempty"`
+	Action     string         `json:"action"`
+	EntityType string         `json:"entityType"`
+	EntityID   *uuid.UUID     `json:"entityId,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	Before     any            `json:"before,omitempty"`
+	After      any            `json:"after,omitempty"`
+	RequestID  string         `json:"requestId,omitempty"`
+	ClientIP   string         `json:"clientIp,omitempty"`
+}
+
+type AuditParams struct {
+	GetParams
+	Action     string    `schema:"action"`
+	EntityType string    `schema:"entityType"`
+	EntityID   uuid.UUID `schema:"entityId"`
+	ActorID    uuid.UUID `schema:"actorId"`
+	From       time.Time `schema:"from"`
+	To         time.Time `schema:"to"`
+}
+
+func ValuesToAuditParams(values url.Values) (*AuditParams, error) {
+	decoder := newDecoder()
+
+	params := &AuditParams{}
+
+	err := decoder.Decode(params, values)
+	if err != nil {
+		return nil, fmt.Errorf("decoder.Decode(params, values): %w", err)
+	}
+
+	if params.Limit == 0 {
+		params.Limit = StandardPage
+	}
+
+	return params, nil
+}
+
+// XRRequest asks for the rate of a pair, the one valid at At when it is set.
 type XRRequest struct {
-	BaseCurrency   string `schema:"base"`
-	TargetCurrency string `schema:"target"`
+	BaseCurrency   string    `schema:"base"`
+	TargetCurrency string    `schema:"target"`
+	At             time.Time `schema:"at"`
+}
+
+func ValuesToXRRequest(values url.Values) (*XRRequest, error) {
+	decoder := newDecoder()
+
+	request := &XRRequest{}
+
+	err := decoder.Decode(request, values)
+	if err != nil {
+		return nil, fmt.Errorf("decoder.Decode(request, values): %w", err)
+	}
+
+	return request, nil
 }
 
+// XRHistoryRequest asks for the rates of a pair between From and To. Zero
+// From means since the first rate, zero To means until now.
+type XRHistoryRequest struct {
+	BaseCurrency   string    `schema:"base"`
+	TargetCurrency string    `schema:"target"`
+	From           time.Time `schema:"from"`
+	To             time.Time `schema:"to"`
+}
+
+func ValuesToXRHistoryRequest(values url.Values) (*XRHistoryRequest, error) {
+	decoder := newDecoder()
+
+	request := &XRHistoryRequest{}
+
+	err := decoder.Decode(request, values)
+	if err != nil {
+		return nil, fmt.Errorf("decoder.Decode(request, values): %w", err)
+	}
+
+	return request, nil
+}
+
+// XRResponse quotes a pair. XR is the mid-rate, Bid is how much of the
+// target currency one unit of the base is bought for and Ask how much it is
+// sold for. Rate servers without spreads leave Bid and Ask zero.
 type XRResponse struct {
-	XR float64 `json:"xr"`
+	XR  float64 `json:"xr"`
+	Bid float64 `json:"bid"`
+	Ask float64 `json:"ask"`
+}
+
+// Buy returns how much of the target currency amount of the base costs.
+func (r XRResponse) Buy(amount float64) float64 {
+	return amount * r.Ask
+}
+
+// Sell returns how much of the target currency amount of the base brings.
+func (r XRResponse) Sell(amount float64) float64 {
+	return amount * r.Bid
+}
+
+// Spread returns what converting amount of the base at price earns over
+// the mid-rate, in the target currency.
+func (r XRResponse) Spread(amount, price float64) float64 {
+	return math.Abs(price - amount*r.XR)
+}
+
+// XRPair names a pair to quote.
+type XRPair struct {
+	BaseCurrency   string `json:"base"`
+	TargetCurrency string `json:"target"`
+}
+
+type XRBatchRequest struct {
+	Pairs []XRPair `json:"pairs"`
+}
+
+// XRBatchResponse quotes the requested pairs in the order they were asked for.
+type XRBatchResponse struct {
+	Rates []XRResponse `json:"rates"`
+}
+
+// XRMatrix quotes every currency the rate server knows against Base.
+type XRMatrix struct {
+	Base  string                `json:"base"`
+	Rates map[string]XRResponse `json:"rates"`
+}
+
+// XRPoint is the rate of a pair from At until the next point.
+type XRPoint struct {
+	At time.Time `json:"at"`
+	XR float64   `json:"xr"`
+}
+
+// XRRate is the value of one unit of a currency in the rate server's
+// reference currency since EffectiveAt. Cross rates are ratios of these.
+// Stored with a zero rate it records that the currency was removed.
+type XRRate struct {
+	Code        string    `json:"code"`
+	Rate        float64   `json:"rate"`
+	EffectiveAt time.Time `json:"effectiveAt"`
+}
+
+// XRUpdate tells when the rate of a currency was last confirmed and by
+// which feed source, empty when it was set by hand or loaded on start.
+type XRUpdate struct {
+	Code      string    `json:"code"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Source    string    `json:"source,omitempty"`
+}
+
+func (r *XRRate) Validate() error {
+	v := validation{}
+
+	v.add("code", ValidateCurrency(r.Code))
+	v.check(!math.IsNaN(r.Rate) && !math.IsInf(r.Rate, 0) && r.Rate > 0, "rate", ErrInvalidRate)
+
+	return v.err()
 }
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const MaxWalletNameLength = 64
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// FieldErrors are all the violations found in a request.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// validation collects field errors so that a request is reported with all
+// its violations at once.
+type validation struct {
+	errs FieldErrors
+}
+
+func (v *validation) check(ok bool, field string, err error) {
+	if !ok {
+		v.errs = append(v.errs, &FieldError{Field: field, Err: err})
+	}
+}
+
+func (v *validation) add(field string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, &FieldError{Field: field, Err: err})
+	}
+}
+
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return v.errs
+}
+
+// ValidateCurrency checks that code looks like an ISO 4217 code. Whether the
+// currency is known is up to the registry.
+func ValidateCurrency(code string) error {
+	if !currencyCodePattern.MatchString(code) {
+		return ErrWrongCurrency
+	}
+
+	return nil
+}
+
+// ValidateAmount checks that sum is a finite number. Precision depends on
+// the currency and is checked by Currency.ValidateAmount.
+func ValidateAmount(sum float64) error {
+	if math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return ErrInvalidAmount
+	}
+
+	return nil
+}
+
+func ValidateWalletName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return ErrEmptyName
+	case utf8.RuneCountInString(name) > MaxWalletNameLength:
+		return ErrNameTooLong
+	case strings.IndexFunc(name, unicode.IsControl) != -1:
+		return ErrInvalidName
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusDead      = "dead"
+)
+
+// webhookEvents are the events a webhook subscription may filter on.
+var webhookEvents = map[string]bool{
+	EventFundsDeposited:    true,
+	EventFundsWithdrawn:    true,
+	EventTransferCompleted: true,
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	OwnerID   uuid.UUID `json:"ownerId"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (s *WebhookSubscription) Validate() error {
+	v := validation{}
+
+	u, err := url.Parse(s.URL)
+	v.check(
+		err == nil && u.IsAbs() && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"url", ErrInvalidWebhookURL)
+
+	for i, event := range s.Events {
+		v.check(webhookEvents[event], fmt.Sprintf("events[%d]", i), ErrUnknownEvent)
+	}
+
+	return v.err()
+}
+
+// WebhookPayload is the body POSTed to subscribers.
+type WebhookPayload struct {
+	DeliveryID uuid.UUID `json:"deliveryId"`
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	SubscriptionID uuid.UUID  `json:"subscriptionId"`
+	Event          string     `json:"event"`
+	Payload        []byte     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+
+	// filled in for the dispatcher only
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookAttempt is the outcome of one delivery attempt.
+type WebhookAttempt struct {
+	DeliveryID    uuid.UUID
+	StatusCode    int
+	Error         string
+	Succeeded     bool
+	Dead          bool
+	NextAttemptAt time.Time
+}
+
+type DeliveryParams struct {
+	GetParams
+	Status string `schema:"status"`
+}
+
+func ValuesToDeliveryParams(values url.Values) (*DeliveryParams, error) {
+	decoder := newDecoder()
+
+	params := &DeliveryParams{}
+
+	err := decoder.Decode(params, values)
+	if err != nil {
+		return nil, fmt.Errorf("decoder.Decode(params, values): %w", err)
+	}
+
+	if params.Limit == 0 {
+		params.Limit = StandardPage
+	}
+
+	return params, nil
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+// StdoutPublisher writes events to stdout as JSON lines.
+type StdoutPublisher struct {
+	mutex *sync.Mutex
+	out   io.Writer
+}
+
+func NewStdoutPublisher() *StdoutPublisher {
+	return &StdoutPublisher{
+		mutex: new(sync.Mutex),
+		out:   os.Stdout,
+	}
+}
+
+func (p *StdoutPublisher) Publish(_ context.Context, events []model.Event) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return writeJSONLines(p.out, events)
+}
+
+// FilePublisher appends events to a file as JSON lines.
+type FilePublisher struct {
+	mutex *sync.Mutex
+	path  string
+}
+
+func NewFilePublisher(path string) *FilePublisher {
+	return &FilePublisher{
+		mutex: new(sync.Mutex),
+		path:  path,
+	}
+}
+
+func (p *FilePublisher) Publish(_ context.Context, events []model.Event) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	file, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(p.path, ...): %w", err)
+	}
+
+	defer func() {
+		err := file.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("FilePublisher.Publish/file.Close()")
+		}
+	}()
+
+	if err = writeJSONLines(file, events); err != nil {
+		return fmt.Errorf("writeJSONLines(file, events): %w", err)
+	}
+
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("file.Sync(): %w", err)
+	}
+
+	return nil
+}
+
+// HTTPPublisher POSTs each batch to a URL as a JSON array.
+// Any status other than 2xx fails the batch.
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string) *HTTPPublisher {
+	return &HTTPPublisher{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, events []model.Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(events): %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext(...): %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("p.client.Do(req): %w", err)
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("HTTPPublisher.Publish/resp.Body.Close()")
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("sink responded %d: %w", resp.StatusCode, model.ErrPublishingEvents)
+	}
+
+	return nil
+}
+
+func writeJSONLines(w io.Writer, events []model.Event) error {
+	encoder := json.NewEncoder(w)
+
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("encoder.Encode(event): %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+type store interface {
+	PublishOutbox(
+		ctx context.Context,
+		limit int,
+		publish func(ctx context.Context, events []model.Event) error,
+	) (int, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, events []model.Event) error
+}
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Relay moves events from the outbox table to the publisher.
+// Delivery is at least once: consumers should deduplicate by event ID.
+type Relay struct {
+	db        store
+	publisher Publisher
+	cfg       Config
+}
+
+func NewRelay(cfg Config, db store, publisher Publisher) *Relay {
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.drain(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// drain publishes batches until the outbox is empty or publishing fails.
+// Failures are retried on the next tick.
+func (r *Relay) drain(ctx context.Context) {
+	for {
+		published, err := r.db.PublishOutbox(ctx, r.cfg.BatchSize, r.publisher.Publish)
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("drain/r.db.PublishOutbox(...)")
+
+			return
+		}
+
+		if published < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// NewPublisher builds the publisher selected by kind.
+func NewPublisher(kind, filePath, httpURL string) (Publisher, error) {
+	switch kind {
+	case "stdout":
+		return NewStdoutPublisher(), nil
+	case "file":
+		return NewFilePublisher(filePath), nil
+	case "http":
+		return NewHTTPPublisher(httpURL), nil
+	}
+
+	return nil, fmt.Errorf("unknown publisher %q: %w", kind, model.ErrUnknownPublisher)
+}
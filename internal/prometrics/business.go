@@ -0,0 +1,85 @@
+package prometrics
+
+import (
+	"errors"
+	"math"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+const (
+	resultOK    = "ok"
+	resultError = "error"
+
+	reasonInternal = "internal"
+	unknownLabel   = "unknown"
+)
+
+type failureReason struct {
+	err    error
+	reason string
+}
+
+// failureReasons label failed operations, the first matching entry wins.
+// Other errors are internal.
+var failureReasons = []failureReason{
+	{model.ErrWalletNotFound, "wallet_not_found"},
+	{model.ErrNotEnoughBalance, "not_enough_balance"},
+	{model.ErrWalletFrozen, "wallet_frozen"},
+	{model.ErrWalletWasChanged, "wallet_changed"},
+	{model.ErrDuplicateTransaction, "duplicate_transaction"},
+	{model.ErrSameWallet, "same_wallet"},
+	{model.ErrWrongCurrency, "wrong_currency"},
+	{model.ErrCurrencyNotFound, "currency_not_found"},
+	{model.ErrCurrencyDisabled, "currency_disabled"},
+	{model.ErrGettingXR, "exchange_rate_unavailable"},
+	{model.ErrNegativeSum, "negative_sum"},
+	{model.ErrZeroSum, "zero_sum"},
+	{model.ErrInvalidAmount, "invalid_amount"},
+	{model.ErrAmountPrecision, "amount_precision"},
+	{model.ErrNilUUID, "nil_uuid"},
+	{model.ErrNotAllowed, "not_allowed"},
+	{model.ErrUserInfoNotOk, "unauthorized"},
+}
+
+func reasonOf(err error) string {
+	for _, failure := range failureReasons {
+		if errors.Is(err, failure.err) {
+			return failure.reason
+		}
+	}
+
+	return reasonInternal
+}
+
+// TrackOperation counts a transfer, deposit or withdrawal of amount in
+// currency, and the money moved when it succeeded. A currency that failed
+// validation isn't used as a label.
+func (m *Metrics) TrackOperation(operation, currency string, amount float64, err error) {
+	if err != nil {
+		var fieldErr *model.FieldError
+		if errors.As(err, &fieldErr) && fieldErr.Field == "currency" {
+			currency = unknownLabel
+		}
+
+		m.operationsTotal.WithLabelValues(operation, currency, resultError).Inc()
+		m.operationFailures.WithLabelValues(operation, reasonOf(err)).Inc()
+
+		return
+	}
+
+	m.operationsTotal.WithLabelValues(operation, currency, resultOK).Inc()
+	m.operationAmount.WithLabelValues(operation, currency).Add(math.Abs(amount))
+}
+
+func (m *Metrics) TrackConversion(baseCurrency, targetCurrency string) {
+	m.xrConversions.WithLabelValues(baseCurrency, targetCurrency).Inc()
+}
+
+func (m *Metrics) TrackWalletCreated(currency string) {
+	m.walletsCreated.WithLabelValues(currency).Inc()
+}
+
+func (m *Metrics) TrackWalletsArchived(count int) {
+	m.walletsArchived.Add(float64(count))
+}
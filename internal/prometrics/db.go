@@ -0,0 +1,51 @@
+package prometrics
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (m *Metrics) TrackDBTransaction(start time.Time, method string) {
+	elapsed := time.Since(start).Seconds()
+
+	m.dbTransactionDuration.WithLabelValues(method).Observe(elapsed)
+}
+
+// TrackDBPool exports the connection pool stats, read from stat on every
+// scrape.
+func (m *Metrics) TrackDBPool(stat func() *pgxpool.Stat) {
+	gauge := func(name, help string, value func(s *pgxpool.Stat) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{Name: name, Help: help},
+			func() float64 { return value(stat()) })
+	}
+
+	counter := func(name, help string, value func(s *pgxpool.Stat) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(
+			prometheus.CounterOpts{Name: name, Help: help},
+			func() float64 { return value(stat()) })
+	}
+
+	prometheus.MustRegister(
+		gauge("db_pool_max_conns", "Maximum size of the pool.",
+			func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }),
+		gauge("db_pool_total_conns", "Connections in the pool.",
+			func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) }),
+		gauge("db_pool_acquired_conns", "Connections in use.",
+			func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }),
+		gauge("db_pool_idle_conns", "Idle connections.",
+			func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) }),
+		gauge("db_pool_constructing_conns", "Connections being established.",
+			func(s *pgxpool.Stat) float64 { return float64(s.ConstructingConns()) }),
+		counter("db_pool_acquires_total", "Connections acquired from the pool.",
+			func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }),
+		counter("db_pool_empty_acquires_total", "Acquires that had to wait for a connection.",
+			func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }),
+		counter("db_pool_canceled_acquires_total", "Acquires canceled by their context.",
+			func(s *pgxpool.Stat) float64 { return float64(s.CanceledAcquireCount()) }),
+		counter("db_pool_acquire_duration_seconds_total", "Time spent acquiring connections.",
+			func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() }),
+	)
+}
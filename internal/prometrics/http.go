@@ -0,0 +1,81 @@
+package prometrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels requests no route matched, so that scanned paths
+// don't make a series each.
+const unmatchedRoute = "unmatched"
+
+// httpMetrics are the metrics of an HTTP server. The names of the rate
+// server's start with xr_, both servers may share a process in tests.
+type httpMetrics struct {
+	requestsTotal    *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	responseSize     *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+}
+
+func newHTTPMetrics(prefix string) httpMetrics {
+	return httpMetrics{
+		requestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: prefix + "http_requests_total",
+				Help: "Total number of HTTP requests by route, method and status code.",
+			},
+			[]string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: prefix + "http_request_duration_seconds",
+				Help: "Duration of HTTP requests.",
+			},
+			[]string{"method", "route"}),
+		responseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    prefix + "http_response_size_bytes",
+				Help:    "Size of HTTP response bodies.",
+				Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+			},
+			[]string{"method", "route"}),
+		requestsInFlight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: prefix + "http_requests_in_flight",
+				Help: "HTTP requests being served.",
+			}),
+	}
+}
+
+func (m *httpMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requestsTotal,
+		m.requestDuration,
+		m.responseSize,
+		m.requestsInFlight,
+	}
+}
+
+func (m *httpMetrics) TrackHTTPRequestStart() {
+	m.requestsInFlight.Inc()
+}
+
+// TrackHTTPRequest labels the request with the route chi matched, it has
+// to be called once the request has been routed.
+func (m *httpMetrics) TrackHTTPRequest(start time.Time, r *http.Request, status, size int) {
+	route := unmatchedRoute
+	if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+		route = routeContext.RoutePattern()
+	}
+
+	elapsed := time.Since(start).Seconds()
+
+	m.requestsInFlight.Dec()
+	m.requestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(r.Method, route).Observe(elapsed)
+	m.responseSize.WithLabelValues(r.Method, route).Observe(float64(size))
+}
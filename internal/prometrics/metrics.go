@@ -1,34 +1,30 @@
 package prometrics
 
 import (
-	"github.com/go-chi/chi/v5"
-	"net/http"
-	"strings"
 	"time"
 
+	"github.com/Saaghh/wallet/internal/model"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Metrics struct {
-	requestsTotal           *prometheus.CounterVec
-	requestDuration         *prometheus.HistogramVec
+	httpMetrics
+
 	externalRequestDuration *prometheus.HistogramVec
+	xrCacheRequests         *prometheus.CounterVec
+	xrProviderRequests      *prometheus.CounterVec
+	xrProviderUp            *prometheus.GaugeVec
+	operationsTotal         *prometheus.CounterVec
+	operationAmount         *prometheus.CounterVec
+	operationFailures       *prometheus.CounterVec
+	xrConversions           *prometheus.CounterVec
+	walletsCreated          *prometheus.CounterVec
+	walletsArchived         prometheus.Counter
+	dbTransactionDuration   *prometheus.HistogramVec
 }
 
 func New() *Metrics {
-	requestsTotal := prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests.",
-		},
-		[]string{"endpoint"})
-
-	requestDuration := prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "http_request_duration_seconds",
-			Help: "Duration of HTTP requests.",
-		},
-		[]string{"endpoint"})
+	serverMetrics := newHTTPMetrics("")
 
 	externalRequestDuration := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -37,35 +33,205 @@ func New() *Metrics {
 		},
 		[]string{"endpoint"})
 
+	xrCacheRequests := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "xr_cache_requests_total",
+			Help: "Exchange rate lookups by cache result: hit, miss or stale.",
+		},
+		[]string{"result"})
+
+	xrProviderRequests := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "xr_provider_requests_total",
+			Help: "Exchange rate lookups by provider and result: ok, error or deviation.",
+		},
+		[]string{"provider", "result"})
+
+	xrProviderUp := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "xr_provider_up",
+			Help: "Whether the last lookup from the rate provider succeeded.",
+		},
+		[]string{"provider"})
+
+	operationsTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wallet_operations_total",
+			Help: "Transfers, deposits and withdrawals by currency and result: ok or error.",
+		},
+		[]string{"operation", "currency", "result"})
+
+	operationAmount := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wallet_operation_amount_total",
+			Help: "Money moved by successful operations, in the currency of the operation.",
+		},
+		[]string{"operation", "currency"})
+
+	operationFailures := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wallet_operation_failures_total",
+			Help: "Failed transfers, deposits and withdrawals by reason.",
+		},
+		[]string{"operation", "reason"})
+
+	xrConversions := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "xr_conversions_total",
+			Help: "Currency conversions of moved money by currency pair.",
+		},
+		[]string{"base", "target"})
+
+	walletsCreated := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wallets_created_total",
+			Help: "Wallets created by currency.",
+		},
+		[]string{"currency"})
+
+	walletsArchived := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wallets_archived_total",
+			Help: "Inactive wallets archived.",
+		})
+
+	dbTransactionDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "db_transaction_duration_seconds",
+			Help: "Duration of database transactions by store method.",
+		},
+		[]string{"method"})
+
 	metrics := Metrics{
-		requestsTotal:           requestsTotal,
-		requestDuration:         requestDuration,
+		httpMetrics:             serverMetrics,
 		externalRequestDuration: externalRequestDuration,
+		xrCacheRequests:         xrCacheRequests,
+		xrProviderRequests:      xrProviderRequests,
+		xrProviderUp:            xrProviderUp,
+		operationsTotal:         operationsTotal,
+		operationAmount:         operationAmount,
+		operationFailures:       operationFailures,
+		xrConversions:           xrConversions,
+		walletsCreated:          walletsCreated,
+		walletsArchived:         walletsArchived,
+		dbTransactionDuration:   dbTransactionDuration,
 	}
 
+	prometheus.MustRegister(serverMetrics.collectors()...)
 	prometheus.MustRegister(
-		requestsTotal,
-		requestDuration,
+		externalRequestDuration,
+		xrCacheRequests,
+		xrProviderRequests,
+		xrProviderUp,
+		operationsTotal,
+		operationAmount,
+		operationFailures,
+		xrConversions,
+		walletsCreated,
+		walletsArchived,
+		dbTransactionDuration,
 	)
 
 	return &metrics
 }
 
-func (m *Metrics) TrackHTTPRequest(start time.Time, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	url := r.URL.Host + r.URL.Path
-	if id != "" {
-		url = strings.Replace(url, id, "{id}", 1)
-	}
-	method := r.Method
+func (m *Metrics) TrackExternalRequest(start time.Time, endpoint string) {
 	elapsed := time.Since(start).Seconds()
 
-	m.requestsTotal.WithLabelValues(method + url).Inc()
-	m.requestDuration.WithLabelValues(method + url).Observe(elapsed)
+	m.externalRequestDuration.WithLabelValues(endpoint).Observe(elapsed)
 }
 
-func (m *Metrics) TrackExternalRequest(start time.Time, endpoint string) {
-	elapsed := time.Since(start).Seconds()
+func (m *Metrics) TrackXRCache(result string) {
+	m.xrCacheRequests.WithLabelValues(result).Inc()
+}
 
-	m.externalRequestDuration.WithLabelValues(endpoint).Observe(elapsed)
+func (m *Metrics) TrackXRProvider(provider, result string) {
+	m.xrProviderRequests.WithLabelValues(provider, result).Inc()
+
+	switch result {
+	case "ok":
+		m.xrProviderUp.WithLabelValues(provider).Set(1)
+	case "error":
+		m.xrProviderUp.WithLabelValues(provider).Set(0)
+	}
+}
+
+// XRServerMetrics are the metrics of the rate server.
+type XRServerMetrics struct {
+	httpMetrics
+
+	xrFeedFetches *prometheus.CounterVec
+	xrRatesStale  prometheus.Gauge
+	xrCurrencies  prometheus.Gauge
+	xrRateAge     *prometheus.GaugeVec
+}
+
+func NewXRServer() *XRServerMetrics {
+	serverMetrics := newHTTPMetrics("xr_")
+
+	xrFeedFetches := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "xr_feed_fetches_total",
+			Help: "Rate feed fetches by provider and result: ok or error.",
+		},
+		[]string{"provider", "result"})
+
+	xrRatesStale := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "xr_rates_stale",
+			Help: "1 while any rate has not been updated for longer than the feed's max age.",
+		})
+
+	xrCurrencies := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "xr_currencies",
+			Help: "Number of currencies with a rate.",
+		})
+
+	xrRateAge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "xr_rate_age_seconds",
+			Help: "Time since the rate of the currency was last updated.",
+		},
+		[]string{"currency"})
+
+	metrics := XRServerMetrics{
+		httpMetrics:   serverMetrics,
+		xrFeedFetches: xrFeedFetches,
+		xrRatesStale:  xrRatesStale,
+		xrCurrencies:  xrCurrencies,
+		xrRateAge:     xrRateAge,
+	}
+
+	prometheus.MustRegister(serverMetrics.collectors()...)
+	prometheus.MustRegister(
+		xrFeedFetches,
+		xrRatesStale,
+		xrCurrencies,
+		xrRateAge,
+	)
+
+	return &metrics
+}
+
+func (m *XRServerMetrics) TrackXRFeed(provider, result string) {
+	m.xrFeedFetches.WithLabelValues(provider, result).Inc()
+}
+
+func (m *XRServerMetrics) SetXRRatesStale(stale bool) {
+	if stale {
+		m.xrRatesStale.Set(1)
+	} else {
+		m.xrRatesStale.Set(0)
+	}
+}
+
+// SetXRRates reports the currencies with a rate and how old each rate is.
+func (m *XRServerMetrics) SetXRRates(updates []model.XRUpdate) {
+	m.xrCurrencies.Set(float64(len(updates)))
+	m.xrRateAge.Reset()
+
+	for _, update := range updates {
+		m.xrRateAge.WithLabelValues(update.Code).Set(time.Since(update.UpdatedAt).Seconds())
+	}
 }
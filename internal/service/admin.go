@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+)
+
+func checkAdmin(ctx context.Context) error {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return model.ErrUserInfoNotOk
+	}
+
+	if !userInfo.IsAdmin() {
+		return model.ErrNotAllowed
+	}
+
+	return nil
+}
+
+func (s *Service) SearchUsers(ctx context.Context, email string, params model.GetParams) ([]*model.User, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	users, err := s.db.SearchUsers(ctx, email, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.SearchUsers(ctx, email, params): %w", err)
+	}
+
+	return users, nil
+}
+
+func (s *Service) GetUserWallets(ctx context.Context, userID uuid.UUID, params model.GetParams) ([]*model.Wallet, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	wallets, err := s.db.GetWalletsByOwner(ctx, userID, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetWalletsByOwner(ctx, userID, params): %w", err)
+	}
+
+	return wallets, nil
+}
+
+func (s *Service) GetUserTransactions(
+	ctx context.Context,
+	userID uuid.UUID,
+	params model.GetParams,
+) ([]*model.Transaction, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	transactions, err := s.db.GetTransactionsByOwner(ctx, userID, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetTransactionsByOwner(ctx, userID, params): %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (s *Service) FreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error) {
+	return s.SetWalletStatus(ctx, walletID, model.WalletStatusRequest{Status: model.WalletStatusFrozen, Reason: reason})
+}
+
+func (s *Service) UnfreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error) {
+	return s.SetWalletStatus(ctx, walletID, model.WalletStatusRequest{Status: model.WalletStatusActive, Reason: reason})
+}
+
+func (s *Service) EnableWallet(ctx context.Context, walletID uuid.UUID, reason string) (*model.Wallet, error) {
+	return s.SetWalletStatus(ctx, walletID, model.WalletStatusRequest{Status: model.WalletStatusActive, Reason: reason})
+}
+
+func (s *Service) SetWalletStatus(
+	ctx context.Context,
+	walletID uuid.UUID,
+	request model.WalletStatusRequest,
+) (*model.Wallet, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, fmt.Errorf("request.Validate(): %w", err)
+	}
+
+	wallet, err := s.db.SetWalletStatus(ctx, walletID, request.Status, request.Reason)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.SetWalletStatus(ctx, walletID, request.Status, request.Reason): %w", err)
+	}
+
+	return wallet, nil
+}
+
+func (s *Service) AdjustBalance(ctx context.Context, adjustment model.BalanceAdjustment) (*uuid.UUID, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	if err := adjustment.Validate(); err != nil {
+		return nil, fmt.Errorf("adjustment.Validate(): %w", err)
+	}
+
+	transactionID, err := s.db.AdjustBalance(ctx, adjustment)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.AdjustBalance(ctx, adjustment): %w", err)
+	}
+
+	return transactionID, nil
+}
+
+func (s *Service) GetSpreadIncome(ctx context.Context, params model.ReportParams) ([]*model.SpreadIncomeReport, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	reports, err := s.db.GetSpreadIncome(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetSpreadIncome(ctx, params): %w", err)
+	}
+
+	return reports, nil
+}
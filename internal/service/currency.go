@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+func (s *Service) GetCurrencies(ctx context.Context) ([]*model.Currency, error) {
+	currencies, err := s.db.GetCurrencies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetCurrencies(ctx): %w", err)
+	}
+
+	return currencies, nil
+}
+
+func (s *Service) GetCurrency(ctx context.Context, code string) (*model.Currency, error) {
+	currency, err := s.db.GetCurrency(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetCurrency(ctx, code): %w", err)
+	}
+
+	return currency, nil
+}
+
+func (s *Service) UpsertCurrency(ctx context.Context, currency model.Currency) (*model.Currency, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, fmt.Errorf("checkAdmin(ctx): %w", err)
+	}
+
+	if err := currency.Validate(); err != nil {
+		return nil, fmt.Errorf("currency.Validate(): %w", err)
+	}
+
+	rCurrency, err := s.db.UpsertCurrency(ctx, currency)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.UpsertCurrency(ctx, currency): %w", err)
+	}
+
+	return rCurrency, nil
+}
+
+// checkWalletCurrency checks that wallets can be opened in code.
+func (s *Service) checkWalletCurrency(ctx context.Context, code string) error {
+	currency, err := s.db.GetCurrency(ctx, code)
+
+	switch {
+	case errors.Is(err, model.ErrCurrencyNotFound):
+		return &model.FieldError{Field: "currency", Err: model.ErrWrongCurrency}
+	case err != nil:
+		return fmt.Errorf("s.db.GetCurrency(ctx, code): %w", err)
+	case !currency.IsEnabled:
+		return &model.FieldError{Field: "currency", Err: model.ErrCurrencyDisabled}
+	}
+
+	return nil
+}
+
+// checkTransactionCurrency checks that the transaction is in a known currency
+// and fits its minor units. Disabled currencies are fine, their wallets stay usable.
+func (s *Service) checkTransactionCurrency(ctx context.Context, transaction model.Transaction) error {
+	currency, err := s.db.GetCurrency(ctx, transaction.Currency)
+
+	switch {
+	case errors.Is(err, model.ErrCurrencyNotFound):
+		return &model.FieldError{Field: "currency", Err: model.ErrWrongCurrency}
+	case err != nil:
+		return fmt.Errorf("s.db.GetCurrency(ctx, transaction.Currency): %w", err)
+	}
+
+	if err := currency.ValidateAmount(transaction.Sum); err != nil {
+		return &model.FieldError{Field: "sum", Err: err}
+	}
+
+	return nil
+}
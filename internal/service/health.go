@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+// readinessCheckTimeout bounds each dependency check, a hanging dependency
+// is as good as a failing one.
+const readinessCheckTimeout = 2 * time.Second
+
+// CheckReadiness checks the dependencies concurrently: the database, that its
+// migrations are up to date and the rate server.
+func (s *Service) CheckReadiness(ctx context.Context) model.Readiness {
+	checks := map[string]func(ctx context.Context) error{
+		"postgres":    s.db.Ping,
+		"migrations":  s.db.CheckMigrations,
+		"rate_server": s.cc.Ping,
+	}
+
+	readiness := model.Readiness{
+		Status:       model.HealthOK,
+		Dependencies: make(map[string]model.DependencyHealth, len(checks)),
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+
+			health := model.DependencyHealth{Status: model.HealthOK}
+
+			if err := check(checkCtx); err != nil {
+				logger.FromContext(ctx).With(zap.Error(err), zap.String("dependency", name)).Warn("CheckReadiness/check(checkCtx)")
+
+				health.Status = model.HealthUnavailable
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			readiness.Dependencies[name] = health
+
+			if health.Status != model.HealthOK {
+				readiness.Status = model.HealthUnavailable
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return readiness
+}
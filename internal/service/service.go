@@ -13,38 +13,92 @@ type store interface {
 	CreateWallet(ctx context.Context, wallet model.Wallet) (*model.Wallet, error)
 	GetWalletByID(ctx context.Context, walletID uuid.UUID) (*model.Wallet, error)
 	GetWallets(ctx context.Context, params model.GetParams) ([]*model.Wallet, error)
-	DeleteWallet(ctx context.Context, walletID uuid.UUID) error
+	CloseWallet(ctx context.Context, closure model.WalletClosure) error
 	UpdateWallet(ctx context.Context, walletID uuid.UUID, request model.UpdateWalletRequest) (*model.Wallet, error)
 
 	GetTransactions(ctx context.Context, params model.GetParams) ([]*model.Transaction, error)
 	Transfer(ctx context.Context, transfer model.Transfer, transaction model.Transaction) (*uuid.UUID, error)
-	ExternalTransaction(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error)
+	ExternalTransaction(
+		ctx context.Context,
+		transaction model.Transaction,
+		spreadIncome []model.SpreadIncome,
+	) (*uuid.UUID, error)
 
 	DisableInactiveWallets(ctx context.Context) ([]*model.Wallet, error)
+
+	GetAuditEntries(ctx context.Context, params model.AuditParams) ([]*model.AuditEntry, error)
+
+	GetCurrencies(ctx context.Context) ([]*model.Currency, error)
+	GetCurrency(ctx context.Context, code string) (*model.Currency, error)
+	UpsertCurrency(ctx context.Context, currency model.Currency) (*model.Currency, error)
+
+	SearchUsers(ctx context.Context, email string, params model.GetParams) ([]*model.User, error)
+	GetWalletsByOwner(ctx context.Context, ownerID uuid.UUID, params model.GetParams) ([]*model.Wallet, error)
+	GetTransactionsByOwner(ctx context.Context, ownerID uuid.UUID, params model.GetParams) ([]*model.Transaction, error)
+	SetWalletStatus(ctx context.Context, walletID uuid.UUID, status model.WalletStatus, reason string) (*model.Wallet, error)
+	AdjustBalance(ctx context.Context, adjustment model.BalanceAdjustment) (*uuid.UUID, error)
+	GetSpreadIncome(ctx context.Context, params model.ReportParams) ([]*model.SpreadIncomeReport, error)
+
+	CreateWebhookSubscription(ctx context.Context, subscription model.WebhookSubscription) (*model.WebhookSubscription, error)
+	GetWebhookSubscriptions(ctx context.Context, ownerID uuid.UUID) ([]*model.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, ownerID, subscriptionID uuid.UUID) error
+	GetWebhookDeliveries(
+		ctx context.Context,
+		ownerID uuid.UUID,
+		subscriptionID uuid.UUID,
+		params model.DeliveryParams,
+	) ([]*model.WebhookDelivery, error)
+	RetryWebhookDelivery(ctx context.Context, ownerID, deliveryID uuid.UUID) error
+
+	ListenWalletEvents(ctx context.Context, notify func(sequence int64)) error
+	GetWalletEvents(ctx context.Context, ownerID uuid.UUID, after int64, limit int) ([]model.Event, error)
+	GetLatestEventSequence(ctx context.Context) (int64, error)
+
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
 }
 
 type currencyConverter interface {
-	GetExchangeRate(baseCurrency, targetCurrency string) (float64, error)
+	GetExchangeRate(ctx context.Context, baseCurrency, targetCurrency string) (model.XRResponse, error)
+	GetExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error)
+	Ping(ctx context.Context) error
+}
+
+type metrics interface {
+	TrackOperation(operation, currency string, amount float64, err error)
+	TrackConversion(baseCurrency, targetCurrency string)
+	TrackWalletCreated(currency string)
+	TrackWalletsArchived(count int)
 }
 
 type Service struct {
-	db store
-	cc currencyConverter
+	db      store
+	cc      currencyConverter
+	hub     *hub
+	metrics metrics
 }
 
-func New(db store, cc currencyConverter) *Service {
+func New(db store, cc currencyConverter, metrics metrics) *Service {
 	return &Service{
-		db: db,
-		cc: cc,
+		db:      db,
+		cc:      cc,
+		hub:     newHub(),
+		metrics: metrics,
 	}
 }
 
 func (s *Service) CreateWallet(ctx context.Context, wallet model.Wallet) (*model.Wallet, error) {
+	if err := s.checkWalletCurrency(ctx, wallet.Currency); err != nil {
+		return nil, fmt.Errorf("s.checkWalletCurrency(ctx, wallet.Currency): %w", err)
+	}
+
 	rWallet, err := s.db.CreateWallet(ctx, wallet)
 	if err != nil {
 		return nil, fmt.Errorf("s.db.CreateWallet(ctx, owner, currency): %w", err)
 	}
 
+	s.metrics.TrackWalletCreated(rWallet.Currency)
+
 	return rWallet, nil
 }
 
@@ -68,30 +122,41 @@ func (s *Service) transactionToTransfer(ctx context.Context, transaction model.T
 		return nil, fmt.Errorf("s.db.GetWalletByID(ctx, *transaction.TargetWallet): %w", err)
 	}
 
+	if !agentWallet.Status.CanDebit() || !targetWallet.Status.CanCredit() {
+		return nil, model.ErrWalletFrozen
+	}
+
 	transfer := model.Transfer{
 		ID:           transaction.ID,
 		AgentWallet:  agentWallet,
 		TargetWallet: targetWallet,
 	}
 
-	if agentWallet.Currency != transaction.Currency {
-		xr, err := s.cc.GetExchangeRate(transaction.Currency, agentWallet.Currency)
-		if err != nil {
-			return nil, fmt.Errorf("s.cc.GetExchangeRate(transaction.Currency, agentWallet.Currency): %w", err)
-		}
+	// both legs are quoted at once, from the same rates
+	quotes, err := s.getQuotes(ctx, transaction.Currency, agentWallet.Currency, targetWallet.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("s.getQuotes(ctx, transaction.Currency, ...): %w", err)
+	}
 
-		transfer.SumToWithdraw = xr * transaction.Sum
+	// the agent buys the transaction currency, the target sells it
+	if xr, ok := quotes[agentWallet.Currency]; ok {
+		transfer.SumToWithdraw = xr.Buy(transaction.Sum)
+		transfer.SpreadIncome = append(transfer.SpreadIncome, model.SpreadIncome{
+			WalletID: agentWallet.ID,
+			Currency: agentWallet.Currency,
+			Amount:   xr.Spread(transaction.Sum, transfer.SumToWithdraw),
+		})
 	} else {
 		transfer.SumToWithdraw = transaction.Sum
 	}
 
-	if targetWallet.Currency != transaction.Currency {
-		xr, err := s.cc.GetExchangeRate(transaction.Currency, agentWallet.Currency)
-		if err != nil {
-			return nil, fmt.Errorf("s.cc.GetExchangeRate(transaction.Currency, agentWallet.Currency): %w", err)
-		}
-
-		transfer.SumToDeposit = xr * transaction.Sum
+	if xr, ok := quotes[targetWallet.Currency]; ok {
+		transfer.SumToDeposit = xr.Sell(transaction.Sum)
+		transfer.SpreadIncome = append(transfer.SpreadIncome, model.SpreadIncome{
+			WalletID: targetWallet.ID,
+			Currency: targetWallet.Currency,
+			Amount:   xr.Spread(transaction.Sum, transfer.SumToDeposit),
+		})
 	} else {
 		transfer.SumToDeposit = transaction.Sum
 	}
@@ -99,7 +164,55 @@ func (s *Service) transactionToTransfer(ctx context.Context, transaction model.T
 	return &transfer, nil
 }
 
+// getQuotes quotes baseCurrency against each of targetCurrencies in a single
+// lookup, by target currency. baseCurrency itself needs no quote.
+func (s *Service) getQuotes(
+	ctx context.Context,
+	baseCurrency string,
+	targetCurrencies ...string,
+) (map[string]model.XRResponse, error) {
+	quotes := make(map[string]model.XRResponse, len(targetCurrencies))
+	pairs := make([]model.XRPair, 0, len(targetCurrencies))
+	seen := map[string]bool{baseCurrency: true}
+
+	for _, targetCurrency := range targetCurrencies {
+		if seen[targetCurrency] {
+			continue
+		}
+
+		seen[targetCurrency] = true
+		pairs = append(pairs, model.XRPair{BaseCurrency: baseCurrency, TargetCurrency: targetCurrency})
+	}
+
+	if len(pairs) == 0 {
+		return quotes, nil
+	}
+
+	rates, err := s.cc.GetExchangeRates(ctx, pairs)
+	if err != nil {
+		return nil, fmt.Errorf("s.cc.GetExchangeRates(ctx, pairs): %w", err)
+	}
+
+	for i, pair := range pairs {
+		quotes[pair.TargetCurrency] = rates[i]
+	}
+
+	return quotes, nil
+}
+
 func (s *Service) Transfer(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error) {
+	transactionID, err := s.transfer(ctx, transaction)
+
+	s.metrics.TrackOperation(string(model.TransactionTypeTransfer), transaction.Currency, transaction.Sum, err)
+
+	return transactionID, err
+}
+
+func (s *Service) transfer(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error) {
+	if err := s.checkTransactionCurrency(ctx, transaction); err != nil {
+		return nil, fmt.Errorf("s.checkTransactionCurrency(ctx, transaction): %w", err)
+	}
+
 	// conversion
 	transfer, err := s.transactionToTransfer(ctx, transaction)
 	if err != nil {
@@ -112,30 +225,82 @@ func (s *Service) Transfer(ctx context.Context, transaction model.Transaction) (
 		return nil, fmt.Errorf("s.db.Transfer(ctx, transaction): %w", err)
 	}
 
+	for _, wallet := range []*model.Wallet{transfer.AgentWallet, transfer.TargetWallet} {
+		if wallet.Currency != transaction.Currency {
+			s.metrics.TrackConversion(transaction.Currency, wallet.Currency)
+		}
+	}
+
 	return transactionID, nil
 }
 
 func (s *Service) ExternalTransaction(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error) {
+	transactionID, err := s.externalTransaction(ctx, transaction)
+
+	operation := model.TransactionTypeDeposit
+	if transaction.Sum < 0 {
+		operation = model.TransactionTypeWithdrawal
+	}
+
+	s.metrics.TrackOperation(string(operation), transaction.Currency, transaction.Sum, err)
+
+	return transactionID, err
+}
+
+func (s *Service) externalTransaction(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error) {
+	if err := s.checkTransactionCurrency(ctx, transaction); err != nil {
+		return nil, fmt.Errorf("s.checkTransactionCurrency(ctx, transaction): %w", err)
+	}
+
 	// conversion
 	wallet, err := s.db.GetWalletByID(ctx, *transaction.TargetWalletID)
 	if err != nil {
 		return nil, fmt.Errorf("s.db.GetWalletByID(ctx, *transaction.TargetWalletID): %w", err)
 	}
 
+	allowed := wallet.Status.CanCredit()
+	if transaction.Sum < 0 {
+		allowed = wallet.Status.CanDebit()
+	}
+
+	if !allowed {
+		return nil, model.ErrWalletFrozen
+	}
+
+	var spreadIncome []model.SpreadIncome
+
+	currency := transaction.Currency
+
 	if wallet.Currency != transaction.Currency {
-		k, err := s.cc.GetExchangeRate(transaction.Currency, wallet.Currency)
+		xr, err := s.cc.GetExchangeRate(ctx, transaction.Currency, wallet.Currency)
 		if err != nil {
-			return nil, fmt.Errorf("s.cc.GetExchangeRate(transaction.Currency, wallet.Currency): %w", err)
+			return nil, fmt.Errorf("s.cc.GetExchangeRate(ctx, transaction.Currency, wallet.Currency): %w", err)
 		}
 
+		// deposits sell the transaction currency, withdrawals buy it
+		sum := xr.Sell(transaction.Sum)
+		if transaction.Sum < 0 {
+			sum = xr.Buy(transaction.Sum)
+		}
+
+		spreadIncome = append(spreadIncome, model.SpreadIncome{
+			WalletID: wallet.ID,
+			Currency: wallet.Currency,
+			Amount:   xr.Spread(transaction.Sum, sum),
+		})
+
 		transaction.Currency = wallet.Currency
-		transaction.Sum *= k
+		transaction.Sum = sum
 	}
 
 	// execution
-	transactionID, err := s.db.ExternalTransaction(ctx, transaction)
+	transactionID, err := s.db.ExternalTransaction(ctx, transaction, spreadIncome)
 	if err != nil {
-		return nil, fmt.Errorf("s.db.ExternalTransaction(ctx, transaction): %w", err)
+		return nil, fmt.Errorf("s.db.ExternalTransaction(ctx, transaction, spreadIncome): %w", err)
+	}
+
+	if currency != wallet.Currency {
+		s.metrics.TrackConversion(currency, wallet.Currency)
 	}
 
 	return transactionID, nil
@@ -150,10 +315,54 @@ func (s *Service) GetWallets(ctx context.Context, params model.GetParams) ([]*mo
 	return wallets, nil
 }
 
-func (s *Service) DeleteWallet(ctx context.Context, walletID uuid.UUID) error {
-	err := s.db.DeleteWallet(ctx, walletID)
+// DeleteWallet closes the wallet. The remaining balance, if any, is moved
+// to the sweepTo wallet, converting it when currencies differ.
+func (s *Service) DeleteWallet(ctx context.Context, walletID uuid.UUID, sweepTo *uuid.UUID) error {
+	wallet, err := s.db.GetWalletByID(ctx, walletID)
 	if err != nil {
-		return fmt.Errorf("s.db.DeleteWallet(ctx, walletID): %w", err)
+		return fmt.Errorf("s.db.GetWalletByID(ctx, walletID): %w", err)
+	}
+
+	closure := model.WalletClosure{
+		WalletID:           wallet.ID,
+		Currency:           wallet.Currency,
+		SweepTo:            sweepTo,
+		ConversionRate:     1,
+		SweepTransactionID: uuid.New(),
+		CloseTransactionID: uuid.New(),
+	}
+
+	if sweepTo != nil {
+		if *sweepTo == walletID {
+			return model.ErrSameWallet
+		}
+
+		target, err := s.db.GetWalletByID(ctx, *sweepTo)
+		if err != nil {
+			return fmt.Errorf("s.db.GetWalletByID(ctx, *sweepTo): %w", err)
+		}
+
+		closure.SweepToCurrency = target.Currency
+
+		// the balance is sold for the target currency
+		if target.Currency != wallet.Currency {
+			xr, err := s.cc.GetExchangeRate(ctx, wallet.Currency, target.Currency)
+			if err != nil {
+				return fmt.Errorf("s.cc.GetExchangeRate(ctx, wallet.Currency, target.Currency): %w", err)
+			}
+
+			closure.ConversionRate = xr.Bid
+			closure.SpreadRate = xr.XR - xr.Bid
+		}
+	}
+
+	err = s.db.CloseWallet(ctx, closure)
+	if err != nil {
+		return fmt.Errorf("s.db.CloseWallet(ctx, closure): %w", err)
+	}
+
+	if closure.SweepTo != nil && closure.SweepToCurrency != closure.Currency {
+		s.metrics.TrackConversion(closure.Currency, closure.SweepToCurrency)
 	}
 
 	return nil
@@ -174,22 +383,40 @@ func (s *Service) UpdateWallet(ctx context.Context, walletID uuid.UUID, request
 		return nil, model.ErrNotAllowed
 	}
 
+	request.FromCurrency = wallet.Currency
+
 	if request.Currency != nil && *request.Currency != wallet.Currency {
-		xr, err := s.cc.GetExchangeRate(wallet.Currency, *request.Currency)
+		if wallet.Status != model.WalletStatusActive {
+			return nil, model.ErrWalletFrozen
+		}
+
+		if err := s.checkWalletCurrency(ctx, *request.Currency); err != nil {
+			return nil, fmt.Errorf("s.checkWalletCurrency(ctx, *request.Currency): %w", err)
+		}
+
+		xr, err := s.cc.GetExchangeRate(ctx, wallet.Currency, *request.Currency)
 		if err != nil {
-			return nil, fmt.Errorf("s.cc.GetExchangeRate(*request.Currency, wallet.Currency): %w", err)
+			return nil, fmt.Errorf("s.cc.GetExchangeRate(ctx, *request.Currency, wallet.Currency): %w", err)
 		}
 
-		request.ConversionRate = xr
+		// the balance is sold for the new currency
+		request.ConversionRate = xr.Bid
+		request.SpreadRate = xr.XR - xr.Bid
 	} else {
 		request.ConversionRate = 1
 	}
 
+	currency := wallet.Currency
+
 	wallet, err = s.db.UpdateWallet(ctx, walletID, request)
 	if err != nil {
 		return nil, fmt.Errorf("s.db.UpdateWallet(ctx, walletID, request): %w", err)
 	}
 
+	if wallet.Currency != currency {
+		s.metrics.TrackConversion(currency, wallet.Currency)
+	}
+
 	return wallet, nil
 }
 
@@ -202,6 +429,15 @@ func (s *Service) GetTransactions(ctx context.Context, params model.GetParams) (
 	return transactions, nil
 }
 
+func (s *Service) GetAuditLog(ctx context.Context, params model.AuditParams) ([]*model.AuditEntry, error) {
+	entries, err := s.db.GetAuditEntries(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetAuditEntries(ctx, params): %w", err)
+	}
+
+	return entries, nil
+}
+
 func (s *Service) ArchiverRun(ctx context.Context) error {
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
@@ -209,10 +445,12 @@ func (s *Service) ArchiverRun(ctx context.Context) error {
 	for {
 		select {
 		case <-ticker.C:
-			_, err := s.db.DisableInactiveWallets(ctx)
+			wallets, err := s.db.DisableInactiveWallets(ctx)
 			if err != nil {
 				return fmt.Errorf("pg.TrackInactiveWallets: %w", err)
 			}
+
+			s.metrics.TrackWalletsArchived(len(wallets))
 		case <-ctx.Done():
 			return nil
 		}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	streamBatchSize     = 100
+	streamRelistenDelay = time.Second
+	// events held back until older transactions end are released by
+	// transactions that need not write events, so nothing wakes streams up
+	streamPollInterval = time.Second
+)
+
+// hub wakes up every open wallet event stream when a new event is written.
+// Streams then read what concerns them from the store, so a wake-up carries
+// no data and missed wake-ups are coalesced.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[chan struct{}]struct{})}
+}
+
+func (h *hub) subscribe() (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	h.subscribers[wake] = struct{}{}
+	h.mu.Unlock()
+
+	return wake, func() {
+		h.mu.Lock()
+		delete(h.subscribers, wake)
+		h.mu.Unlock()
+	}
+}
+
+func (h *hub) broadcast(_ int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StreamRun listens for new wallet events until ctx is done, reconnecting
+// when the listening connection fails.
+func (s *Service) StreamRun(ctx context.Context) error {
+	for {
+		err := s.db.ListenWalletEvents(ctx, s.hub.broadcast)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		logger.FromContext(ctx).With(zap.Error(err)).Warn("StreamRun/s.db.ListenWalletEvents(ctx, s.hub.broadcast)")
+
+		select {
+		case <-time.After(streamRelistenDelay):
+			// events may have been written while not listening
+			s.hub.broadcast(0)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// WalletEvents streams events of the caller's wallets until ctx is done.
+// Events after lastEventID are replayed first; without it the stream starts
+// with the next event.
+func (s *Service) WalletEvents(ctx context.Context, lastEventID *int64) (<-chan model.Event, error) {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, model.ErrUserInfoNotOk
+	}
+
+	// subscribe first so nothing written meanwhile is missed
+	wake, unsubscribe := s.hub.subscribe()
+
+	var after int64
+
+	if lastEventID != nil {
+		after = *lastEventID
+	} else {
+		latest, err := s.db.GetLatestEventSequence(ctx)
+		if err != nil {
+			unsubscribe()
+
+			return nil, fmt.Errorf("s.db.GetLatestEventSequence(ctx): %w", err)
+		}
+
+		after = latest
+	}
+
+	events := make(chan model.Event)
+
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+
+		poll := time.NewTicker(streamPollInterval)
+		defer poll.Stop()
+
+		for {
+			batch, err := s.db.GetWalletEvents(ctx, userInfo.ID, after, streamBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.FromContext(ctx).With(zap.Error(err)).Warn("WalletEvents/s.db.GetWalletEvents(...)")
+				}
+
+				return
+			}
+
+			for _, event := range batch {
+				select {
+				case events <- event:
+					after = event.Sequence
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if len(batch) == streamBatchSize {
+				continue
+			}
+
+			select {
+			case <-wake:
+			case <-poll.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
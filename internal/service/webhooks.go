@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+)
+
+const webhookSecretBytes = 32
+
+func (s *Service) CreateWebhook(
+	ctx context.Context,
+	subscription model.WebhookSubscription,
+) (*model.WebhookSubscription, error) {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, model.ErrUserInfoNotOk
+	}
+
+	if err := subscription.Validate(); err != nil {
+		return nil, fmt.Errorf("subscription.Validate(): %w", err)
+	}
+
+	if subscription.Secret == "" {
+		secret := make([]byte, webhookSecretBytes)
+
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("rand.Read(secret): %w", err)
+		}
+
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	if subscription.Events == nil {
+		subscription.Events = []string{}
+	}
+
+	subscription.ID = uuid.New()
+	subscription.OwnerID = userInfo.ID
+
+	created, err := s.db.CreateWebhookSubscription(ctx, subscription)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.CreateWebhookSubscription(ctx, subscription): %w", err)
+	}
+
+	return created, nil
+}
+
+func (s *Service) GetWebhooks(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, model.ErrUserInfoNotOk
+	}
+
+	subscriptions, err := s.db.GetWebhookSubscriptions(ctx, userInfo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetWebhookSubscriptions(ctx, userInfo.ID): %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, subscriptionID uuid.UUID) error {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return model.ErrUserInfoNotOk
+	}
+
+	err := s.db.DeleteWebhookSubscription(ctx, userInfo.ID, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("s.db.DeleteWebhookSubscription(ctx, userInfo.ID, subscriptionID): %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetWebhookDeliveries(
+	ctx context.Context,
+	subscriptionID uuid.UUID,
+	params model.DeliveryParams,
+) ([]*model.WebhookDelivery, error) {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, model.ErrUserInfoNotOk
+	}
+
+	deliveries, err := s.db.GetWebhookDeliveries(ctx, userInfo.ID, subscriptionID, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetWebhookDeliveries(ctx, userInfo.ID, subscriptionID, params): %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Service) RetryWebhookDelivery(ctx context.Context, deliveryID uuid.UUID) error {
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return model.ErrUserInfoNotOk
+	}
+
+	err := s.db.RetryWebhookDelivery(ctx, userInfo.ID, deliveryID)
+	if err != nil {
+		return fmt.Errorf("s.db.RetryWebhookDelivery(ctx, userInfo.ID, deliveryID): %w", err)
+	}
+
+	return nil
+}
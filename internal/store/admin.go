@@ -0,0 +1,409 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+func (p *Postgres) SearchUsers(ctx context.Context, email string, params model.GetParams) ([]*model.User, error) {
+	users := make([]*model.User, 0, 1)
+
+	query := `
+	SELECT id, email, role, registered_at
+	FROM users
+	WHERE email ILIKE '%' || $1 || '%'`
+
+	order, err := orderBy(params, userColumns)
+	if err != nil {
+		return nil, fmt.Errorf("orderBy(params, userColumns): %w", err)
+	}
+
+	query += order + fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		email)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, email): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := new(model.User)
+
+		err = rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Role,
+			&user.RegDate)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		users = append(users, user)
+	}
+
+	err = p.writeAuditEntry(ctx, p.db, model.AuditEntry{
+		Action:     model.AuditActionSearchUsers,
+		EntityType: model.AuditEntityUser,
+		Details:    map[string]any{"email": email},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	return users, nil
+}
+
+func (p *Postgres) GetWalletsByOwner(ctx context.Context, ownerID uuid.UUID, params model.GetParams) ([]*model.Wallet, error) {
+	wallets := make([]*model.Wallet, 0, 1)
+
+	query := `
+	SELECT id, owner_id, currency, balance, created_at, modified_at, name, status, status_reason
+	FROM wallets
+	WHERE owner_id = $1`
+
+	if params.Filter != "" {
+		query += " AND name LIKE '%' || $2 || '%'"
+	}
+
+	order, err := orderBy(params, walletColumns)
+	if err != nil {
+		return nil, fmt.Errorf("orderBy(params, walletColumns): %w", err)
+	}
+
+	query += order + fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		filterArgs(params, ownerID)...)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, ownerID): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		wallet := new(model.Wallet)
+
+		err = rows.Scan(
+			&wallet.ID,
+			&wallet.OwnerID,
+			&wallet.Currency,
+			&wallet.Balance,
+			&wallet.CreatedDate,
+			&wallet.ModifiedDate,
+			&wallet.Name,
+			&wallet.Status,
+			&wallet.StatusReason)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		wallets = append(wallets, wallet)
+	}
+
+	err = p.writeAuditEntry(ctx, p.db, model.AuditEntry{
+		Action:     model.AuditActionViewWallets,
+		EntityType: model.AuditEntityUser,
+		EntityID:   &ownerID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	return wallets, nil
+}
+
+func (p *Postgres) GetTransactionsByOwner(
+	ctx context.Context,
+	ownerID uuid.UUID,
+	params model.GetParams,
+) ([]*model.Transaction, error) {
+	transactions := make([]*model.Transaction, 0, 1)
+
+	query := `
+	SELECT
+		transactions.id,
+		transactions.from_wallet_id,
+		transactions.to_wallet_id,
+		transactions.currency,
+		transactions.balance,
+		transactions.created_at,
+		transactions.type
+	FROM
+		transactions
+	LEFT JOIN
+		wallets AS sender_wallet ON transactions.from_wallet_id = sender_wallet.id
+	LEFT JOIN
+		wallets AS receiver_wallet ON transactions.to_wallet_id = receiver_wallet.id
+	WHERE
+		(sender_wallet.owner_id = $1 OR receiver_wallet.owner_id = $1)`
+
+	if params.Filter != "" {
+		query += " AND transactions.currency LIKE '%' || $2 || '%'"
+	}
+
+	order, err := orderBy(params, transactionColumns)
+	if err != nil {
+		return nil, fmt.Errorf("orderBy(params, transactionColumns): %w", err)
+	}
+
+	query += order + fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		filterArgs(params, ownerID)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, ownerID): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transaction := new(model.Transaction)
+
+		err = rows.Scan(
+			&transaction.ID,
+			&transaction.AgentWalletID,
+			&transaction.TargetWalletID,
+			&transaction.Currency,
+			&transaction.Sum,
+			&transaction.CreatedAt,
+			&transaction.Type)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	err = p.writeAuditEntry(ctx, p.db, model.AuditEntry{
+		Action:     model.AuditActionViewTransactions,
+		EntityType: model.AuditEntityUser,
+		EntityID:   &ownerID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (p *Postgres) SetWalletStatus(
+	ctx context.Context,
+	walletID uuid.UUID,
+	status model.WalletStatus,
+	reason string,
+) (*model.Wallet, error) {
+	ctx, done := p.observe(ctx, "SetWalletStatus")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("SetWalletStatus/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	SELECT status
+	FROM wallets
+	WHERE id = $1
+	FOR UPDATE`
+
+	var previousStatus model.WalletStatus
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		walletID,
+	).Scan(
+		&previousStatus)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, model.ErrWalletNotFound
+	case err != nil:
+		return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
+	case !previousStatus.CanTransitionTo(status):
+		return nil, model.ErrStatusTransition
+	}
+
+	query = `
+	UPDATE wallets
+	SET status = $2, status_reason = $3, modified_at = $4
+	WHERE id = $1
+	RETURNING id, owner_id, currency, balance, created_at, modified_at, name, status, status_reason`
+
+	wallet := new(model.Wallet)
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		walletID, status, reason, time.Now(),
+	).Scan(
+		&wallet.ID,
+		&wallet.OwnerID,
+		&wallet.Currency,
+		&wallet.Balance,
+		&wallet.CreatedDate,
+		&wallet.ModifiedDate,
+		&wallet.Name,
+		&wallet.Status,
+		&wallet.StatusReason,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
+	}
+
+	err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+		Action:     model.AuditActionChangeStatus,
+		EntityType: model.AuditEntityWallet,
+		EntityID:   &wallet.ID,
+		Reason:     reason,
+		Before:     map[string]any{"status": previousStatus},
+		After:      wallet,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, model.EventStatusChanged, wallet.ID, model.WalletStatusChangedV1{
+		WalletID:       wallet.ID,
+		Status:         wallet.Status,
+		PreviousStatus: previousStatus,
+		Reason:         reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return wallet, nil
+}
+
+func (p *Postgres) AdjustBalance(ctx context.Context, adjustment model.BalanceAdjustment) (*uuid.UUID, error) {
+	ctx, done := p.observe(ctx, "AdjustBalance")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("AdjustBalance/tx.Rollback(ctx)")
+		}
+	}()
+
+	// Adjustments obey the wallet status like any other money movement
+	err = checkWalletStatus(ctx, tx, adjustment.WalletID, adjustment.Sum < 0)
+	if err != nil {
+		return nil, fmt.Errorf("checkWalletStatus(ctx, tx, adjustment.WalletID, ...): %w", err)
+	}
+
+	query := `
+	UPDATE wallets
+	SET balance = balance + $1, modified_at = $3
+	WHERE id = $2
+	RETURNING currency, balance`
+
+	var (
+		currency string
+		balance  float64
+		pgErr    *pgconn.PgError
+	)
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		adjustment.Sum, adjustment.WalletID, time.Now(),
+	).Scan(
+		&currency,
+		&balance)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, model.ErrWalletNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation:
+		return nil, model.ErrNotEnoughBalance
+	case err != nil:
+		return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
+	}
+
+	// Save transaction
+	query = `
+	INSERT INTO transactions (id, to_wallet_id, currency, balance, type)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		adjustment.ID, adjustment.WalletID, currency, adjustment.Sum, model.TransactionTypeAdjustment)
+
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return nil, model.ErrDuplicateTransaction
+	case err != nil:
+		return nil, fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+		Action:     model.AuditActionAdjustBalance,
+		EntityType: model.AuditEntityWallet,
+		EntityID:   &adjustment.WalletID,
+		Reason:     adjustment.Reason,
+		Details: map[string]any{
+			"transactionId": adjustment.ID,
+			"sum":           adjustment.Sum,
+			"currency":      currency,
+		},
+		Before: map[string]any{"balance": balance - adjustment.Sum},
+		After:  map[string]any{"balance": balance},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, model.EventBalanceAdjusted, adjustment.WalletID, model.BalanceAdjustedV1{
+		TransactionID: adjustment.ID,
+		WalletID:      adjustment.WalletID,
+		Currency:      currency,
+		Sum:           adjustment.Sum,
+		Balance:       balance,
+		Reason:        adjustment.Reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return &adjustment.ID, nil
+}
@@ -0,0 +1,197 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// writeAuditEntry appends entry to the audit log. Actor and request details
+// are taken from ctx; entries written outside a request have no actor.
+func (p *Postgres) writeAuditEntry(ctx context.Context, db execer, entry model.AuditEntry) error {
+	if userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo); ok {
+		entry.ActorID = &userInfo.ID
+	}
+
+	if requestInfo, ok := ctx.Value(model.RequestInfoKey).(model.RequestInfo); ok {
+		entry.RequestID = requestInfo.RequestID
+		entry.ClientIP = requestInfo.ClientIP
+	}
+
+	query := `
+	INSERT INTO audit_log (
+		id, actor_id, action, entity_type, entity_id, reason, details,
+		before_state, after_state, request_id, client_ip)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := db.Exec(
+		ctx,
+		query,
+		uuid.New(), entry.ActorID, entry.Action, entry.EntityType, entry.EntityID, entry.Reason, entry.Details,
+		entry.Before, entry.After, entry.RequestID, entry.ClientIP)
+	if err != nil {
+		return fmt.Errorf("db.Exec(...): %w", err)
+	}
+
+	return nil
+}
+
+// writeBalanceAuditEntry records a balance change of delta that left the wallet at balanceAfter.
+func (p *Postgres) writeBalanceAuditEntry(
+	ctx context.Context,
+	db execer,
+	action string,
+	walletID, transactionID uuid.UUID,
+	delta, balanceAfter float64,
+) error {
+	return p.writeAuditEntry(ctx, db, model.AuditEntry{
+		Action:     action,
+		EntityType: model.AuditEntityWallet,
+		EntityID:   &walletID,
+		Details:    map[string]any{"transactionId": transactionID},
+		Before:     map[string]any{"balance": balanceAfter - delta},
+		After:      map[string]any{"balance": balanceAfter},
+	})
+}
+
+func (p *Postgres) GetAuditEntries(ctx context.Context, params model.AuditParams) ([]*model.AuditEntry, error) {
+	entries := make([]*model.AuditEntry, 0, 1)
+
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, model.ErrUserInfoNotOk
+	}
+
+	query := `
+	SELECT
+		id, created_at, actor_id, action, entity_type, entity_id, COALESCE(reason, ''), details,
+		before_state, after_state, COALESCE(request_id, ''), COALESCE(client_ip, '')
+	FROM audit_log
+	WHERE true`
+
+	args := make([]any, 0)
+
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		query += fmt.Sprintf(" AND "+condition, len(args))
+	}
+
+	if !userInfo.IsAdmin() {
+		// entries of someone else's actions on the caller's wallets are visible,
+		// but who did them is not, see redactAuditEntry
+		if params.ActorID != uuid.Nil && params.ActorID != userInfo.ID {
+			return entries, nil
+		}
+
+		addCondition(
+			"entity_type = 'wallet' AND entity_id IN (SELECT id FROM wallets WHERE owner_id = $%d)",
+			userInfo.ID)
+	}
+
+	if params.Action != "" {
+		addCondition("action = $%d", params.Action)
+	}
+
+	if params.EntityType != "" {
+		addCondition("entity_type = $%d", params.EntityType)
+	}
+
+	if params.EntityID != uuid.Nil {
+		addCondition("entity_id = $%d", params.EntityID)
+	}
+
+	if params.ActorID != uuid.Nil {
+		addCondition("actor_id = $%d", params.ActorID)
+	}
+
+	if !params.From.IsZero() {
+		addCondition("created_at >= $%d", params.From)
+	}
+
+	if !params.To.IsZero() {
+		addCondition("created_at < $%d", params.To)
+	}
+
+	query += " ORDER BY created_at"
+	if params.Descending {
+		query += " DESC"
+	}
+
+	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, args...): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry         model.AuditEntry
+			before, after json.RawMessage
+		)
+
+		err = rows.Scan(
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&entry.Reason,
+			&entry.Details,
+			&before,
+			&after,
+			&entry.RequestID,
+			&entry.ClientIP)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		if before != nil {
+			entry.Before = before
+		}
+
+		if after != nil {
+			entry.After = after
+		}
+
+		if !userInfo.IsAdmin() {
+			redactAuditEntry(&entry, userInfo.ID)
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return entries, nil
+}
+
+// redactAuditEntry hides who made the change and from where unless it was the viewer.
+// A transfer recipient must not learn the sender's request details, nor a
+// customer those of the support staff.
+func redactAuditEntry(entry *model.AuditEntry, viewerID uuid.UUID) {
+	if entry.ActorID != nil && *entry.ActorID == viewerID {
+		return
+	}
+
+	entry.ActorID = nil
+	entry.RequestID = ""
+	entry.ClientIP = ""
+}
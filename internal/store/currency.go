@@ -0,0 +1,160 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// currencyConstraint is the foreign key of wallets to the currency registry.
+const currencyConstraint = "fk_wallets_currency"
+
+func (p *Postgres) GetCurrencies(ctx context.Context) ([]*model.Currency, error) {
+	currencies := make([]*model.Currency, 0, 1)
+
+	query := `
+	SELECT code, coalesce(numeric_code, 0), minor_units, symbol, is_enabled
+	FROM currencies
+	ORDER BY code`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		currency := new(model.Currency)
+
+		err = rows.Scan(
+			&currency.Code,
+			&currency.NumericCode,
+			&currency.MinorUnits,
+			&currency.Symbol,
+			&currency.IsEnabled)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		currencies = append(currencies, currency)
+	}
+
+	return currencies, nil
+}
+
+func (p *Postgres) GetCurrency(ctx context.Context, code string) (*model.Currency, error) {
+	query := `
+	SELECT code, coalesce(numeric_code, 0), minor_units, symbol, is_enabled
+	FROM currencies
+	WHERE code = $1`
+
+	return scanCurrency(p.db.QueryRow(ctx, query, code))
+}
+
+// UpsertCurrency creates the currency or replaces its metadata.
+func (p *Postgres) UpsertCurrency(ctx context.Context, currency model.Currency) (*model.Currency, error) {
+	ctx, done := p.observe(ctx, "UpsertCurrency")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("UpsertCurrency/tx.Rollback(ctx)")
+		}
+	}()
+
+	var before any
+
+	query := `
+	SELECT code, coalesce(numeric_code, 0), minor_units, symbol, is_enabled
+	FROM currencies
+	WHERE code = $1
+	FOR UPDATE`
+
+	previous, err := scanCurrency(tx.QueryRow(ctx, query, currency.Code))
+
+	switch {
+	case errors.Is(err, model.ErrCurrencyNotFound):
+		break
+	case err != nil:
+		return nil, fmt.Errorf("scanCurrency(tx.QueryRow(ctx, query, currency.Code)): %w", err)
+	default:
+		before = previous
+	}
+
+	query = `
+	INSERT INTO currencies (code, numeric_code, minor_units, symbol, is_enabled)
+	VALUES ($1, nullif($2, 0), $3, $4, $5)
+	ON CONFLICT (code) DO UPDATE
+	SET numeric_code = excluded.numeric_code,
+		minor_units = excluded.minor_units,
+		symbol = excluded.symbol,
+		is_enabled = excluded.is_enabled,
+		modified_at = $6`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		currency.Code, currency.NumericCode, currency.MinorUnits, currency.Symbol, currency.IsEnabled, time.Now())
+
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return nil, model.ErrDuplicateNumericCode
+	case err != nil:
+		return nil, fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+		Action:     model.AuditActionUpsertCurrency,
+		EntityType: model.AuditEntityCurrency,
+		Details:    map[string]any{"code": currency.Code},
+		Before:     before,
+		After:      currency,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return &currency, nil
+}
+
+func scanCurrency(row pgx.Row) (*model.Currency, error) {
+	currency := new(model.Currency)
+
+	err := row.Scan(
+		&currency.Code,
+		&currency.NumericCode,
+		&currency.MinorUnits,
+		&currency.Symbol,
+		&currency.IsEnabled)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, model.ErrCurrencyNotFound
+	case err != nil:
+		return nil, fmt.Errorf("row.Scan(...): %w", err)
+	}
+
+	return currency, nil
+}
@@ -0,0 +1,28 @@
+-- +migrate Up
+
+ALTER TABLE users ADD COLUMN role varchar not null default 'user';
+
+ALTER TABLE wallets ADD COLUMN is_frozen boolean not null default false;
+
+CREATE TABLE audit_log
+(
+    id          uuid not null unique primary key,
+    created_at  timestamp with time zone default now(),
+    actor_id    uuid not null references users (id),
+    action      varchar not null,
+    entity_type varchar not null,
+    entity_id   uuid,
+    reason      varchar,
+    details     jsonb
+);
+
+CREATE INDEX idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE INDEX idx_audit_log_entity_id ON audit_log (entity_id);
+
+-- +migrate Down
+
+DROP TABLE audit_log;
+
+ALTER TABLE wallets DROP COLUMN is_frozen;
+
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,42 @@
+-- +migrate Up
+
+ALTER TABLE wallets
+    ADD COLUMN status varchar not null default 'active'
+        CHECK ( status IN ('active', 'frozen_debit', 'frozen', 'closed', 'archived') ),
+    ADD COLUMN status_reason varchar not null default '';
+
+-- is_disabled was set both by the archiver and by user deletion, and nothing
+-- recorded which one it was. Every disabled wallet becomes archived: it stays
+-- unusable, but unlike closed it can still be re-enabled by support, so a
+-- wrong guess costs a support ticket rather than a wallet lost for good.
+UPDATE wallets
+SET status = 'archived', status_reason = 'disabled before wallet statuses'
+WHERE is_disabled = true;
+
+UPDATE wallets
+SET status = 'frozen'
+WHERE is_disabled = false AND is_frozen = true;
+
+ALTER TABLE wallets
+    DROP COLUMN is_disabled,
+    DROP COLUMN is_frozen;
+
+CREATE INDEX idx_wallets_status_id ON wallets (status, id);
+CREATE INDEX idx_wallets_status_owner_id ON wallets (status, owner_id);
+
+-- +migrate Down
+
+ALTER TABLE wallets
+    ADD COLUMN is_disabled boolean not null default false,
+    ADD COLUMN is_frozen boolean not null default false;
+
+UPDATE wallets
+SET is_disabled = status IN ('closed', 'archived'),
+    is_frozen = status IN ('frozen_debit', 'frozen');
+
+CREATE INDEX idx_wallets_is_disabled_id ON wallets (is_disabled, id);
+CREATE INDEX idx_wallets_is_disabled_owner_id ON wallets (is_disabled, owner_id);
+
+ALTER TABLE wallets
+    DROP COLUMN status,
+    DROP COLUMN status_reason;
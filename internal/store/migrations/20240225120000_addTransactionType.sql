@@ -0,0 +1,21 @@
+-- +migrate Up
+
+ALTER TABLE transactions
+    ADD COLUMN type varchar not null default 'transfer'
+        CHECK ( type IN ('transfer', 'deposit', 'withdrawal', 'adjustment', 'sweep', 'close') );
+
+UPDATE transactions
+SET type = CASE WHEN balance < 0 THEN 'withdrawal' ELSE 'deposit' END
+WHERE from_wallet_id IS NULL;
+
+UPDATE transactions
+SET type = 'adjustment'
+WHERE id IN (
+    SELECT (details ->> 'transactionId')::uuid
+    FROM audit_log
+    WHERE action = 'wallet.adjustBalance'
+);
+
+-- +migrate Down
+
+ALTER TABLE transactions DROP COLUMN type;
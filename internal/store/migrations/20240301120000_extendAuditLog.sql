@@ -0,0 +1,39 @@
+-- +migrate Up
+
+ALTER TABLE audit_log
+    ALTER COLUMN actor_id DROP NOT NULL,
+    ADD COLUMN before_state jsonb,
+    ADD COLUMN after_state  jsonb,
+    ADD COLUMN request_id   varchar,
+    ADD COLUMN client_ip    varchar;
+
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+
+-- +migrate StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE
+    ON audit_log
+    FOR EACH ROW
+EXECUTE FUNCTION audit_log_append_only();
+
+-- +migrate Down
+
+DROP TRIGGER audit_log_append_only ON audit_log;
+
+DROP FUNCTION audit_log_append_only();
+
+DROP INDEX idx_audit_log_created_at;
+
+ALTER TABLE audit_log
+    DROP COLUMN before_state,
+    DROP COLUMN after_state,
+    DROP COLUMN request_id,
+    DROP COLUMN client_ip;
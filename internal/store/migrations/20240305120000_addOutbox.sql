@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE outbox
+(
+    id           bigserial not null primary key,
+    event_id     uuid not null unique,
+    type         varchar not null,
+    version      integer not null,
+    aggregate_id uuid not null,
+    payload      jsonb not null,
+    created_at   timestamp with time zone default now(),
+    published_at timestamp with time zone
+);
+
+CREATE INDEX idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_aggregate_id ON outbox (aggregate_id);
+
+-- +migrate Down
+
+DROP TABLE outbox;
@@ -0,0 +1,37 @@
+-- +migrate Up
+
+CREATE TABLE webhook_subscriptions
+(
+    id         uuid not null unique primary key,
+    owner_id   uuid not null references users (id),
+    url        varchar not null,
+    events     varchar[] not null default '{}',
+    secret     varchar not null,
+    is_active  boolean not null default true,
+    created_at timestamp with time zone default now()
+);
+
+CREATE INDEX idx_webhook_subscriptions_owner_id ON webhook_subscriptions (owner_id);
+
+CREATE TABLE webhook_deliveries
+(
+    id               uuid not null unique primary key,
+    subscription_id  uuid not null references webhook_subscriptions (id),
+    event            varchar not null,
+    payload          jsonb not null,
+    status           varchar not null default 'pending'
+        CHECK ( status IN ('pending', 'succeeded', 'dead') ),
+    attempts         integer not null default 0,
+    next_attempt_at  timestamp with time zone not null default now(),
+    last_status_code integer,
+    last_error       varchar,
+    created_at       timestamp with time zone default now(),
+    delivered_at     timestamp with time zone
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id, created_at);
+
+-- +migrate Down
+
+DROP TABLE webhook_deliveries, webhook_subscriptions;
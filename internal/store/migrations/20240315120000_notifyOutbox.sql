@@ -0,0 +1,20 @@
+-- +migrate Up
+
+-- +migrate StatementBegin
+CREATE FUNCTION outbox_notify() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('wallet_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE TRIGGER outbox_notify
+    AFTER INSERT ON outbox
+    FOR EACH ROW EXECUTE FUNCTION outbox_notify();
+
+-- +migrate Down
+
+DROP TRIGGER outbox_notify ON outbox;
+DROP FUNCTION outbox_notify();
@@ -0,0 +1,40 @@
+-- +migrate Up
+
+CREATE TABLE currencies
+(
+    code         varchar(3) not null unique primary key
+        CHECK ( code ~ '^[A-Z]{3}$' ),
+    numeric_code smallint unique
+        CHECK ( numeric_code BETWEEN 1 AND 999 ),
+    minor_units  smallint not null default 2
+        CHECK ( minor_units BETWEEN 0 AND 4 ),
+    symbol       varchar not null default '',
+    is_enabled   boolean not null default true,
+    created_at   timestamp with time zone default now(),
+    modified_at  timestamp with time zone default now()
+);
+
+-- the currencies quoted by the rate server
+INSERT INTO currencies (code, numeric_code, minor_units, symbol)
+VALUES ('EUR', 978, 2, '€'),
+       ('IDR', 360, 2, 'Rp'),
+       ('KZT', 398, 2, '₸'),
+       ('RUB', 643, 2, '₽'),
+       ('USD', 840, 2, '$');
+
+-- wallets in any other currency keep working, but no new ones are opened
+INSERT INTO currencies (code, is_enabled)
+SELECT DISTINCT currency, false
+FROM wallets
+WHERE currency ~ '^[A-Z]{3}$'
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT fk_wallets_currency FOREIGN KEY (currency) REFERENCES currencies (code) NOT VALID;
+
+-- +migrate Down
+
+ALTER TABLE wallets
+    DROP CONSTRAINT fk_wallets_currency;
+
+DROP TABLE currencies;
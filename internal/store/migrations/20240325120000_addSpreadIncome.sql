@@ -0,0 +1,17 @@
+-- +migrate Up
+
+CREATE TABLE spread_income
+(
+    id             bigserial primary key,
+    created_at     timestamp with time zone not null default now(),
+    transaction_id uuid references transactions (id),
+    wallet_id      uuid                     not null references wallets (id),
+    currency       varchar                  not null,
+    amount         numeric                  not null CHECK ( amount >= 0 )
+);
+
+CREATE INDEX idx_spread_income_created_at ON spread_income (created_at);
+
+-- +migrate Down
+
+DROP TABLE spread_income;
@@ -0,0 +1,14 @@
+-- +migrate Up
+
+-- Outbox ids are taken when a row is inserted, not when it commits, so
+-- readers following ids can skip rows committed late. The id of the writing
+-- transaction lets readers see rows in the order no later commit can precede.
+ALTER TABLE outbox
+    ADD COLUMN xid xid8 not null default pg_current_xact_id();
+
+CREATE INDEX idx_outbox_xid_id ON outbox (xid, id);
+
+-- +migrate Down
+
+ALTER TABLE outbox
+    DROP COLUMN xid;
@@ -0,0 +1,135 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// writeOutboxEvent stores an event to be published by the relay. It must be
+// called with the transaction that makes the change the event describes.
+func (p *Postgres) writeOutboxEvent(
+	ctx context.Context,
+	db execer,
+	eventType string,
+	aggregateID uuid.UUID,
+	payload any,
+) error {
+	query := `
+	INSERT INTO outbox (event_id, type, version, aggregate_id, payload)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := db.Exec(
+		ctx,
+		query,
+		uuid.New(), eventType, model.EventSchemaVersion, aggregateID, payload)
+	if err != nil {
+		return fmt.Errorf("db.Exec(...): %w", err)
+	}
+
+	return nil
+}
+
+// PublishOutbox hands up to limit unpublished events to publish in creation order
+// and marks them published when it succeeds. Rows are locked with SKIP LOCKED,
+// so relays of several replicas never publish the same batch concurrently.
+func (p *Postgres) PublishOutbox(
+	ctx context.Context,
+	limit int,
+	publish func(ctx context.Context, events []model.Event) error,
+) (int, error) {
+	ctx, done := p.observe(ctx, "PublishOutbox")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("PublishOutbox/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	SELECT id, event_id, type, version, aggregate_id, created_at, payload
+	FROM outbox
+	WHERE published_at IS NULL
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(
+		ctx,
+		query,
+		limit)
+	if err != nil {
+		return 0, fmt.Errorf("tx.Query(ctx, query, limit): %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]model.Event, 0, limit)
+	ids := make([]int64, 0, limit)
+
+	for rows.Next() {
+		var event model.Event
+
+		err = rows.Scan(
+			&event.Sequence,
+			&event.ID,
+			&event.Type,
+			&event.Version,
+			&event.AggregateID,
+			&event.OccurredAt,
+			&event.Payload)
+		if err != nil {
+			return 0, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		events = append(events, event)
+		ids = append(ids, event.Sequence)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	rows.Close()
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err = publish(ctx, events); err != nil {
+		return 0, fmt.Errorf("publish(ctx, events): %w", err)
+	}
+
+	query = `
+	UPDATE outbox
+	SET published_at = $2
+	WHERE id = ANY($1)`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		ids, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,66 @@
+//go:build !MySql
+
+package store
+
+import (
+	"fmt"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+// Columns listings may be ordered by, keyed by the sorting parameter.
+var (
+	userColumns = map[string]string{
+		"id":            "id",
+		"email":         "email",
+		"role":          "role",
+		"registered_at": "registered_at",
+	}
+
+	walletColumns = map[string]string{
+		"id":          "id",
+		"name":        "name",
+		"currency":    "currency",
+		"balance":     "balance",
+		"status":      "status",
+		"created_at":  "created_at",
+		"modified_at": "modified_at",
+	}
+
+	transactionColumns = map[string]string{
+		"id":         "transactions.id",
+		"currency":   "transactions.currency",
+		"balance":    "transactions.balance",
+		"created_at": "transactions.created_at",
+		"type":       "transactions.type",
+	}
+)
+
+// orderBy returns the ORDER BY clause of params. Sorting is spliced into
+// the query, so only the known columns are accepted.
+func orderBy(params model.GetParams, columns map[string]string) (string, error) {
+	if params.Sorting == "" {
+		return "", nil
+	}
+
+	column, ok := columns[params.Sorting]
+	if !ok {
+		return "", fmt.Errorf("%q: %w", params.Sorting, model.ErrInvalidSorting)
+	}
+
+	if params.Descending {
+		return " ORDER BY " + column + " DESC", nil
+	}
+
+	return " ORDER BY " + column, nil
+}
+
+// filterArgs are the query arguments of a listing, args followed by the
+// filter when there is one.
+func filterArgs(params model.GetParams, args ...any) []any {
+	if params.Filter != "" {
+		args = append(args, params.Filter)
+	}
+
+	return args
+}
@@ -0,0 +1,82 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+)
+
+// writeSpreadIncome records what the conversions of a transaction earned,
+// transactionID is nil for conversions that are not transactions.
+func (p *Postgres) writeSpreadIncome(
+	ctx context.Context,
+	db execer,
+	transactionID *uuid.UUID,
+	incomes ...model.SpreadIncome,
+) error {
+	query := `
+	INSERT INTO spread_income (transaction_id, wallet_id, currency, amount)
+	VALUES ($1, $2, $3, $4)`
+
+	for _, income := range incomes {
+		if income.Amount == 0 {
+			continue
+		}
+
+		_, err := db.Exec(ctx, query, transactionID, income.WalletID, income.Currency, income.Amount)
+		if err != nil {
+			return fmt.Errorf("db.Exec(...): %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetSpreadIncome(ctx context.Context, params model.ReportParams) ([]*model.SpreadIncomeReport, error) {
+	reports := make([]*model.SpreadIncomeReport, 0, 1)
+
+	query := `
+	SELECT currency, sum(amount), count(*)
+	FROM spread_income
+	WHERE true`
+
+	args := make([]any, 0)
+
+	if !params.From.IsZero() {
+		args = append(args, params.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+
+	if !params.To.IsZero() {
+		args = append(args, params.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	query += " GROUP BY currency ORDER BY currency"
+
+	rows, err := p.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, args...): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report model.SpreadIncomeReport
+
+		if err = rows.Scan(&report.Currency, &report.Amount, &report.Conversions); err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		reports = append(reports, &report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return reports, nil
+}
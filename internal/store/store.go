@@ -4,10 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"net/url"
+	"sort"
+	"time"
 
 	"github.com/Saaghh/wallet/internal/config"
+	"github.com/Saaghh/wallet/internal/tracing"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	migrate "github.com/rubenv/sql-migrate"
@@ -15,14 +19,22 @@ import (
 )
 
 type Postgres struct {
-	db  *pgxpool.Pool
-	dsn string
+	db      *pgxpool.Pool
+	dsn     string
+	metrics metrics
+}
+
+type metrics interface {
+	TrackDBTransaction(start time.Time, method string)
+	TrackDBPool(stat func() *pgxpool.Stat)
 }
 
 //go:embed migrations
 var migrations embed.FS
 
-func New(ctx context.Context, cfg *config.Config) (*Postgres, error) {
+var errMigrationVersion = errors.New("migrations are not at the expected version")
+
+func New(ctx context.Context, cfg *config.Config, metrics metrics) (*Postgres, error) {
 	urlScheme := url.URL{
 		Scheme:   "postgres",
 		User:     url.UserPassword(cfg.PGUser, cfg.PGPassword),
@@ -33,9 +45,16 @@ func New(ctx context.Context, cfg *config.Config) (*Postgres, error) {
 
 	dsn := urlScheme.String()
 
-	db, err := pgxpool.New(ctx, dsn)
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("pgxpool.New(ctx, dsn): %w", err)
+		return nil, fmt.Errorf("pgxpool.ParseConfig(dsn): %w", err)
+	}
+
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.NewWithConfig(ctx, poolConfig): %w", err)
 	}
 
 	err = db.Ping(ctx)
@@ -45,9 +64,12 @@ func New(ctx context.Context, cfg *config.Config) (*Postgres, error) {
 
 	zap.L().Info("successfully connected to db")
 
+	metrics.TrackDBPool(db.Stat)
+
 	return &Postgres{
-		db:  db,
-		dsn: dsn,
+		db:      db,
+		dsn:     dsn,
+		metrics: metrics,
 	}, nil
 }
 
@@ -94,3 +116,56 @@ func (p *Postgres) Migrate(direction migrate.MigrationDirection) error {
 
 	return nil
 }
+
+// observe runs a store method in a span, the returned func ends it and
+// tracks how long the method took.
+func (p *Postgres) observe(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+
+	ctx, span := tracing.Child(ctx, "store."+method)
+
+	return ctx, func() {
+		span.End()
+		p.metrics.TrackDBTransaction(start, method)
+	}
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	if err := p.db.Ping(ctx); err != nil {
+		return fmt.Errorf("p.db.Ping(ctx): %w", err)
+	}
+
+	return nil
+}
+
+// CheckMigrations fails when the latest embedded migration is not applied
+// yet.
+func (p *Postgres) CheckMigrations(ctx context.Context) error {
+	dirEntry, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("migrations.ReadDir: %w", err)
+	}
+
+	expected := make([]string, 0, len(dirEntry))
+
+	for _, e := range dirEntry {
+		expected = append(expected, e.Name())
+	}
+
+	sort.Strings(expected)
+
+	var applied string
+
+	// sql-migrate keeps the names of the applied migrations in gorp_migrations
+	err = p.db.QueryRow(ctx, "SELECT COALESCE(MAX(id), '') FROM gorp_migrations").Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("p.db.QueryRow(...).Scan(&applied): %w", err)
+	}
+
+	// names start with a timestamp, a newer deployment may have applied more
+	if len(expected) > 0 && applied < expected[len(expected)-1] {
+		return fmt.Errorf("at %q, expected %q: %w", applied, expected[len(expected)-1], errMigrationVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// walletEventsChannel is notified with the outbox sequence of every new event,
+// see the outbox_notify trigger.
+const walletEventsChannel = "wallet_events"
+
+// ListenWalletEvents calls notify with the sequence of every event written to
+// the outbox by any replica. It blocks until ctx is done or the connection fails.
+func (p *Postgres) ListenWalletEvents(ctx context.Context, notify func(sequence int64)) error {
+	conn, err := p.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Acquire(ctx): %w", err)
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+walletEventsChannel)
+	if err != nil {
+		return fmt.Errorf("conn.Exec(ctx, LISTEN): %w", err)
+	}
+
+	defer func() {
+		// the connection goes back to the pool, it must not keep listening
+		//nolint: contextcheck
+		_, err := conn.Exec(context.Background(), "UNLISTEN "+walletEventsChannel)
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("ListenWalletEvents/conn.Exec(UNLISTEN)")
+		}
+	}()
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+
+		switch {
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			return nil
+		case err != nil:
+			return fmt.Errorf("conn.Conn().WaitForNotification(ctx): %w", err)
+		}
+
+		sequence, err := strconv.ParseInt(notification.Payload, 10, 64)
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("ListenWalletEvents/strconv.ParseInt(notification.Payload, 10, 64)")
+
+			continue
+		}
+
+		notify(sequence)
+	}
+}
+
+// GetWalletEvents returns up to limit events after the given sequence that
+// concern any wallet of the owner, in commit order.
+//
+// Only events of transactions older than every running one are returned:
+// a running transaction may hold a lower sequence and commit later, and a
+// reader that moved past it would never see its event. Ordering by the
+// writing transaction instead of the sequence keeps the cursor valid too.
+func (p *Postgres) GetWalletEvents(
+	ctx context.Context,
+	ownerID uuid.UUID,
+	after int64,
+	limit int,
+) ([]model.Event, error) {
+	events := make([]model.Event, 0, 1)
+
+	query := `
+	WITH last_read AS (
+		SELECT COALESCE((SELECT xid FROM outbox WHERE id = $2), '0'::xid8) AS xid
+	)
+	SELECT o.id, o.event_id, o.type, o.version, o.aggregate_id, o.created_at, o.payload
+	FROM outbox AS o, last_read AS r
+	WHERE (o.xid, o.id) > (r.xid, $2)
+		AND o.xid < pg_snapshot_xmin(pg_current_snapshot())
+		AND EXISTS (
+			SELECT 1
+			FROM wallets AS w
+			WHERE w.owner_id = $1 AND w.id::text IN (
+				o.payload->>'walletId',
+				o.payload->>'fromWalletId',
+				o.payload->>'toWalletId',
+				o.payload->>'sweptTo'
+			)
+		)
+	ORDER BY o.xid, o.id
+	LIMIT $3`
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		ownerID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, ownerID, after, limit): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event model.Event
+
+		err = rows.Scan(
+			&event.Sequence,
+			&event.ID,
+			&event.Type,
+			&event.Version,
+			&event.AggregateID,
+			&event.OccurredAt,
+			&event.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return events, nil
+}
+
+// GetLatestEventSequence returns the sequence of the last event in the
+// order GetWalletEvents reads them, 0 when there is none yet.
+func (p *Postgres) GetLatestEventSequence(ctx context.Context) (int64, error) {
+	var sequence int64
+
+	query := `
+	SELECT COALESCE((
+		SELECT id
+		FROM outbox
+		WHERE xid < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY xid DESC, id DESC
+		LIMIT 1
+	), 0)`
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+	).Scan(
+		&sequence)
+	if err != nil {
+		return 0, fmt.Errorf("p.db.QueryRow(...): %w", err)
+	}
+
+	return sequence, nil
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/Saaghh/wallet/internal/model"
@@ -31,6 +32,13 @@ func (p *Postgres) TruncateTables(ctx context.Context) error {
 		return fmt.Errorf("p.db.Exec(...): %w", err)
 	}
 
+	_, err = p.db.Exec(
+		ctx,
+		"TRUNCATE TABLE outbox")
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(...): %w", err)
+	}
+
 	_, err = p.db.Exec(
 		ctx,
 		"TRUNCATE TABLE users CASCADE")
@@ -43,18 +51,24 @@ func (p *Postgres) TruncateTables(ctx context.Context) error {
 
 func (p *Postgres) CreateUser(ctx context.Context, user model.User) (*model.User, error) {
 	query := `
-	INSERT INTO users (id, email)
-	VALUES ($1, $2)
-	RETURNING id, registered_at
+	INSERT INTO users (id, email, role)
+	VALUES ($1, $2, $3)
+	RETURNING id, role, registered_at
 `
 
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+
 	err := p.db.QueryRow(
 		ctx,
 		query,
 		uuid.New(),
 		user.Email,
+		user.Role,
 	).Scan(
 		&user.ID,
+		&user.Role,
 		&user.RegDate,
 	)
 	if err != nil {
@@ -65,6 +79,9 @@ func (p *Postgres) CreateUser(ctx context.Context, user model.User) (*model.User
 }
 
 func (p *Postgres) CreateWallet(ctx context.Context, wallet model.Wallet) (*model.Wallet, error) {
+	ctx, done := p.observe(ctx, "CreateWallet")
+	defer done()
+
 	if wallet.OwnerID == uuid.Nil {
 		return nil, model.ErrNilUUID
 	}
@@ -72,7 +89,7 @@ func (p *Postgres) CreateWallet(ctx context.Context, wallet model.Wallet) (*mode
 	// Checking if name is free
 	query := `
 	SELECT FROM wallets
-	WHERE is_disabled = false and owner_id = $1 and name = $2`
+	WHERE status NOT IN ('closed', 'archived') and owner_id = $1 and name = $2`
 	err := p.db.QueryRow(
 		ctx,
 		query,
@@ -87,13 +104,25 @@ func (p *Postgres) CreateWallet(ctx context.Context, wallet model.Wallet) (*mode
 		return nil, model.ErrDuplicateWallet
 	}
 
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("CreateWallet/tx.Rollback(ctx)")
+		}
+	}()
+
 	// Creating wallet
 	query = `
     INSERT INTO wallets (id, owner_id, currency, name)
     VALUES ($1, $2, $3, $4)
-    RETURNING id, owner_id, currency, balance, created_at, modified_at`
+    RETURNING id, owner_id, currency, balance, created_at, modified_at, status, status_reason`
 
-	err = p.db.QueryRow(
+	err = tx.QueryRow(
 		ctx,
 		query,
 		uuid.New(),
@@ -107,15 +136,43 @@ func (p *Postgres) CreateWallet(ctx context.Context, wallet model.Wallet) (*mode
 		&wallet.Balance,
 		&wallet.CreatedDate,
 		&wallet.ModifiedDate,
+		&wallet.Status,
+		&wallet.StatusReason,
 	)
 
 	var pgErr *pgconn.PgError
 
 	switch {
+	case errors.As(err, &pgErr) && pgErr.ConstraintName == currencyConstraint:
+		return nil, model.ErrWrongCurrency
 	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation:
 		return nil, model.ErrUserNotFound
 	case err != nil:
-		return nil, fmt.Errorf("p.db.QueryRow(): %w", err)
+		return nil, fmt.Errorf("tx.QueryRow(): %w", err)
+	}
+
+	err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+		Action:     model.AuditActionCreateWallet,
+		EntityType: model.AuditEntityWallet,
+		EntityID:   &wallet.ID,
+		After:      wallet,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, model.EventWalletCreated, wallet.ID, model.WalletCreatedV1{
+		WalletID: wallet.ID,
+		OwnerID:  wallet.OwnerID,
+		Currency: wallet.Currency,
+		Name:     wallet.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
 	}
 
 	return &wallet, nil
@@ -130,27 +187,25 @@ func (p *Postgres) GetWallets(ctx context.Context, params model.GetParams) ([]*m
 	}
 
 	query := `
-	SELECT id, owner_id, currency, balance, created_at, modified_at, name
+	SELECT id, owner_id, currency, balance, created_at, modified_at, name, status, status_reason
 	FROM wallets
-	WHERE is_disabled = false AND owner_id = $1`
+	WHERE status NOT IN ('closed', 'archived') AND owner_id = $1`
 
 	if params.Filter != "" {
-		query += fmt.Sprintf(" AND name LIKE '%%%s%%'", params.Filter)
+		query += " AND name LIKE '%' || $2 || '%'"
 	}
 
-	if params.Sorting != "" {
-		query += " ORDER BY " + params.Sorting
-		if params.Descending {
-			query += " DESC"
-		}
+	order, err := orderBy(params, walletColumns)
+	if err != nil {
+		return nil, fmt.Errorf("orderBy(params, walletColumns): %w", err)
 	}
 
-	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+	query += order + fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
 
 	rows, err := p.db.Query(
 		ctx,
 		query,
-		userInfo.ID)
+		filterArgs(params, userInfo.ID)...)
 	if err != nil {
 		return nil, fmt.Errorf("p.db.Query(ctx, query, owner.ID): %w", err)
 	}
@@ -166,7 +221,9 @@ func (p *Postgres) GetWallets(ctx context.Context, params model.GetParams) ([]*m
 			&wallet.Balance,
 			&wallet.CreatedDate,
 			&wallet.ModifiedDate,
-			&wallet.Name)
+			&wallet.Name,
+			&wallet.Status,
+			&wallet.StatusReason)
 		if err != nil {
 			return nil, fmt.Errorf("err = rows.Scan(...): %w", err)
 		}
@@ -180,9 +237,9 @@ func (p *Postgres) GetWallets(ctx context.Context, params model.GetParams) ([]*m
 func (p *Postgres) GetWalletByID(ctx context.Context, walletID uuid.UUID) (*model.Wallet, error) {
 	wallet := new(model.Wallet)
 	query := `
-	SELECT id, owner_id, currency, balance, created_at, modified_at, name
+	SELECT id, owner_id, currency, balance, created_at, modified_at, name, status, status_reason
 	FROM wallets
-	WHERE is_disabled = false and id = $1`
+	WHERE status NOT IN ('closed', 'archived') and id = $1`
 
 	err := p.db.QueryRow(
 		ctx,
@@ -196,6 +253,8 @@ func (p *Postgres) GetWalletByID(ctx context.Context, walletID uuid.UUID) (*mode
 		&wallet.CreatedDate,
 		&wallet.ModifiedDate,
 		&wallet.Name,
+		&wallet.Status,
+		&wallet.StatusReason,
 	)
 
 	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
@@ -216,9 +275,12 @@ func (p *Postgres) GetWalletByID(ctx context.Context, walletID uuid.UUID) (*mode
 }
 
 func (p *Postgres) UpdateWallet(ctx context.Context, walletID uuid.UUID, request model.UpdateWalletRequest) (*model.Wallet, error) {
-	wallet, err := p.GetWalletByID(ctx, walletID)
-	if err != nil {
-		return nil, fmt.Errorf("p.GetWalletByID(ctx, walletID): %w", err)
+	ctx, done := p.observe(ctx, "UpdateWallet")
+	defer done()
+
+	userInfo, ok := ctx.Value(model.UserInfoKey).(model.UserInfo)
+	if !ok {
+		return nil, model.ErrUserInfoNotOk
 	}
 
 	tx, err := p.db.Begin(ctx)
@@ -233,13 +295,54 @@ func (p *Postgres) UpdateWallet(ctx context.Context, walletID uuid.UUID, request
 		}
 	}()
 
+	// Locking wallet, so that the balance converted is the one in it
+	query := `
+	SELECT id, owner_id, currency, balance, created_at, modified_at, name, status, status_reason
+	FROM wallets
+	WHERE status NOT IN ('closed', 'archived') and id = $1
+	FOR UPDATE`
+
+	wallet := new(model.Wallet)
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		walletID,
+	).Scan(
+		&wallet.ID,
+		&wallet.OwnerID,
+		&wallet.Currency,
+		&wallet.Balance,
+		&wallet.CreatedDate,
+		&wallet.ModifiedDate,
+		&wallet.Name,
+		&wallet.Status,
+		&wallet.StatusReason)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, model.ErrWalletNotFound
+	case err != nil:
+		return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
+	case wallet.OwnerID != userInfo.ID:
+		return nil, model.ErrNotAllowed
+	case request.Currency == nil:
+		break
+	case wallet.Currency != request.FromCurrency:
+		return nil, model.ErrWalletWasChanged
+	case *request.Currency != wallet.Currency && wallet.Status != model.WalletStatusActive:
+		return nil, model.ErrWalletFrozen
+	}
+
+	before := *wallet
+
 	if request.Name != nil {
 		// checking if name is free
 		query := `
 		SELECT FROM wallets
-		WHERE is_disabled = false and owner_id = $1 and name = $2`
+		WHERE status NOT IN ('closed', 'archived') and owner_id = $1 and name = $2`
 
-		err := p.db.QueryRow(
+		err := tx.QueryRow(
 			ctx,
 			query,
 			wallet.OwnerID, request.Name).Scan()
@@ -248,7 +351,7 @@ func (p *Postgres) UpdateWallet(ctx context.Context, walletID uuid.UUID, request
 		case errors.Is(err, pgx.ErrNoRows):
 			break
 		case err != nil:
-			return nil, fmt.Errorf("p.db.QueryRow(...): %w", err)
+			return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
 		default:
 			return nil, model.ErrDuplicateWallet
 		}
@@ -256,7 +359,7 @@ func (p *Postgres) UpdateWallet(ctx context.Context, walletID uuid.UUID, request
 		query = `
 		UPDATE wallets
 		SET name = $2, modified_at = $3
-		WHERE is_disabled = false and id = $1 
+		WHERE id = $1
 		RETURNING id, name, modified_at`
 
 		err = tx.QueryRow(
@@ -268,32 +371,59 @@ func (p *Postgres) UpdateWallet(ctx context.Context, walletID uuid.UUID, request
 			&wallet.Name,
 			&wallet.ModifiedDate)
 		if err != nil {
-			return nil, fmt.Errorf("p.db.QueryRow(...): %w", err)
+			return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
 		}
 	}
 
 	if request.Currency != nil {
 		query := `
 		UPDATE wallets
-		SET currency = $2, modified_at = $3, balance = $4
-		WHERE is_disabled = false and id = $1 
+		SET currency = $2, modified_at = $3, balance = balance * $4
+		WHERE id = $1
 		RETURNING id, currency, balance, modified_at`
 
 		err = tx.QueryRow(
 			ctx,
 			query,
-			walletID, request.Currency, time.Now(), wallet.Balance*request.ConversionRate,
+			walletID, request.Currency, time.Now(), request.ConversionRate,
 		).Scan(
 			&wallet.ID,
 			&wallet.Currency,
 			&wallet.Balance,
 			&wallet.ModifiedDate,
 		)
+
+		var pgErr *pgconn.PgError
+
+		switch {
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == currencyConstraint:
+			return nil, model.ErrWrongCurrency
+		case err != nil:
+			return nil, fmt.Errorf("tx.QueryRow(...): %w", err)
+		}
+
+		err = p.writeSpreadIncome(ctx, tx, nil, model.SpreadIncome{
+			WalletID: wallet.ID,
+			Currency: wallet.Currency,
+			Amount:   before.Balance * request.SpreadRate,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("p.db.QueryRow(...): %w", err)
+			return nil, fmt.Errorf("p.writeSpreadIncome(ctx, tx, nil, ...): %w", err)
 		}
 	}
 
+	err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+		Action:     model.AuditActionUpdateWallet,
+		EntityType: model.AuditEntityWallet,
+		EntityID:   &wallet.ID,
+		Details:    map[string]any{"conversionRate": request.ConversionRate},
+		Before:     before,
+		After:      wallet,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
 	}
@@ -301,30 +431,252 @@ func (p *Postgres) UpdateWallet(ctx context.Context, walletID uuid.UUID, request
 	return wallet, nil
 }
 
-func (p *Postgres) DeleteWallet(ctx context.Context, walletID uuid.UUID) error {
+func (p *Postgres) CloseWallet(ctx context.Context, closure model.WalletClosure) error {
+	ctx, done := p.observe(ctx, "CloseWallet")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("CloseWallet/tx.Rollback(ctx)")
+		}
+	}()
+
+	// Locking wallet
 	query := `
-	UPDATE wallets
-	SET is_disabled = true, modified_at = $2
-	WHERE is_disabled = false and id = $1 
-	RETURNING id
-`
-	err := p.db.QueryRow(
+	SELECT currency, balance, status
+	FROM wallets
+	WHERE id = $1
+	FOR UPDATE`
+
+	var (
+		currency string
+		balance  float64
+		status   model.WalletStatus
+	)
+
+	err = tx.QueryRow(
 		ctx,
 		query,
-		walletID, time.Now(),
-	).Scan(nil)
+		closure.WalletID,
+	).Scan(
+		&currency,
+		&balance,
+		&status)
 
 	switch {
 	case errors.Is(err, pgx.ErrNoRows):
 		return model.ErrWalletNotFound
 	case err != nil:
-		return fmt.Errorf("p.db.QueryRow(...): %w", err)
+		return fmt.Errorf("tx.QueryRow(...): %w", err)
+	case status == model.WalletStatusClosed || status == model.WalletStatusArchived:
+		return model.ErrWalletNotFound
+	case status != model.WalletStatusActive:
+		// frozen wallets can't be closed whatever the balance, an admin
+		// unfreezes them first
+		return model.ErrWalletFrozen
+	case currency != closure.Currency:
+		return model.ErrWalletWasChanged
+	case balance != 0 && closure.SweepTo == nil:
+		return model.ErrNonZeroBalance
+	}
+
+	// Sweeping remaining balance
+	if balance != 0 {
+		err = p.sweepBalance(ctx, tx, closure, balance)
+		if err != nil {
+			return fmt.Errorf("p.sweepBalance(ctx, tx, closure, balance): %w", err)
+		}
+	}
+
+	// Closing wallet
+	query = `
+	UPDATE wallets
+	SET status = 'closed', modified_at = $2
+	WHERE id = $1`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		closure.WalletID, time.Now())
+	if err != nil {
+		return fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	// Recording close event
+	query = `
+	INSERT INTO transactions (id, from_wallet_id, currency, balance, type)
+	VALUES ($1, $2, $3, 0, $4)`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		closure.CloseTransactionID, closure.WalletID, currency, model.TransactionTypeClose)
+	if err != nil {
+		return fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+		Action:     model.AuditActionCloseWallet,
+		EntityType: model.AuditEntityWallet,
+		EntityID:   &closure.WalletID,
+		Details: map[string]any{
+			"sweepTo":            closure.SweepTo,
+			"conversionRate":     closure.ConversionRate,
+			"sweepTransactionId": closure.SweepTransactionID,
+		},
+		Before: map[string]any{"status": status, "balance": balance},
+		After:  map[string]any{"status": model.WalletStatusClosed, "balance": 0},
+	})
+	if err != nil {
+		return fmt.Errorf("p.writeAuditEntry(...): %w", err)
+	}
+
+	event := model.WalletClosedV1{
+		WalletID:       closure.WalletID,
+		Currency:       currency,
+		ConversionRate: closure.ConversionRate,
+	}
+
+	if balance != 0 {
+		event.SweptTo = closure.SweepTo
+		event.SweptAmount = balance
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, model.EventWalletClosed, closure.WalletID, event)
+	if err != nil {
+		return fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) sweepBalance(ctx context.Context, tx pgx.Tx, closure model.WalletClosure, balance float64) error {
+	err := checkWalletStatus(ctx, tx, *closure.SweepTo, false)
+	if err != nil {
+		return fmt.Errorf("checkWalletStatus(ctx, tx, *closure.SweepTo, false): %w", err)
+	}
+
+	query := `
+	UPDATE wallets
+	SET balance = balance + $1, modified_at = $3
+	WHERE id = $2
+	RETURNING currency, balance, owner_id`
+
+	var (
+		currency     string
+		balanceAfter float64
+		targetOwner  uuid.UUID
+		agentOwner   uuid.UUID
+	)
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		balance*closure.ConversionRate, closure.SweepTo, time.Now(),
+	).Scan(
+		&currency,
+		&balanceAfter,
+		&targetOwner)
+
+	switch {
+	case err != nil:
+		return fmt.Errorf("tx.QueryRow(...): %w", err)
+	case currency != closure.SweepToCurrency:
+		return model.ErrWalletWasChanged
+	}
+
+	err = p.writeBalanceAuditEntry(
+		ctx, tx, model.AuditActionTransferIn, *closure.SweepTo, closure.SweepTransactionID,
+		balance*closure.ConversionRate, balanceAfter)
+	if err != nil {
+		return fmt.Errorf("p.writeBalanceAuditEntry(...): %w", err)
+	}
+
+	query = `
+	UPDATE wallets
+	SET balance = 0, modified_at = $2
+	WHERE id = $1
+	RETURNING owner_id`
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		closure.WalletID, time.Now(),
+	).Scan(&agentOwner)
+	if err != nil {
+		return fmt.Errorf("tx.QueryRow(...): %w", err)
+	}
+
+	query = `
+	INSERT INTO transactions (id, from_wallet_id, to_wallet_id, currency, balance, type)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		closure.SweepTransactionID, closure.WalletID, closure.SweepTo, closure.Currency, balance,
+		model.TransactionTypeSweep)
+	if err != nil {
+		return fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	err = p.writeSpreadIncome(ctx, tx, &closure.SweepTransactionID, model.SpreadIncome{
+		WalletID: *closure.SweepTo,
+		Currency: closure.SweepToCurrency,
+		Amount:   balance * closure.SpreadRate,
+	})
+	if err != nil {
+		return fmt.Errorf("p.writeSpreadIncome(ctx, tx, &closure.SweepTransactionID, ...): %w", err)
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, model.EventTransferCompleted, closure.SweepTransactionID, model.TransferCompletedV1{
+		TransactionID: closure.SweepTransactionID,
+		FromWalletID:  closure.WalletID,
+		ToWalletID:    *closure.SweepTo,
+		Currency:      closure.Currency,
+		Sum:           balance,
+		SumWithdrawn:  balance,
+		SumDeposited:  balance * closure.ConversionRate,
+		FromBalance:   0,
+		ToBalance:     balanceAfter,
+		FromCurrency:  closure.Currency,
+		ToCurrency:    closure.SweepToCurrency,
+	})
+	if err != nil {
+		return fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	err = p.enqueueWebhookDeliveries(
+		ctx, tx, model.EventTransferCompleted,
+		[]uuid.UUID{agentOwner, targetOwner}, model.Transaction{
+			ID:             closure.SweepTransactionID,
+			AgentWalletID:  &closure.WalletID,
+			TargetWalletID: closure.SweepTo,
+			Currency:       closure.Currency,
+			Sum:            balance,
+			Type:           model.TransactionTypeSweep,
+		})
+	if err != nil {
+		return fmt.Errorf("p.enqueueWebhookDeliveries(...): %w", err)
 	}
 
 	return nil
 }
 
 func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transaction model.Transaction) (*uuid.UUID, error) {
+	ctx, done := p.observe(ctx, "Transfer")
+	defer done()
+
 	tx, err := p.db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
@@ -339,14 +691,15 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 
 	// Saving transaction to DB
 	query := `
-	INSERT INTO transactions (id, from_wallet_id, to_wallet_id, currency, balance)
-	VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO transactions (id, from_wallet_id, to_wallet_id, currency, balance, type)
+	VALUES ($1, $2, $3, $4, $5, $6)
 	returning id, created_at`
 
 	err = tx.QueryRow(
 		ctx,
 		query,
 		transaction.ID, transaction.AgentWalletID, transaction.TargetWalletID, transaction.Currency, transaction.Sum,
+		model.TransactionTypeTransfer,
 	).Scan(
 		&transaction.ID,
 		&transaction.CreatedAt,
@@ -362,14 +715,28 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 		return nil, fmt.Errorf("tx.QueryRow(): %w", err)
 	}
 
+	// Checking that both wallets accept the money movement
+	err = checkWalletStatus(ctx, tx, transfer.AgentWallet.ID, true)
+	if err != nil {
+		return nil, fmt.Errorf("checkWalletStatus(ctx, tx, AgentWallet.ID, true): %w", err)
+	}
+
+	err = checkWalletStatus(ctx, tx, transfer.TargetWallet.ID, false)
+	if err != nil {
+		return nil, fmt.Errorf("checkWalletStatus(ctx, tx, TargetWallet.ID, false): %w", err)
+	}
+
 	// Moving Cash
 	query = `
 	UPDATE wallets
 	SET balance = balance - $1, modified_at = $3
-	WHERE is_disabled = false and id = $2 
-	RETURNING currency`
+	WHERE id = $2
+	RETURNING currency, balance`
 
-	var currency string
+	var (
+		currency string
+		balance  float64
+	)
 
 	err = tx.QueryRow(
 		ctx,
@@ -377,6 +744,7 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 		transfer.SumToWithdraw, transfer.AgentWallet.ID, time.Now(),
 	).Scan(
 		&currency,
+		&balance,
 	)
 
 	switch {
@@ -390,13 +758,21 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 		return nil, model.ErrWalletWasChanged
 	}
 
+	err = p.writeBalanceAuditEntry(
+		ctx, tx, model.AuditActionTransferOut, transfer.AgentWallet.ID, transaction.ID, -transfer.SumToWithdraw, balance)
+	if err != nil {
+		return nil, fmt.Errorf("p.writeBalanceAuditEntry(...): %w", err)
+	}
+
+	agentBalance := balance
+
 	// Depositing money
 
 	query = `
 	UPDATE wallets
 	SET balance = balance + $1, modified_at = $3
-	WHERE is_disabled = false and id = $2
-	RETURNING currency`
+	WHERE id = $2
+	RETURNING currency, balance`
 
 	err = tx.QueryRow(
 		ctx,
@@ -404,6 +780,7 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 		transfer.SumToDeposit, transfer.TargetWallet.ID, time.Now(),
 	).Scan(
 		&currency,
+		&balance,
 	)
 
 	switch {
@@ -417,6 +794,43 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 		return nil, model.ErrWalletWasChanged
 	}
 
+	err = p.writeBalanceAuditEntry(
+		ctx, tx, model.AuditActionTransferIn, transfer.TargetWallet.ID, transaction.ID, transfer.SumToDeposit, balance)
+	if err != nil {
+		return nil, fmt.Errorf("p.writeBalanceAuditEntry(...): %w", err)
+	}
+
+	err = p.writeSpreadIncome(ctx, tx, &transaction.ID, transfer.SpreadIncome...)
+	if err != nil {
+		return nil, fmt.Errorf("p.writeSpreadIncome(ctx, tx, &transaction.ID, ...): %w", err)
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, model.EventTransferCompleted, transaction.ID, model.TransferCompletedV1{
+		TransactionID: transaction.ID,
+		FromWalletID:  transfer.AgentWallet.ID,
+		ToWalletID:    transfer.TargetWallet.ID,
+		Currency:      transaction.Currency,
+		Sum:           transaction.Sum,
+		SumWithdrawn:  transfer.SumToWithdraw,
+		SumDeposited:  transfer.SumToDeposit,
+		FromBalance:   agentBalance,
+		ToBalance:     balance,
+		FromCurrency:  transfer.AgentWallet.Currency,
+		ToCurrency:    transfer.TargetWallet.Currency,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	transaction.Type = model.TransactionTypeTransfer
+
+	err = p.enqueueWebhookDeliveries(
+		ctx, tx, model.EventTransferCompleted,
+		[]uuid.UUID{transfer.AgentWallet.OwnerID, transfer.TargetWallet.OwnerID}, transaction)
+	if err != nil {
+		return nil, fmt.Errorf("p.enqueueWebhookDeliveries(...): %w", err)
+	}
+
 	// Committing transaction
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
@@ -425,7 +839,14 @@ func (p *Postgres) Transfer(ctx context.Context, transfer model.Transfer, transa
 	return &transaction.ID, nil
 }
 
-func (p *Postgres) ExternalTransaction(ctx context.Context, transaction model.Transaction) (*uuid.UUID, error) {
+func (p *Postgres) ExternalTransaction(
+	ctx context.Context,
+	transaction model.Transaction,
+	spreadIncome []model.SpreadIncome,
+) (*uuid.UUID, error) {
+	ctx, done := p.observe(ctx, "ExternalTransaction")
+	defer done()
+
 	tx, err := p.db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
@@ -440,14 +861,19 @@ func (p *Postgres) ExternalTransaction(ctx context.Context, transaction model.Tr
 
 	// Save transaction
 	query := `
-	INSERT INTO transactions (id, to_wallet_id, currency, balance)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO transactions (id, to_wallet_id, currency, balance, type)
+	VALUES ($1, $2, $3, $4, $5)
 	returning id, created_at`
 
+	transactionType := model.TransactionTypeDeposit
+	if transaction.Sum < 0 {
+		transactionType = model.TransactionTypeWithdrawal
+	}
+
 	err = tx.QueryRow(
 		ctx,
 		query,
-		transaction.ID, transaction.TargetWalletID, transaction.Currency, transaction.Sum,
+		transaction.ID, transaction.TargetWalletID, transaction.Currency, transaction.Sum, transactionType,
 	).Scan(
 		&transaction.ID,
 		&transaction.CreatedAt,
@@ -462,21 +888,33 @@ func (p *Postgres) ExternalTransaction(ctx context.Context, transaction model.Tr
 		return nil, fmt.Errorf("tx.QueryRow(): %w", err)
 	}
 
+	// Check wallet accepts the money movement
+	err = checkWalletStatus(ctx, tx, *transaction.TargetWalletID, transaction.Sum < 0)
+	if err != nil {
+		return nil, fmt.Errorf("checkWalletStatus(ctx, tx, *transaction.TargetWalletID, ...): %w", err)
+	}
+
 	// Update wallet
 	query = `
 	UPDATE wallets
 	SET balance = balance + $1, modified_at = $3
 	WHERE id = $2
-	RETURNING currency`
+	RETURNING currency, balance, owner_id`
 
-	var currency string
+	var (
+		currency string
+		balance  float64
+		ownerID  uuid.UUID
+	)
 
 	err = tx.QueryRow(
 		ctx,
 		query,
 		transaction.Sum, transaction.TargetWalletID, time.Now(),
 	).Scan(
-		&currency)
+		&currency,
+		&balance,
+		&ownerID)
 
 	switch {
 	case errors.Is(err, pgx.ErrNoRows):
@@ -489,6 +927,44 @@ func (p *Postgres) ExternalTransaction(ctx context.Context, transaction model.Tr
 		return nil, fmt.Errorf("tx.Exec(ctx, query, targetWallet.Sum, targetWallet.ID): %w", err)
 	}
 
+	action := model.AuditActionDeposit
+	if transaction.Sum < 0 {
+		action = model.AuditActionWithdraw
+	}
+
+	err = p.writeBalanceAuditEntry(ctx, tx, action, *transaction.TargetWalletID, transaction.ID, transaction.Sum, balance)
+	if err != nil {
+		return nil, fmt.Errorf("p.writeBalanceAuditEntry(...): %w", err)
+	}
+
+	err = p.writeSpreadIncome(ctx, tx, &transaction.ID, spreadIncome...)
+	if err != nil {
+		return nil, fmt.Errorf("p.writeSpreadIncome(ctx, tx, &transaction.ID, ...): %w", err)
+	}
+
+	eventType := model.EventFundsDeposited
+	if transaction.Sum < 0 {
+		eventType = model.EventFundsWithdrawn
+	}
+
+	err = p.writeOutboxEvent(ctx, tx, eventType, *transaction.TargetWalletID, model.FundsMovedV1{
+		TransactionID: transaction.ID,
+		WalletID:      *transaction.TargetWalletID,
+		Currency:      currency,
+		Amount:        math.Abs(transaction.Sum),
+		Balance:       balance,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("p.writeOutboxEvent(...): %w", err)
+	}
+
+	transaction.Type = transactionType
+
+	err = p.enqueueWebhookDeliveries(ctx, tx, eventType, []uuid.UUID{ownerID}, transaction)
+	if err != nil {
+		return nil, fmt.Errorf("p.enqueueWebhookDeliveries(...): %w", err)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
@@ -497,6 +973,40 @@ func (p *Postgres) ExternalTransaction(ctx context.Context, transaction model.Tr
 	return &transaction.ID, nil
 }
 
+// checkWalletStatus locks the wallet row until the end of tx and checks
+// that its status allows a debit or a credit.
+func checkWalletStatus(ctx context.Context, tx pgx.Tx, walletID uuid.UUID, debit bool) error {
+	query := `
+	SELECT status
+	FROM wallets
+	WHERE id = $1
+	FOR UPDATE`
+
+	var status model.WalletStatus
+
+	err := tx.QueryRow(
+		ctx,
+		query,
+		walletID,
+	).Scan(
+		&status)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return model.ErrWalletNotFound
+	case err != nil:
+		return fmt.Errorf("tx.QueryRow(...): %w", err)
+	case status == model.WalletStatusClosed || status == model.WalletStatusArchived:
+		return model.ErrWalletNotFound
+	case debit && !status.CanDebit():
+		return model.ErrWalletFrozen
+	case !debit && !status.CanCredit():
+		return model.ErrWalletFrozen
+	}
+
+	return nil
+}
+
 func (p *Postgres) GetTransactions(ctx context.Context, params model.GetParams) ([]*model.Transaction, error) {
 	transactions := make([]*model.Transaction, 0, 1)
 
@@ -512,33 +1022,32 @@ func (p *Postgres) GetTransactions(ctx context.Context, params model.GetParams)
 		transactions.to_wallet_id, 
 		transactions.currency, 
 		transactions.balance, 
-		transactions.created_at
+		transactions.created_at,
+		transactions.type
 	FROM 
 		transactions
-	JOIN 
+	LEFT JOIN 
 		wallets AS sender_wallet ON transactions.from_wallet_id = sender_wallet.id
-	JOIN 
+	LEFT JOIN 
 		wallets AS receiver_wallet ON transactions.to_wallet_id = receiver_wallet.id
 	WHERE 
-		sender_wallet.owner_id = $1 OR receiver_wallet.owner_id = $1`
+		(sender_wallet.owner_id = $1 OR receiver_wallet.owner_id = $1)`
 
 	if params.Filter != "" {
-		query += fmt.Sprintf(" AND currency LIKE '%%%s%%'", params.Filter)
+		query += " AND transactions.currency LIKE '%' || $2 || '%'"
 	}
 
-	if params.Sorting != "" {
-		query += " ORDER BY " + params.Sorting
-		if params.Descending {
-			query += " DESC"
-		}
+	order, err := orderBy(params, transactionColumns)
+	if err != nil {
+		return nil, fmt.Errorf("orderBy(params, transactionColumns): %w", err)
 	}
 
-	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+	query += order + fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
 
 	rows, err := p.db.Query(
 		ctx,
 		query,
-		userInfo.ID,
+		filterArgs(params, userInfo.ID)...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("p.db.Query(ctx, query): %w", err)
@@ -554,7 +1063,8 @@ func (p *Postgres) GetTransactions(ctx context.Context, params model.GetParams)
 			&transaction.TargetWalletID,
 			&transaction.Currency,
 			&transaction.Sum,
-			&transaction.CreatedAt)
+			&transaction.CreatedAt,
+			&transaction.Type)
 		if err != nil {
 			return nil, fmt.Errorf("rows.Scan(...): %w", err)
 		}
@@ -570,13 +1080,28 @@ func (p *Postgres) GetTransactions(ctx context.Context, params model.GetParams)
 }
 
 func (p *Postgres) DisableInactiveWallets(ctx context.Context) ([]*model.Wallet, error) {
+	ctx, done := p.observe(ctx, "DisableInactiveWallets")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("DisableInactiveWallets/tx.Rollback(ctx)")
+		}
+	}()
+
 	query := `
 	UPDATE wallets
-	SET is_disabled = true
-	WHERE modified_at < NOW() - INTERVAL '3 months' AND balance = 0
-	RETURNING id, owner_id, currency, balance, created_at, modified_at, name`
+	SET status = 'archived'
+	WHERE status = 'active' AND modified_at < NOW() - INTERVAL '3 months' AND balance = 0
+	RETURNING id, owner_id, currency, balance, created_at, modified_at, name, status, status_reason`
 
-	rows, err := p.db.Query(
+	rows, err := tx.Query(
 		ctx,
 		query)
 
@@ -601,7 +1126,9 @@ func (p *Postgres) DisableInactiveWallets(ctx context.Context) ([]*model.Wallet,
 			&wallet.Balance,
 			&wallet.CreatedDate,
 			&wallet.ModifiedDate,
-			&wallet.Name)
+			&wallet.Name,
+			&wallet.Status,
+			&wallet.StatusReason)
 		if err != nil {
 			return nil, fmt.Errorf("rows.Scan: %w", err)
 		}
@@ -613,5 +1140,25 @@ func (p *Postgres) DisableInactiveWallets(ctx context.Context) ([]*model.Wallet,
 		return nil, fmt.Errorf("rows.Err(): %w", err)
 	}
 
+	rows.Close()
+
+	for _, wallet := range wallets {
+		err = p.writeAuditEntry(ctx, tx, model.AuditEntry{
+			Action:     model.AuditActionArchiveWallet,
+			EntityType: model.AuditEntityWallet,
+			EntityID:   &wallet.ID,
+			Reason:     "inactive for 3 months",
+			Before:     map[string]any{"status": model.WalletStatusActive},
+			After:      map[string]any{"status": wallet.Status},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("p.writeAuditEntry(...): %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
 	return wallets, nil
 }
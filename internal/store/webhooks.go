@@ -0,0 +1,365 @@
+//go:build !MySql
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+func (p *Postgres) CreateWebhookSubscription(
+	ctx context.Context,
+	subscription model.WebhookSubscription,
+) (*model.WebhookSubscription, error) {
+	query := `
+	INSERT INTO webhook_subscriptions (id, owner_id, url, events, secret)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING is_active, created_at`
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+		subscription.ID, subscription.OwnerID, subscription.URL, subscription.Events, subscription.Secret,
+	).Scan(
+		&subscription.IsActive,
+		&subscription.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.QueryRow(...): %w", err)
+	}
+
+	return &subscription, nil
+}
+
+func (p *Postgres) GetWebhookSubscriptions(ctx context.Context, ownerID uuid.UUID) ([]*model.WebhookSubscription, error) {
+	subscriptions := make([]*model.WebhookSubscription, 0, 1)
+
+	query := `
+	SELECT id, owner_id, url, events, is_active, created_at
+	FROM webhook_subscriptions
+	WHERE owner_id = $1 AND is_active
+	ORDER BY created_at`
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, ownerID): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subscription := new(model.WebhookSubscription)
+
+		err = rows.Scan(
+			&subscription.ID,
+			&subscription.OwnerID,
+			&subscription.URL,
+			&subscription.Events,
+			&subscription.IsActive,
+			&subscription.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription deactivates the subscription. Its delivery log is
+// kept, pending deliveries are dead-lettered.
+func (p *Postgres) DeleteWebhookSubscription(ctx context.Context, ownerID, subscriptionID uuid.UUID) error {
+	ctx, done := p.observe(ctx, "DeleteWebhookSubscription")
+	defer done()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("DeleteWebhookSubscription/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	UPDATE webhook_subscriptions
+	SET is_active = false
+	WHERE id = $1 AND owner_id = $2 AND is_active`
+
+	result, err := tx.Exec(
+		ctx,
+		query,
+		subscriptionID, ownerID)
+	if err != nil {
+		return fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return model.ErrWebhookNotFound
+	}
+
+	query = `
+	UPDATE webhook_deliveries
+	SET status = 'dead', last_error = 'subscription deleted'
+	WHERE subscription_id = $1 AND status = 'pending'`
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		subscriptionID)
+	if err != nil {
+		return fmt.Errorf("tx.Exec(...): %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return nil
+}
+
+// enqueueWebhookDeliveries creates a pending delivery of the event for every
+// active subscription of the owners whose event filter matches it. It must be
+// called with the transaction that makes the change the event describes.
+func (p *Postgres) enqueueWebhookDeliveries(
+	ctx context.Context,
+	tx pgx.Tx,
+	event string,
+	ownerIDs []uuid.UUID,
+	data any,
+) error {
+	query := `
+	SELECT id
+	FROM webhook_subscriptions
+	WHERE owner_id = ANY($1) AND is_active AND (cardinality(events) = 0 OR $2 = ANY(events))`
+
+	rows, err := tx.Query(
+		ctx,
+		query,
+		ownerIDs, event)
+	if err != nil {
+		return fmt.Errorf("tx.Query(ctx, query, ownerIDs, event): %w", err)
+	}
+
+	subscriptionIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return fmt.Errorf("pgx.CollectRows(rows, pgx.RowTo[uuid.UUID]): %w", err)
+	}
+
+	if len(subscriptionIDs) == 0 {
+		return nil
+	}
+
+	query = `
+	INSERT INTO webhook_deliveries (id, subscription_id, event, payload)
+	VALUES ($1, $2, $3, $4)`
+
+	batch := &pgx.Batch{}
+	occurredAt := time.Now()
+
+	for _, subscriptionID := range subscriptionIDs {
+		deliveryID := uuid.New()
+
+		batch.Queue(
+			query,
+			deliveryID, subscriptionID, event, model.WebhookPayload{
+				DeliveryID: deliveryID,
+				Event:      event,
+				OccurredAt: occurredAt,
+				Data:       data,
+			})
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("tx.SendBatch(ctx, batch).Close(): %w", err)
+	}
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit due deliveries and leases them by
+// moving next_attempt_at forward, so other dispatchers skip them meanwhile.
+func (p *Postgres) ClaimWebhookDeliveries(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]*model.WebhookDelivery, error) {
+	deliveries := make([]*model.WebhookDelivery, 0, limit)
+
+	query := `
+	WITH due AS (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE webhook_deliveries AS d
+	SET next_attempt_at = $2
+	FROM due, webhook_subscriptions AS s
+	WHERE d.id = due.id AND s.id = d.subscription_id
+	RETURNING d.id, d.subscription_id, d.event, d.payload, d.status, d.attempts, d.created_at, s.url, s.secret`
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, limit, lease): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery := new(model.WebhookDelivery)
+
+		err = rows.Scan(
+			&delivery.ID,
+			&delivery.SubscriptionID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.CreatedAt,
+			&delivery.URL,
+			&delivery.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (p *Postgres) RecordWebhookAttempt(ctx context.Context, attempt model.WebhookAttempt) error {
+	status := model.DeliveryStatusPending
+
+	switch {
+	case attempt.Succeeded:
+		status = model.DeliveryStatusSucceeded
+	case attempt.Dead:
+		status = model.DeliveryStatusDead
+	}
+
+	query := `
+	UPDATE webhook_deliveries
+	SET
+		status = $2,
+		attempts = attempts + 1,
+		next_attempt_at = $3,
+		last_status_code = NULLIF($4, 0),
+		last_error = NULLIF($5, ''),
+		delivered_at = CASE WHEN $6 THEN now() END
+	WHERE id = $1`
+
+	_, err := p.db.Exec(
+		ctx,
+		query,
+		attempt.DeliveryID, status, attempt.NextAttemptAt, attempt.StatusCode, attempt.Error, attempt.Succeeded)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(...): %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetWebhookDeliveries(
+	ctx context.Context,
+	ownerID uuid.UUID,
+	subscriptionID uuid.UUID,
+	params model.DeliveryParams,
+) ([]*model.WebhookDelivery, error) {
+	deliveries := make([]*model.WebhookDelivery, 0, 1)
+
+	query := `
+	SELECT
+		d.id,
+		d.subscription_id,
+		d.event,
+		d.status,
+		d.attempts,
+		d.next_attempt_at,
+		coalesce(d.last_status_code, 0),
+		coalesce(d.last_error, ''),
+		d.created_at,
+		d.delivered_at
+	FROM webhook_deliveries AS d
+	JOIN webhook_subscriptions AS s ON s.id = d.subscription_id
+	WHERE s.id = $1 AND s.owner_id = $2 AND ($3 = '' OR d.status = $3)
+	ORDER BY d.created_at DESC`
+
+	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+
+	rows, err := p.db.Query(
+		ctx,
+		query,
+		subscriptionID, ownerID, params.Status)
+	if err != nil {
+		return nil, fmt.Errorf("p.db.Query(ctx, query, subscriptionID, ownerID, params.Status): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery := new(model.WebhookDelivery)
+
+		err = rows.Scan(
+			&delivery.ID,
+			&delivery.SubscriptionID,
+			&delivery.Event,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery moves a dead-lettered delivery back to the queue.
+func (p *Postgres) RetryWebhookDelivery(ctx context.Context, ownerID, deliveryID uuid.UUID) error {
+	query := `
+	UPDATE webhook_deliveries AS d
+	SET status = 'pending', attempts = 0, next_attempt_at = now()
+	FROM webhook_subscriptions AS s
+	WHERE d.id = $1 AND s.id = d.subscription_id AND s.owner_id = $2 AND s.is_active AND d.status = 'dead'`
+
+	result, err := p.db.Exec(
+		ctx,
+		query,
+		deliveryID, ownerID)
+	if err != nil {
+		return fmt.Errorf("p.db.Exec(...): %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return model.ErrDeliveryNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/backoff"
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	SignatureHeader = "X-Wallet-Signature"
+	EventHeader     = "X-Wallet-Event"
+	DeliveryHeader  = "X-Wallet-Delivery"
+
+	maxErrorLength = 512
+)
+
+var errPrivateDestination = errors.New("destination address is not public")
+
+// carrier-grade NAT range, which netip does not count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+type store interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt model.WebhookAttempt) error
+}
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+
+	// AllowPrivateNetworks lets deliveries reach loopback, link-local and
+	// private addresses. Subscribers are customers, so only for local setups.
+	AllowPrivateNetworks bool
+}
+
+// Dispatcher POSTs pending webhook deliveries to subscribers. Failed attempts
+// are retried with exponential backoff until MaxAttempts, then the delivery
+// is dead-lettered.
+type Dispatcher struct {
+	db     store
+	cfg    Config
+	client *http.Client
+}
+
+func NewDispatcher(cfg Config, db store) *Dispatcher {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+
+	// checked on the resolved address, so DNS cannot point a public name inside
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = denyPrivateAddress
+	}
+
+	return &Dispatcher{
+		db:  db,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// a proxy would dial on our behalf, skipping the address check
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConns:        cfg.BatchSize,
+				IdleConnTimeout:     time.Minute,
+			},
+			// a redirect is the subscriber's answer, not a new destination to POST to
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.dispatch(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	// the lease must outlive a whole batch of timed out requests
+	lease := d.cfg.Timeout*time.Duration(d.cfg.BatchSize) + d.cfg.PollInterval
+
+	deliveries, err := d.db.ClaimWebhookDeliveries(ctx, d.cfg.BatchSize, lease)
+	if err != nil {
+		zap.L().With(zap.Error(err)).Warn("dispatch/d.db.ClaimWebhookDeliveries(...)")
+
+		return
+	}
+
+	for _, delivery := range deliveries {
+		attempt := d.deliver(ctx, delivery)
+
+		if err := d.db.RecordWebhookAttempt(ctx, attempt); err != nil {
+			zap.L().With(zap.Error(err)).Warn("dispatch/d.db.RecordWebhookAttempt(ctx, attempt)")
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery) model.WebhookAttempt {
+	attempt := model.WebhookAttempt{DeliveryID: delivery.ID}
+
+	attempt.StatusCode, attempt.Error = d.send(ctx, delivery)
+	attempt.Succeeded = attempt.Error == ""
+
+	attempts := delivery.Attempts + 1
+
+	switch {
+	case attempt.Succeeded:
+		attempt.NextAttemptAt = time.Now()
+	case attempts >= d.cfg.MaxAttempts:
+		attempt.Dead = true
+		attempt.NextAttemptAt = time.Now()
+	default:
+		attempt.NextAttemptAt = time.Now().Add(backoff.Exponential(attempts, d.cfg.BaseBackoff, d.cfg.MaxBackoff))
+	}
+
+	return attempt
+}
+
+// send returns the response status code and a description of the failure,
+// empty on success.
+func (d *Dispatcher) send(ctx context.Context, delivery *model.WebhookDelivery) (int, string) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, truncate(err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, truncate(err.Error())
+	}
+	defer resp.Body.Close()
+
+	// drain so the connection can be reused
+	//nolint: errcheck
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, "unexpected status " + resp.Status
+	}
+
+	return resp.StatusCode, ""
+}
+
+// Sign builds the signature header value: t=<unix time>,v1=<hex HMAC-SHA256
+// of "<unix time>.<body>" keyed with the subscription secret>. Including the
+// timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func denyPrivateAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("netip.ParseAddrPort(address): %w", err)
+	}
+
+	addr := addrPort.Addr().Unmap()
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%s: %w", addr, errPrivateDestination)
+	}
+
+	return nil
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength]
+	}
+
+	return s
+}
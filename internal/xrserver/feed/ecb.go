@@ -0,0 +1,76 @@
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+const ecbBase = "EUR"
+
+// ecbEnvelope is the layout of the ECB euro foreign exchange reference
+// rates, the latest day first.
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ECBProvider reads an ECB-style daily feed, rates of currencies per euro,
+// from a URL or a local file.
+type ECBProvider struct {
+	source string
+	client *http.Client
+}
+
+func NewECBProvider(source string, client *http.Client) *ECBProvider {
+	return &ECBProvider{source: source, client: client}
+}
+
+func (p *ECBProvider) Name() string {
+	return "ecb:" + p.source
+}
+
+func (p *ECBProvider) Fetch(ctx context.Context) ([]model.XRRate, error) {
+	var (
+		body []byte
+		err  error
+	)
+
+	if strings.HasPrefix(p.source, "http://") || strings.HasPrefix(p.source, "https://") {
+		body, err = get(ctx, p.client, p.source)
+	} else {
+		body, err = os.ReadFile(p.source)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", p.source, err)
+	}
+
+	var envelope ecbEnvelope
+
+	if err = xml.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("xml.Unmarshal(body, &envelope): %w: %w", ErrInvalidFeed, err)
+	}
+
+	if len(envelope.Days) == 0 || len(envelope.Days[0].Rates) == 0 {
+		return nil, fmt.Errorf("no rates: %w", ErrInvalidFeed)
+	}
+
+	perEuro := make(map[string]float64, len(envelope.Days[0].Rates))
+
+	for _, rate := range envelope.Days[0].Rates {
+		perEuro[rate.Currency] = rate.Rate
+	}
+
+	return fromBase(ecbBase, perEuro)
+}
@@ -0,0 +1,68 @@
+// Package feed fetches snapshots of exchange rates from upstream sources.
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+var (
+	ErrUnknownSource = errors.New("unknown rate feed source")
+	ErrInvalidFeed   = errors.New("invalid rate feed")
+)
+
+// New builds the provider described by source, "kind:location" where kind is
+// file, ecb or http. ECB feeds are read from a file unless location is a URL.
+func New(source string, timeout time.Duration) (Provider, error) {
+	kind, location, found := strings.Cut(source, ":")
+	if !found || location == "" {
+		return nil, fmt.Errorf("%q: %w", source, ErrUnknownSource)
+	}
+
+	client := &http.Client{Timeout: timeout}
+
+	switch kind {
+	case "file":
+		return NewFileProvider(location), nil
+	case "ecb":
+		return NewECBProvider(location, client), nil
+	case "http":
+		return NewHTTPProvider(location, client), nil
+	}
+
+	return nil, fmt.Errorf("%q: %w", source, ErrUnknownSource)
+}
+
+// Provider fetches a snapshot of rates: the value of one unit of each
+// currency, in any unit common to the snapshot.
+type Provider interface {
+	Name() string
+	Fetch(ctx context.Context) ([]model.XRRate, error)
+}
+
+// fromBase turns rates quoted as units of each currency per unit of base,
+// the way rate feeds usually publish them, into values of one unit.
+func fromBase(base string, perBase map[string]float64) ([]model.XRRate, error) {
+	rates := make([]model.XRRate, 0, len(perBase)+1)
+	rates = append(rates, model.XRRate{Code: base, Rate: 1})
+
+	for code, rate := range perBase {
+		if code == base {
+			continue
+		}
+
+		if rate <= 0 {
+			return nil, fmt.Errorf("%s rate %v: %w", code, rate, ErrInvalidFeed)
+		}
+
+		rates = append(rates, model.XRRate{Code: code, Rate: 1 / rate})
+	}
+
+	return rates, nil
+}
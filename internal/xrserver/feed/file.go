@@ -0,0 +1,128 @@
+package feed
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+const watchInterval = time.Second
+
+// FileProvider reads rates from a local file, either CSV with code,rate
+// records, optionally under a header, or JSON with a list of rates as the
+// rate server serves them.
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (p *FileProvider) Name() string {
+	return "file:" + p.path
+}
+
+func (p *FileProvider) Fetch(_ context.Context) ([]model.XRRate, error) {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(p.path): %w", err)
+	}
+
+	defer func() {
+		err := file.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("FileProvider.Fetch/file.Close()")
+		}
+	}()
+
+	switch strings.ToLower(filepath.Ext(p.path)) {
+	case ".csv":
+		return readCSV(file)
+	case ".json":
+		var rates []model.XRRate
+
+		if err = json.NewDecoder(file).Decode(&rates); err != nil {
+			return nil, fmt.Errorf("json.NewDecoder(file).Decode(&rates): %w: %w", ErrInvalidFeed, err)
+		}
+
+		return rates, nil
+	}
+
+	return nil, fmt.Errorf("%s: unsupported file type: %w", p.path, ErrInvalidFeed)
+}
+
+func readCSV(r io.Reader) ([]model.XRRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	rates := make([]model.XRRate, 0)
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return rates, nil
+		case err != nil:
+			return nil, fmt.Errorf("reader.Read(): %w: %w", ErrInvalidFeed, err)
+		}
+
+		rate, err := strconv.ParseFloat(record[1], 64)
+
+		switch {
+		case err != nil && line == 1:
+			// header
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("line %d: %w: %w", line, ErrInvalidFeed, err)
+		}
+
+		rates = append(rates, model.XRRate{Code: strings.ToUpper(record[0]), Rate: rate})
+	}
+}
+
+// Watch signals on changed whenever the file is modified, until ctx is done.
+func (p *FileProvider) Watch(ctx context.Context, changed chan<- struct{}) {
+	var modTime time.Time
+
+	if info, err := os.Stat(p.path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(p.path)
+		if err != nil || info.ModTime().Equal(modTime) {
+			continue
+		}
+
+		modTime = info.ModTime()
+
+		// a refresh already pending covers this change too
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
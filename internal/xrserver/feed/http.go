@@ -0,0 +1,85 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+const maxFeedBytes = 10 << 20
+
+// httpFeed is the usual layout of rate APIs: units of each currency per
+// unit of base.
+type httpFeed struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// HTTPProvider gets rates from a JSON API such as
+// {"base": "EUR", "rates": {"USD": 1.08}}.
+type HTTPProvider struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPProvider(url string, client *http.Client) *HTTPProvider {
+	return &HTTPProvider{url: url, client: client}
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http:" + p.url
+}
+
+func (p *HTTPProvider) Fetch(ctx context.Context) ([]model.XRRate, error) {
+	body, err := get(ctx, p.client, p.url)
+	if err != nil {
+		return nil, fmt.Errorf("get(ctx, p.client, p.url): %w", err)
+	}
+
+	var feed httpFeed
+
+	if err = json.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(body, &feed): %w: %w", ErrInvalidFeed, err)
+	}
+
+	if err = model.ValidateCurrency(feed.Base); err != nil || len(feed.Rates) == 0 {
+		return nil, fmt.Errorf("base %q with %d rates: %w", feed.Base, len(feed.Rates), ErrInvalidFeed)
+	}
+
+	return fromBase(feed.Base, feed.Rates)
+}
+
+func get(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequestWithContext(...): %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client.Do(req): %w", err)
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("get/resp.Body.Close()")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %w", resp.StatusCode, ErrInvalidFeed)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes))
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll(...): %w", err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+const maxBatchPairs = 100
+
+var errTooManyPairs = errors.New("too many pairs")
+
+func (s *Server) handleGetMatrix(w http.ResponseWriter, r *http.Request) {
+	base := r.URL.Query().Get("base")
+
+	matrix, err := s.getMatrix(base)
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "wrong currency")
+
+		return
+	}
+
+	logger.FromContext(r.Context()).Debug("successful GET:/xr/matrix", zap.String("base", base))
+
+	writeOkResponse(w, r, http.StatusOK, matrix)
+}
+
+func (s *Server) handlePostBatch(w http.ResponseWriter, r *http.Request) {
+	var request model.XRBatchRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&request); err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "error reading body")
+
+		return
+	}
+
+	rates, err := s.getBatch(request.Pairs)
+
+	switch {
+	case errors.Is(err, errTooManyPairs):
+		writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
+
+		return
+	case err != nil:
+		writeErrorResponse(w, r, http.StatusBadRequest, "wrong currency")
+
+		return
+	}
+
+	logger.FromContext(r.Context()).Debug("successful POST:/xr/batch", zap.Int("pairs", len(request.Pairs)))
+
+	writeOkResponse(w, r, http.StatusOK, model.XRBatchResponse{Rates: rates})
+}
+
+// getMatrix quotes every currency against base from one snapshot.
+func (s *Server) getMatrix(base string) (*model.XRMatrix, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if _, ok := s.currencies[base]; !ok {
+		return nil, model.ErrWrongCurrency
+	}
+
+	matrix := &model.XRMatrix{
+		Base:  base,
+		Rates: make(map[string]model.XRResponse, len(s.currencies)),
+	}
+
+	for target := range s.currencies {
+		xr, err := crossRate(s.currencies, base, target)
+		if err != nil {
+			return nil, err
+		}
+
+		matrix.Rates[target] = quote(xr, s.cfg.spread(base, target))
+	}
+
+	return matrix, nil
+}
+
+// getBatch quotes pairs from one snapshot, failing as a whole when a
+// currency is unknown.
+func (s *Server) getBatch(pairs []model.XRPair) ([]model.XRResponse, error) {
+	if len(pairs) > maxBatchPairs {
+		return nil, errTooManyPairs
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rates := make([]model.XRResponse, 0, len(pairs))
+
+	for _, pair := range pairs {
+		xr, err := crossRate(s.currencies, pair.BaseCurrency, pair.TargetCurrency)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, quote(xr, s.cfg.spread(pair.BaseCurrency, pair.TargetCurrency)))
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/Saaghh/wallet/internal/xrserver/feed"
+	"go.uber.org/zap"
+)
+
+const (
+	FeedOK    = "ok"
+	FeedError = "error"
+
+	staleCheckInterval = time.Minute
+)
+
+var (
+	errNoAnchor     = errors.New("feed anchor currency missing")
+	errFeedInterval = errors.New("feed interval must be positive")
+)
+
+type watcher interface {
+	Watch(ctx context.Context, changed chan<- struct{})
+}
+
+// runFeed refreshes the rates every FeedInterval and whenever a watched
+// source changes, until ctx is done.
+func (s *Server) runFeed(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+
+	for _, provider := range s.providers {
+		if w, ok := provider.(watcher); ok {
+			go w.Watch(ctx, changed)
+		}
+	}
+
+	refreshTicker := time.NewTicker(s.cfg.FeedInterval)
+	defer refreshTicker.Stop()
+
+	staleTicker := time.NewTicker(staleCheckInterval)
+	defer staleTicker.Stop()
+
+	s.refresh(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refreshTicker.C:
+			s.refresh(ctx)
+		case <-changed:
+			s.refresh(ctx)
+		case <-staleTicker.C:
+		}
+
+		s.checkStale()
+	}
+}
+
+// refresh takes the snapshot of the first provider that delivers a valid
+// one. When none does, the last good rates stay.
+func (s *Server) refresh(ctx context.Context) {
+	for _, provider := range s.providers {
+		if err := s.refreshFrom(ctx, provider); err != nil {
+			s.metrics.TrackXRFeed(provider.Name(), FeedError)
+			logger.FromContext(ctx).With(zap.Error(err), zap.String("provider", provider.Name())).Warn(
+				"refresh/s.refreshFrom(ctx, provider)")
+
+			continue
+		}
+
+		s.metrics.TrackXRFeed(provider.Name(), FeedOK)
+
+		return
+	}
+
+	logger.FromContext(ctx).Warn("refresh: no rate provider delivered, keeping the last rates")
+}
+
+func (s *Server) refreshFrom(ctx context.Context, provider feed.Provider) error {
+	rates, err := provider.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("provider.Fetch(ctx): %w", err)
+	}
+
+	if err = s.updateRates(ctx, provider.Name(), rates); err != nil {
+		return fmt.Errorf("s.updateRates(ctx, provider.Name(), rates): %w", err)
+	}
+
+	return nil
+}
+
+// updateRates rebases a snapshot so that the anchor currency keeps its rate,
+// then stores the rates that changed. A snapshot with an invalid rate is
+// rejected as a whole.
+func (s *Server) updateRates(ctx context.Context, source string, snapshot []model.XRRate) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	anchor, ok := s.currencies[s.cfg.FeedAnchor]
+	if !ok {
+		return fmt.Errorf("%s: %w", s.cfg.FeedAnchor, errNoAnchor)
+	}
+
+	factor := 0.0
+
+	for _, rate := range snapshot {
+		if rate.Code == anchor.Code && rate.Rate > 0 {
+			factor = anchor.Rate / rate.Rate
+		}
+	}
+
+	if factor == 0 {
+		return fmt.Errorf("%s: %w", anchor.Code, errNoAnchor)
+	}
+
+	now := time.Now()
+	changes := make([]model.XRRate, 0, len(snapshot))
+
+	for _, rate := range snapshot {
+		if rate.Code == anchor.Code {
+			continue
+		}
+
+		rate.Rate *= factor
+		rate.EffectiveAt = now
+
+		if err := rate.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", rate.Code, err)
+		}
+
+		if current, ok := s.currencies[rate.Code]; ok && current.Rate == rate.Rate {
+			continue
+		}
+
+		changes = append(changes, rate)
+	}
+
+	if len(changes) > 0 {
+		if err := s.storage.Save(ctx, changes); err != nil {
+			return fmt.Errorf("s.storage.Save(ctx, changes): %w", err)
+		}
+	}
+
+	for _, change := range changes {
+		s.currencies[change.Code] = change
+	}
+
+	for _, rate := range snapshot {
+		s.updates[rate.Code] = model.XRUpdate{Code: rate.Code, UpdatedAt: now, Source: source}
+	}
+
+	logger.FromContext(ctx).Info("rates refreshed", zap.String("source", source), zap.Int("changed", len(changes)))
+
+	return nil
+}
+
+// checkStale raises the alert while any rate is older than FeedMaxAge.
+func (s *Server) checkStale() {
+	if s.cfg.FeedMaxAge <= 0 {
+		return
+	}
+
+	stale := make([]string, 0)
+
+	s.mutex.RLock()
+
+	for code, update := range s.updates {
+		if time.Since(update.UpdatedAt) > s.cfg.FeedMaxAge {
+			stale = append(stale, code)
+		}
+	}
+
+	s.mutex.RUnlock()
+
+	s.metrics.SetXRRatesStale(len(stale) > 0)
+
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		zap.L().Warn("rates are stale", zap.Strings("currencies", stale))
+	}
+}
+
+func (s *Server) handleGetUpdates(w http.ResponseWriter, r *http.Request) {
+	writeOkResponse(w, r, http.StatusOK, s.getUpdates())
+}
+
+func (s *Server) getUpdates() []model.XRUpdate {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	updates := make([]model.XRUpdate, 0, len(s.updates))
+
+	for _, update := range s.updates {
+		updates = append(updates, update)
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Code < updates[j].Code })
+
+	return updates
+}
@@ -1,14 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
+	"time"
 
+	"github.com/Saaghh/wallet/internal/logger"
 	"github.com/Saaghh/wallet/internal/model"
-	"github.com/gorilla/schema"
 	"go.uber.org/zap"
 )
 
@@ -18,83 +19,117 @@ type HTTPResponse struct {
 }
 
 func (s *Server) handleGetExchangeRate(w http.ResponseWriter, r *http.Request) {
-	xrRequest, err := valuesToXRRequest(r.URL.Query())
+	xrRequest, err := model.ValuesToXRRequest(r.URL.Query())
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "error getting params")
+		writeErrorResponse(w, r, http.StatusBadRequest, "error getting params")
 
 		return
 	}
 
-	xr, err := s.getExchangeRate(xrRequest.BaseCurrency, xrRequest.TargetCurrency)
+	var xr float64
+
+	if xrRequest.At.IsZero() {
+		xr, err = s.getExchangeRate(xrRequest.BaseCurrency, xrRequest.TargetCurrency)
+	} else {
+		xr, err = s.getExchangeRateAt(r.Context(), xrRequest.BaseCurrency, xrRequest.TargetCurrency, xrRequest.At)
+	}
 
 	switch {
 	case errors.Is(err, model.ErrWrongCurrency):
-		writeErrorResponse(w, http.StatusBadRequest, "wrong currency")
+		writeErrorResponse(w, r, http.StatusBadRequest, "wrong currency")
 
 		return
 	case err != nil:
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("handleGetExchangeRate/s.getExchangeRateAt(...)")
+		writeErrorResponse(w, r, http.StatusInternalServerError, "internal server error")
 
 		return
 	}
 
-	zap.L().Debug(
+	logger.FromContext(r.Context()).Debug(
 		"successful GET:/xr",
 		zap.Float64("xr", xr),
 		zap.String("base", xrRequest.BaseCurrency),
 		zap.String("target", xrRequest.TargetCurrency))
 
-	writeOkResponse(w, http.StatusOK, model.XRResponse{XR: xr})
+	writeOkResponse(
+		w,
+		r,
+		http.StatusOK,
+		quote(xr, s.cfg.spread(xrRequest.BaseCurrency, xrRequest.TargetCurrency)))
 }
 
-func (s *Server) getExchangeRate(baseCurrency, targetCurrency string) (float64, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+func (s *Server) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	historyRequest, err := model.ValuesToXRHistoryRequest(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "error getting params")
+
+		return
+	}
+
+	if historyRequest.To.IsZero() {
+		historyRequest.To = time.Now()
+	}
+
+	if historyRequest.From.After(historyRequest.To) {
+		writeErrorResponse(w, r, http.StatusBadRequest, "from is after to")
 
-	baseK, ok := s.currencies[baseCurrency]
-	if !ok {
-		return 0, model.ErrWrongCurrency
+		return
 	}
 
-	targetK, ok := s.currencies[targetCurrency]
-	if !ok {
-		return 0, model.ErrWrongCurrency
+	changes, err := s.storage.Changes(
+		r.Context(),
+		[]string{historyRequest.BaseCurrency, historyRequest.TargetCurrency},
+		historyRequest.To)
+	if err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("handleGetHistory/s.storage.Changes(...)")
+		writeErrorResponse(w, r, http.StatusInternalServerError, "internal server error")
+
+		return
 	}
 
-	return baseK / targetK, nil
+	writeOkResponse(
+		w,
+		r,
+		http.StatusOK,
+		history(changes, historyRequest.BaseCurrency, historyRequest.TargetCurrency, historyRequest.From))
 }
 
-func valuesToXRRequest(values url.Values) (*model.XRRequest, error) {
-	decoder := schema.NewDecoder()
+func (s *Server) getExchangeRate(baseCurrency, targetCurrency string) (float64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	xrRequest := &model.XRRequest{}
+	return crossRate(s.currencies, baseCurrency, targetCurrency)
+}
 
-	err := decoder.Decode(xrRequest, values)
+// getExchangeRateAt returns the rate that was valid at the moment.
+func (s *Server) getExchangeRateAt(ctx context.Context, baseCurrency, targetCurrency string, at time.Time) (float64, error) {
+	changes, err := s.storage.Changes(ctx, []string{baseCurrency, targetCurrency}, at)
 	if err != nil {
-		return nil, fmt.Errorf("decoder.Decode(xrRequest, values): %w", err)
+		return 0, fmt.Errorf("s.storage.Changes(ctx, ...): %w", err)
 	}
 
-	return xrRequest, nil
+	return crossRate(replay(changes), baseCurrency, targetCurrency)
 }
 
-func writeOkResponse(w http.ResponseWriter, statusCode int, data any) {
+func writeOkResponse(w http.ResponseWriter, r *http.Request, statusCode int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
 	err := json.NewEncoder(w).Encode(HTTPResponse{Data: data})
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn(
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn(
 			"writeOkResponse/json.NewEncoder(w).Encode(HTTPResponse{Data: data})")
 	}
 }
 
-func writeErrorResponse(w http.ResponseWriter, statusCode int, description string) {
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, statusCode int, description string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
 	err := json.NewEncoder(w).Encode(HTTPResponse{Error: description})
 	if err != nil {
-		zap.L().With(zap.Error(err)).Warn(
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn(
 			"writeErrorResponse/json.NewEncoder(w).Encode(HTTPResponse{Error: data})")
 	}
 }
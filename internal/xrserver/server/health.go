@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// rateMetricsInterval is how often the currency gauges are refreshed, rate
+// ages grow between changes.
+const rateMetricsInterval = 15 * time.Second
+
+type healthResponse struct {
+	Status     string `json:"status"`
+	Currencies int    `json:"currencies,omitempty"`
+}
+
+func (s *Server) Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		s.metrics.TrackHTTPRequestStart()
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			s.metrics.TrackHTTPRequest(start, r, status, ww.BytesWritten())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// handleHealthz reports that the process is up.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeOkResponse(w, r, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether rates can be served: a snapshot is loaded and
+// holds at least one currency.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	loaded, currencies := s.loaded, len(s.currencies)
+	s.mutex.RUnlock()
+
+	if !loaded || currencies == 0 {
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, "no rates loaded")
+
+		return
+	}
+
+	writeOkResponse(w, r, http.StatusOK, healthResponse{Status: "ok", Currencies: currencies})
+}
+
+// runRateMetrics keeps the currency gauges up to date until ctx is done.
+func (s *Server) runRateMetrics(ctx context.Context) {
+	ticker := time.NewTicker(rateMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		s.metrics.SetXRRates(s.getUpdates())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+// replay applies changes, oldest first, and returns the rates they leave.
+func replay(changes []model.XRRate) map[string]model.XRRate {
+	currencies := make(map[string]model.XRRate)
+
+	for _, change := range changes {
+		apply(currencies, change)
+	}
+
+	return currencies
+}
+
+func apply(currencies map[string]model.XRRate, change model.XRRate) {
+	if change.Rate == 0 {
+		delete(currencies, change.Code)
+
+		return
+	}
+
+	currencies[change.Code] = change
+}
+
+func crossRate(currencies map[string]model.XRRate, baseCurrency, targetCurrency string) (float64, error) {
+	base, ok := currencies[baseCurrency]
+	if !ok {
+		return 0, model.ErrWrongCurrency
+	}
+
+	target, ok := currencies[targetCurrency]
+	if !ok {
+		return 0, model.ErrWrongCurrency
+	}
+
+	return base.Rate / target.Rate, nil
+}
+
+// history turns the changes of a pair's currencies into the pair's rates
+// from from on. The first point is the rate valid at from, the others are
+// the moments it changed. Moments the pair had no rate are skipped.
+func history(changes []model.XRRate, baseCurrency, targetCurrency string, from time.Time) []model.XRPoint {
+	currencies := make(map[string]model.XRRate)
+	points := make([]model.XRPoint, 0, len(changes))
+
+	i := 0
+
+	for ; i < len(changes) && changes[i].EffectiveAt.Before(from); i++ {
+		apply(currencies, changes[i])
+	}
+
+	if xr, err := crossRate(currencies, baseCurrency, targetCurrency); err == nil {
+		points = append(points, model.XRPoint{At: from, XR: xr})
+	}
+
+	for ; i < len(changes); i++ {
+		apply(currencies, changes[i])
+
+		// changes made at once are a single point
+		if i+1 < len(changes) && changes[i+1].EffectiveAt.Equal(changes[i].EffectiveAt) {
+			continue
+		}
+
+		xr, err := crossRate(currencies, baseCurrency, targetCurrency)
+		if err != nil || (len(points) > 0 && points[len(points)-1].XR == xr) {
+			continue
+		}
+
+		points = append(points, model.XRPoint{At: changes[i].EffectiveAt, XR: xr})
+	}
+
+	return points
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/logger"
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const maxBodyBytes = 1 << 20
+
+// AdminAuth lets through requests bearing the admin token.
+func (s *Server) AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if !found || s.cfg.AdminToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+			writeErrorResponse(w, r, http.StatusUnauthorized, "unauthorized")
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleGetRates(w http.ResponseWriter, r *http.Request) {
+	writeOkResponse(w, r, http.StatusOK, s.getRates())
+}
+
+func (s *Server) handlePutRate(w http.ResponseWriter, r *http.Request) {
+	var rate model.XRRate
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&rate); err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "error reading body")
+
+		return
+	}
+
+	rate.Code = chi.URLParam(r, "code")
+	rate.EffectiveAt = time.Now()
+
+	if err := rate.Validate(); err != nil {
+		writeErrorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+
+		return
+	}
+
+	if err := s.setRate(r.Context(), rate); err != nil {
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("handlePutRate/s.setRate(r.Context(), rate)")
+		writeErrorResponse(w, r, http.StatusInternalServerError, "internal server error")
+
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("rate set", zap.String("code", rate.Code), zap.Float64("rate", rate.Rate))
+
+	writeOkResponse(w, r, http.StatusOK, rate)
+}
+
+func (s *Server) handleDeleteRate(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	err := s.deleteRate(r.Context(), code)
+
+	switch {
+	case errors.Is(err, model.ErrWrongCurrency):
+		writeErrorResponse(w, r, http.StatusNotFound, "currency not found")
+
+		return
+	case err != nil:
+		logger.FromContext(r.Context()).With(zap.Error(err)).Warn("handleDeleteRate/s.deleteRate(r.Context(), code)")
+		writeErrorResponse(w, r, http.StatusInternalServerError, "internal server error")
+
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("rate deleted", zap.String("code", code))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getRates() []model.XRRate {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rates := make([]model.XRRate, 0, len(s.currencies))
+
+	for _, rate := range s.currencies {
+		rates = append(rates, rate)
+	}
+
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Code < rates[j].Code })
+
+	return rates
+}
+
+// setRate stores the change, then makes it visible to readers. The write
+// lock is held throughout so that changes are stored in the order they apply.
+func (s *Server) setRate(ctx context.Context, rate model.XRRate) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.storage.Save(ctx, []model.XRRate{rate}); err != nil {
+		return fmt.Errorf("s.storage.Save(ctx, ...): %w", err)
+	}
+
+	s.currencies[rate.Code] = rate
+	s.updates[rate.Code] = model.XRUpdate{Code: rate.Code, UpdatedAt: rate.EffectiveAt}
+
+	return nil
+}
+
+func (s *Server) deleteRate(ctx context.Context, code string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.currencies[code]; !ok {
+		return model.ErrWrongCurrency
+	}
+
+	if err := s.storage.Save(ctx, []model.XRRate{{Code: code, EffectiveAt: time.Now()}}); err != nil {
+		return fmt.Errorf("s.storage.Save(ctx, ...): %w", err)
+	}
+
+	delete(s.currencies, code)
+	delete(s.updates, code)
+
+	return nil
+}
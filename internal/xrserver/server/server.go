@@ -8,43 +8,152 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/Saaghh/wallet/internal/tracing"
+	"github.com/Saaghh/wallet/internal/xrserver/feed"
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+type Config struct {
+	BindAddr string
+	// AdminToken authorizes rate management, which is off while it is empty.
+	AdminToken string
+	// Spread is the default width of the bid/ask spread as a fraction of the
+	// mid-rate. Spreads overrides it per currency ("EUR") or per pair
+	// ("EUR/USD").
+	Spread  float64
+	Spreads map[string]float64
+	// FeedInterval is how often the feed providers are asked for rates.
+	// Rates not updated for FeedMaxAge are stale. FeedAnchor is the currency
+	// that ties feed snapshots to the server's rates, its own rate is kept.
+	FeedInterval time.Duration
+	FeedMaxAge   time.Duration
+	FeedAnchor   string
+}
+
+type metrics interface {
+	TrackHTTPRequestStart()
+	TrackHTTPRequest(start time.Time, r *http.Request, status, size int)
+	SetXRRates(updates []model.XRUpdate)
+	TrackXRFeed(provider, result string)
+	SetXRRatesStale(stale bool)
+}
+
+type rateStorage interface {
+	Save(ctx context.Context, changes []model.XRRate) error
+	Changes(ctx context.Context, codes []string, to time.Time) ([]model.XRRate, error)
+}
+
+// defaultRates are stored on the first start, when the storage is empty.
+var defaultRates = map[string]float64{
+	"RUB": 1,
+	"USD": 90.53,
+	"EUR": 97.53,
+	"KZT": 20.0115,
+	"IDR": 0.00579328,
+}
+
 type Server struct {
-	currencies map[string]float64
+	cfg        Config
+	storage    rateStorage
+	providers  []feed.Provider
+	metrics    metrics
+	currencies map[string]model.XRRate
+	updates    map[string]model.XRUpdate
+	loaded     bool
 	router     *chi.Mux
 	server     *http.Server
 	mutex      *sync.RWMutex
 }
 
-func New(bindAddr string) *Server {
-	currencies := map[string]float64{
-		"RUB": 1,
-		"USD": 90.53,
-		"EUR": 97.53,
-		"KZT": 20.0115,
-		"IDR": 0.00579328,
-	}
-
+// New returns a rate server. Its rates are refreshed from providers, the
+// first one to deliver wins; without providers they change only by hand.
+func New(cfg Config, storage rateStorage, providers []feed.Provider, metrics metrics) *Server {
 	router := chi.NewRouter()
 
 	return &Server{
-		currencies: currencies,
+		cfg:        cfg,
+		storage:    storage,
+		providers:  providers,
+		metrics:    metrics,
+		currencies: make(map[string]model.XRRate),
+		updates:    make(map[string]model.XRUpdate),
 		router:     router,
 		mutex:      new(sync.RWMutex),
 		server: &http.Server{
-			Addr:              bindAddr,
+			Addr:              cfg.BindAddr,
 			ReadHeaderTimeout: 5 * time.Second,
 			Handler:           router,
 		},
 	}
 }
 
+// loadRates restores the current rates from the storage, seeding it with
+// the default ones on the first start.
+func (s *Server) loadRates(ctx context.Context) error {
+	changes, err := s.storage.Changes(ctx, nil, time.Now())
+	if err != nil {
+		return fmt.Errorf("s.storage.Changes(ctx, nil, time.Now()): %w", err)
+	}
+
+	if len(changes) == 0 {
+		now := time.Now()
+
+		for code, rate := range defaultRates {
+			changes = append(changes, model.XRRate{Code: code, Rate: rate, EffectiveAt: now})
+		}
+
+		if err = s.storage.Save(ctx, changes); err != nil {
+			return fmt.Errorf("s.storage.Save(ctx, changes): %w", err)
+		}
+	}
+
+	currencies := replay(changes)
+	updates := make(map[string]model.XRUpdate, len(currencies))
+
+	for code, rate := range currencies {
+		updates[code] = model.XRUpdate{Code: code, UpdatedAt: rate.EffectiveAt}
+	}
+
+	s.mutex.Lock()
+	s.currencies = currencies
+	s.updates = updates
+	s.loaded = true
+	s.mutex.Unlock()
+
+	zap.L().Info("rates loaded", zap.Int("currencies", len(currencies)))
+
+	return nil
+}
+
 func (s *Server) Run(ctx context.Context) error {
+	if err := s.cfg.validateSpreads(); err != nil {
+		return fmt.Errorf("s.cfg.validateSpreads(): %w", err)
+	}
+
+	if len(s.providers) > 0 && s.cfg.FeedInterval <= 0 {
+		return errFeedInterval
+	}
+
+	if err := s.loadRates(ctx); err != nil {
+		return fmt.Errorf("s.loadRates(ctx): %w", err)
+	}
+
+	// without the anchor no snapshot could ever be taken
+	if _, ok := s.currencies[s.cfg.FeedAnchor]; len(s.providers) > 0 && !ok {
+		return fmt.Errorf("%q: %w", s.cfg.FeedAnchor, errNoAnchor)
+	}
+
 	s.configRouter()
 
+	go s.runRateMetrics(ctx)
+
+	if len(s.providers) > 0 {
+		go s.runFeed(ctx)
+	}
+
 	go func() {
 		<-ctx.Done()
 
@@ -69,5 +178,25 @@ func (s *Server) Run(ctx context.Context) error {
 }
 
 func (s *Server) configRouter() {
+	s.router.Use(tracing.Middleware)
+	s.router.Use(s.Metrics)
+
+	s.router.Get("/healthz", s.handleHealthz)
+	s.router.Get("/readyz", s.handleReadyz)
+
 	s.router.Get("/xr", s.handleGetExchangeRate)
+	s.router.Get("/xr/history", s.handleGetHistory)
+	s.router.Get("/xr/updates", s.handleGetUpdates)
+	s.router.Get("/xr/matrix", s.handleGetMatrix)
+	s.router.Post("/xr/batch", s.handlePostBatch)
+
+	s.router.Get("/metrics", promhttp.Handler().ServeHTTP)
+
+	s.router.Route("/rates", func(r chi.Router) {
+		r.Use(s.AdminAuth)
+
+		r.Get("/", s.handleGetRates)
+		r.Put("/{code}", s.handlePutRate)
+		r.Delete("/{code}", s.handleDeleteRate)
+	})
 }
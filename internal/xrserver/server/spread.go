@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Saaghh/wallet/internal/model"
+)
+
+// maxSpread keeps the bid positive.
+const maxSpread = 2
+
+// spread returns the width of the pair's spread. A pair's own spread comes
+// first, either way round, then the wider one of its currencies, then the
+// default.
+func (c *Config) spread(baseCurrency, targetCurrency string) float64 {
+	if spread, ok := c.Spreads[baseCurrency+"/"+targetCurrency]; ok {
+		return spread
+	}
+
+	if spread, ok := c.Spreads[targetCurrency+"/"+baseCurrency]; ok {
+		return spread
+	}
+
+	baseSpread, baseOK := c.Spreads[baseCurrency]
+	targetSpread, targetOK := c.Spreads[targetCurrency]
+
+	if baseOK || targetOK {
+		return max(baseSpread, targetSpread)
+	}
+
+	return c.Spread
+}
+
+func (c *Config) validateSpreads() error {
+	if c.Spread < 0 || c.Spread >= maxSpread {
+		return fmt.Errorf("spread %v: %w", c.Spread, model.ErrOutOfRange)
+	}
+
+	for key, spread := range c.Spreads {
+		codes := strings.Split(key, "/")
+		if len(codes) > 2 {
+			return fmt.Errorf("spread %q: %w", key, model.ErrWrongCurrency)
+		}
+
+		for _, code := range codes {
+			if err := model.ValidateCurrency(code); err != nil {
+				return fmt.Errorf("spread %q: %w", key, err)
+			}
+		}
+
+		if spread < 0 || spread >= maxSpread {
+			return fmt.Errorf("spread %q %v: %w", key, spread, model.ErrOutOfRange)
+		}
+	}
+
+	return nil
+}
+
+// quote puts the mid-rate in the middle of a spread of the given width.
+func quote(xr, spread float64) model.XRResponse {
+	return model.XRResponse{
+		XR:  xr,
+		Bid: xr * (1 - spread/2),
+		Ask: xr * (1 + spread/2),
+	}
+}
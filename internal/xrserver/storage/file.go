@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"go.uber.org/zap"
+)
+
+// FileStorage appends changes to a file as JSON lines and keeps them all in
+// memory, the file is only read on start.
+type FileStorage struct {
+	mutex   *sync.RWMutex
+	path    string
+	changes []model.XRRate
+}
+
+func NewFileStorage(path string) (*FileStorage, error) {
+	storage := &FileStorage{
+		mutex: new(sync.RWMutex),
+		path:  path,
+	}
+
+	file, err := os.Open(path)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return storage, nil
+	case err != nil:
+		return nil, fmt.Errorf("os.Open(path): %w", err)
+	}
+
+	defer func() {
+		err := file.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("NewFileStorage/file.Close()")
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var change model.XRRate
+
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(scanner.Bytes(), &change): %w", err)
+		}
+
+		storage.changes = append(storage.changes, change)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Err(): %w", err)
+	}
+
+	sort.SliceStable(storage.changes, func(i, j int) bool {
+		return storage.changes[i].EffectiveAt.Before(storage.changes[j].EffectiveAt)
+	})
+
+	return storage, nil
+}
+
+func (s *FileStorage) Save(_ context.Context, changes []model.XRRate) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(s.path, ...): %w", err)
+	}
+
+	defer func() {
+		err := file.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("FileStorage.Save/file.Close()")
+		}
+	}()
+
+	encoder := json.NewEncoder(file)
+
+	for _, change := range changes {
+		if err = encoder.Encode(change); err != nil {
+			return fmt.Errorf("encoder.Encode(change): %w", err)
+		}
+	}
+
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("file.Sync(): %w", err)
+	}
+
+	s.changes = append(s.changes, changes...)
+
+	sort.SliceStable(s.changes, func(i, j int) bool {
+		return s.changes[i].EffectiveAt.Before(s.changes[j].EffectiveAt)
+	})
+
+	return nil
+}
+
+func (s *FileStorage) Changes(_ context.Context, codes []string, to time.Time) ([]model.XRRate, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	changes := make([]model.XRRate, 0, len(s.changes))
+
+	for _, change := range s.changes {
+		if change.EffectiveAt.After(to) {
+			break
+		}
+
+		if len(codes) == 0 || contains(codes, change.Code) {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func (s *FileStorage) Close() {}
+
+func contains(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,14 @@
+-- +migrate Up
+
+-- Before migrations the table was created at startup, so it may be there.
+CREATE TABLE IF NOT EXISTS xr_rates
+(
+    code         varchar(3) not null,
+    rate         double precision not null CHECK ( rate >= 0 ),
+    effective_at timestamp with time zone not null,
+    primary key (code, effective_at)
+);
+
+-- +migrate Down
+
+DROP TABLE xr_rates;
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	migrate "github.com/rubenv/sql-migrate"
+	"go.uber.org/zap"
+)
+
+// migrationsTable keeps the applied rate storage migrations apart from the
+// wallet ones, the storage may share a database with the wallet.
+const migrationsTable = "xr_migrations"
+
+//go:embed migrations
+var migrations embed.FS
+
+// PostgresStorage keeps changes in the xr_rates table.
+type PostgresStorage struct {
+	db  *pgxpool.Pool
+	dsn string
+}
+
+func NewPostgresStorage(ctx context.Context, dsn string) (*PostgresStorage, error) {
+	db, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.New(ctx, dsn): %w", err)
+	}
+
+	zap.L().Info("successfully connected to rate storage")
+
+	return &PostgresStorage{db: db, dsn: dsn}, nil
+}
+
+func (s *PostgresStorage) Migrate(direction migrate.MigrationDirection) error {
+	conn, err := sql.Open("pgx", s.dsn)
+	if err != nil {
+		return fmt.Errorf("sql.Open: %w", err)
+	}
+
+	defer func() {
+		err := conn.Close()
+		if err != nil {
+			zap.L().With(zap.Error(err)).Warn("conn.Close")
+		}
+	}()
+
+	assetDir := func() func(string) ([]string, error) {
+		return func(path string) ([]string, error) {
+			dirEntry, err := migrations.ReadDir(path)
+			if err != nil {
+				return nil, fmt.Errorf("migrations.ReadDir: %w", err)
+			}
+
+			entries := make([]string, 0)
+
+			for _, e := range dirEntry {
+				entries = append(entries, e.Name())
+			}
+
+			return entries, nil
+		}
+	}()
+
+	asset := migrate.AssetMigrationSource{
+		Asset:    migrations.ReadFile,
+		AssetDir: assetDir,
+		Dir:      "migrations",
+	}
+
+	migrationSet := migrate.MigrationSet{TableName: migrationsTable}
+
+	_, err = migrationSet.Exec(conn, "postgres", asset, direction)
+	if err != nil {
+		return fmt.Errorf("migrationSet.Exec: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) Save(ctx context.Context, changes []model.XRRate) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("s.db.Begin(ctx): %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().With(zap.Error(err)).Warn("PostgresStorage.Save/tx.Rollback(ctx)")
+		}
+	}()
+
+	query := `
+	INSERT INTO xr_rates (code, rate, effective_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (code, effective_at) DO UPDATE
+	SET rate = excluded.rate`
+
+	for _, change := range changes {
+		_, err = tx.Exec(ctx, query, change.Code, change.Rate, change.EffectiveAt)
+		if err != nil {
+			return fmt.Errorf("tx.Exec(...): %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx): %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) Changes(ctx context.Context, codes []string, to time.Time) ([]model.XRRate, error) {
+	changes := make([]model.XRRate, 0, 1)
+
+	query := `
+	SELECT code, rate, effective_at
+	FROM xr_rates
+	WHERE effective_at <= $1 AND (coalesce(cardinality($2::text[]), 0) = 0 OR code = ANY($2))
+	ORDER BY effective_at, code`
+
+	rows, err := s.db.Query(ctx, query, to, codes)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.Query(ctx, query, to, codes): %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change model.XRRate
+
+		if err = rows.Scan(&change.Code, &change.Rate, &change.EffectiveAt); err != nil {
+			return nil, fmt.Errorf("rows.Scan(...): %w", err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err(): %w", err)
+	}
+
+	return changes, nil
+}
+
+func (s *PostgresStorage) Close() {
+	s.db.Close()
+}
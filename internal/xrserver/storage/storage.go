@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saaghh/wallet/internal/model"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var ErrUnknownStorage = errors.New("unknown rate storage")
+
+// Storage keeps every change of the rates, so that the rates valid at any
+// moment can be told. A removed currency is stored as a change to zero.
+type Storage interface {
+	Save(ctx context.Context, changes []model.XRRate) error
+	// Changes returns the changes of codes, or of all currencies when codes
+	// is empty, effective up to to, oldest first.
+	Changes(ctx context.Context, codes []string, to time.Time) ([]model.XRRate, error)
+	Close()
+}
+
+// New builds the storage selected by kind.
+func New(ctx context.Context, kind, filePath, dsn string) (Storage, error) {
+	switch kind {
+	case "file":
+		storage, err := NewFileStorage(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("NewFileStorage(filePath): %w", err)
+		}
+
+		return storage, nil
+	case "postgres":
+		storage, err := NewPostgresStorage(ctx, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("NewPostgresStorage(ctx, dsn): %w", err)
+		}
+
+		if err = storage.Migrate(migrate.Up); err != nil {
+			storage.Close()
+
+			return nil, fmt.Errorf("storage.Migrate(migrate.Up): %w", err)
+		}
+
+		return storage, nil
+	}
+
+	return nil, fmt.Errorf("%q: %w", kind, ErrUnknownStorage)
+}
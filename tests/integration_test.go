@@ -1,29 +1,60 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	walletv1 "github.com/Saaghh/wallet/api/wallet/v1"
 	"github.com/Saaghh/wallet/internal/apiserver"
 	"github.com/Saaghh/wallet/internal/config"
 	"github.com/Saaghh/wallet/internal/currconv"
 	"github.com/Saaghh/wallet/internal/jwtgenerator"
 	"github.com/Saaghh/wallet/internal/logger"
 	"github.com/Saaghh/wallet/internal/model"
+	"github.com/Saaghh/wallet/internal/outbox"
 	"github.com/Saaghh/wallet/internal/prometrics"
 	"github.com/Saaghh/wallet/internal/service"
 	"github.com/Saaghh/wallet/internal/store"
+	"github.com/Saaghh/wallet/internal/webhook"
+	xrfeed "github.com/Saaghh/wallet/internal/xrserver/feed"
+	xrserver "github.com/Saaghh/wallet/internal/xrserver/server"
+	xrstorage "github.com/Saaghh/wallet/internal/xrserver/storage"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	migrate "github.com/rubenv/sql-migrate"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
+	"time"
 )
 
 const (
@@ -32,6 +63,11 @@ const (
 	depositEndpoint      = "/wallets/deposit"
 	withdrawEndpoint     = "/wallets/withdraw"
 	transactionsEndpoint = "/wallets/transactions"
+	adminEndpoint        = "/admin"
+	auditEndpoint        = "/audit"
+	webhooksEndpoint     = "/webhooks"
+	streamEndpoint       = "/wallets/stream"
+	currenciesEndpoint   = "/currencies"
 	bindAddr             = "http://localhost:8080/api/v1"
 	currencyEUR          = "EUR"
 	currencyUSD          = "USD"
@@ -40,10 +76,14 @@ const (
 	thirdName            = "best wallet"
 	fourthName           = "fourth name wallet"
 	badRequestString     = "Lorem Ipsum?"
+	xrTestBindAddr       = ":3031"
+	xrTestAdminToken     = "xr-admin-token"
 )
 
 type currencyConverter interface {
-	GetExchangeRate(baseCurrency, targetCurrency string) (float64, error)
+	GetExchangeRate(ctx context.Context, baseCurrency, targetCurrency string) (model.XRResponse, error)
+	GetExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error)
+	Ping(ctx context.Context) error
 }
 
 type IntegrationTestSuite struct {
@@ -54,13 +94,19 @@ type IntegrationTestSuite struct {
 	secondOwnerID uuid.UUID
 
 	str *store.Postgres
+	srv *service.Service
 
 	converter currencyConverter
 
 	authToken       string
 	secondAuthToken string
+	adminAuthToken  string
 
 	tokenGenerator *jwtgenerator.JWTGenerator
+
+	grpcAddr string
+
+	openAPIRouter routers.Router
 }
 
 func TestIntegrationTestSuite(t *testing.T) {
@@ -78,7 +124,9 @@ func (s *IntegrationTestSuite) SetupSuite() {
 
 	logger.InitLogger(logger.Config{Level: "Warn"})
 
-	str, err := store.New(ctx, cfg)
+	metrics := prometrics.New()
+
+	str, err := store.New(ctx, cfg, metrics)
 	s.Require().NoError(err)
 
 	err = str.Migrate(migrate.Up)
@@ -98,13 +146,44 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	s.secondAuthToken, err = s.tokenGenerator.GetNewTokenString(*user)
 	s.Require().NoError(err)
 
+	user, err = str.CreateUser(ctx, model.User{Email: "admin@test.com", Role: model.RoleAdmin})
+	s.Require().NoError(err)
+	s.adminAuthToken, err = s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
+
 	s.str = str
 
-	metrics := prometrics.New()
+	_, s.openAPIRouter, err = apiserver.LoadOpenAPI(ctx)
+	s.Require().NoError(err)
+
+	providers, err := currconv.NewProviders(
+		cfg.XRURLs,
+		currconv.Config{
+			AuthHeader: cfg.XRAuthHeader,
+			AuthToken:  cfg.XRAuthToken,
+			TLS: currconv.TLSConfig{
+				CAFile:             cfg.XRTLSCAFile,
+				CertFile:           cfg.XRTLSCertFile,
+				KeyFile:            cfg.XRTLSKeyFile,
+				InsecureSkipVerify: cfg.XRTLSSkipVerify,
+			},
+			Timeout:          cfg.XRTimeout,
+			RetryAttempts:    cfg.XRRetryAttempts,
+			RetryBaseDelay:   cfg.XRRetryBaseDelay,
+			RetryMaxDelay:    cfg.XRRetryMaxDelay,
+			BreakerThreshold: cfg.XRBreakerThreshold,
+			BreakerCooldown:  cfg.XRBreakerCooldown,
+		},
+		metrics)
+	s.Require().NoError(err)
 
-	s.converter = currconv.New(cfg.XRBindAddr, metrics)
+	s.converter = currconv.NewCache(
+		currconv.NewFailover(providers, cfg.XRMaxDeviation, metrics),
+		currconv.CacheConfig{TTL: cfg.XRCacheTTL, MaxStaleness: cfg.XRCacheMaxStaleness},
+		metrics)
 
-	srv := service.New(str, s.converter)
+	srv := service.New(str, s.converter, metrics)
+	s.srv = srv
 
 	server := apiserver.New(apiserver.Config{BindAddress: cfg.BindAddress}, srv, s.tokenGenerator.GetPublicKey(), metrics)
 
@@ -112,6 +191,20 @@ func (s *IntegrationTestSuite) SetupSuite() {
 		err = server.Run(ctx)
 		s.Require().NoError(err)
 	}()
+
+	grpcServer := apiserver.NewGRPC(apiserver.GRPCConfig{BindAddress: cfg.GRPCBindAddress}, srv, s.tokenGenerator.GetPublicKey())
+
+	go func() {
+		err := grpcServer.Run(ctx)
+		s.Require().NoError(err)
+	}()
+
+	s.grpcAddr = "localhost" + cfg.GRPCBindAddress
+
+	go func() {
+		err := srv.StreamRun(ctx)
+		s.Require().NoError(err)
+	}()
 }
 
 func (s *IntegrationTestSuite) TearDownSuite() {
@@ -197,7 +290,7 @@ func (s *IntegrationTestSuite) TestWallets() {
 					context.Background(),
 					http.MethodPost,
 					walletEndpoint,
-					wallet1,
+					model.NewWallet{Currency: wallet1.Currency, Name: wallet1.Name},
 					&apiserver.HTTPResponse{Data: &respWalletData})
 				s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
 			})
@@ -347,7 +440,7 @@ func (s *IntegrationTestSuite) TestWallets() {
 
 				newCurrency := currencyUSD
 				wallet := &wallet1
-				xr, err := s.converter.GetExchangeRate(wallet.Currency, newCurrency)
+				xr, err := s.converter.GetExchangeRate(context.Background(), wallet.Currency, newCurrency)
 				s.Require().NoError(err)
 
 				resp := s.sendRequest(
@@ -360,10 +453,10 @@ func (s *IntegrationTestSuite) TestWallets() {
 				s.Require().Equal(http.StatusOK, resp.StatusCode)
 				s.Require().Equal(newCurrency, respData.Currency)
 				s.Require().Equal(wallet.ID, respData.ID)
-				s.Require().Equal(wallet.Balance*xr, respData.Balance)
+				s.Require().Equal(wallet.Balance*xr.Bid, respData.Balance)
 
 				wallet.Currency = newCurrency
-				wallet.Balance *= xr
+				wallet.Balance *= xr.Bid
 			})
 
 			s.Run("200/both", func() {
@@ -425,6 +518,40 @@ func (s *IntegrationTestSuite) TestWallets() {
 				s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
 			})
 
+			s.Run("currency is converted from the locked wallet", func() {
+				wallet := model.Wallet{OwnerID: s.testOwnerID, Currency: currencyUSD, Name: "locked conversion"}
+				s.checkWalletPost(&wallet)
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPut,
+					depositEndpoint,
+					model.Transaction{ID: uuid.New(), TargetWalletID: &wallet.ID, Currency: currencyUSD, Sum: 100},
+					nil)
+				s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+				ctx := context.WithValue(context.Background(), model.UserInfoKey, model.UserInfo{ID: s.testOwnerID})
+				newCurrency := currencyEUR
+
+				// quoted for a currency the wallet no longer has
+				_, err := s.str.UpdateWallet(ctx, wallet.ID, model.UpdateWalletRequest{
+					Currency:       &newCurrency,
+					FromCurrency:   currencyEUR,
+					ConversionRate: 2,
+				})
+				s.Require().ErrorIs(err, model.ErrWalletWasChanged)
+				s.Require().Equal(currencyUSD, s.getWalletByID(wallet.ID).Currency)
+
+				updated, err := s.str.UpdateWallet(ctx, wallet.ID, model.UpdateWalletRequest{
+					Currency:       &newCurrency,
+					FromCurrency:   currencyUSD,
+					ConversionRate: 2,
+				})
+				s.Require().NoError(err)
+				s.Require().Equal(currencyEUR, updated.Currency)
+				s.Require().Equal(float64(200), updated.Balance)
+			})
+
 			s.Run("400/id", func() {
 				resp := s.sendRequest(
 					context.Background(),
@@ -457,6 +584,16 @@ func (s *IntegrationTestSuite) TestWallets() {
 		})
 
 		s.Run("DELETE:/wallets", func() {
+			s.Run("422/non-zero balance", func() {
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodDelete,
+					walletEndpoint+"/"+wallet1.ID.String(),
+					nil,
+					nil)
+				s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			})
+
 			s.Run("400", func() {
 				resp := s.sendRequest(
 					context.Background(),
@@ -646,7 +783,7 @@ func (s *IntegrationTestSuite) TestWallets() {
 				Sum:            10000,
 			}
 
-			xr, err := s.converter.GetExchangeRate(trans.Currency, wallet1.Currency)
+			xr, err := s.converter.GetExchangeRate(context.Background(), trans.Currency, wallet1.Currency)
 			s.Require().NoError(err)
 
 			resp := s.sendRequest(
@@ -671,7 +808,7 @@ func (s *IntegrationTestSuite) TestWallets() {
 
 				s.Require().Equal(http.StatusOK, resp.StatusCode)
 				s.Require().Equal(wallet.ID, wallet.ID)
-				s.Require().Equal(wallet1.Balance+trans.Sum*xr, wallet.Balance)
+				s.Require().Equal(wallet1.Balance+trans.Sum*xr.Bid, wallet.Balance)
 				wallet1 = wallet
 			})
 		})
@@ -750,14 +887,21 @@ func (s *IntegrationTestSuite) TestWallets() {
 					Sum:            2000,
 				}
 
+				var problem apiserver.Problem
+
 				resp := s.sendRequest(
 					context.Background(),
 					http.MethodPut,
 					transferEndpoint,
 					trans,
-					nil)
+					&problem)
 
 				s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+				s.Require().Equal("application/problem+json", resp.Header.Get("Content-Type"))
+				s.Require().Equal("not_enough_balance", problem.Code)
+				s.Require().Equal(http.StatusUnprocessableEntity, problem.Status)
+				s.Require().NotEmpty(problem.RequestID)
+				s.Require().Equal(resp.Header.Get("X-Request-Id"), problem.RequestID)
 			})
 
 			s.Run("negative sum", func() {
@@ -769,14 +913,23 @@ func (s *IntegrationTestSuite) TestWallets() {
 					Sum:            -300,
 				}
 
+				var problem apiserver.Problem
+
 				resp := s.sendRequest(
 					context.Background(),
 					http.MethodPut,
 					transferEndpoint,
 					trans,
-					nil)
+					&problem)
 
 				s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+				s.Require().Equal("application/problem+json", resp.Header.Get("Content-Type"))
+				s.Require().Equal("validation_failed", problem.Code)
+				s.Require().Equal([]apiserver.ProblemFieldError{{
+					Field:   "sum",
+					Code:    "negative_sum",
+					Message: model.ErrNegativeSum.Error(),
+				}}, problem.Errors)
 			})
 
 			s.Run("wrong currency", func() {
@@ -788,14 +941,20 @@ func (s *IntegrationTestSuite) TestWallets() {
 					Sum:            1000,
 				}
 
+				var problem apiserver.Problem
+
 				resp := s.sendRequest(
 					context.Background(),
 					http.MethodPut,
 					transferEndpoint,
 					trans,
-					nil)
+					&problem)
 
 				s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+				s.Require().Equal("application/problem+json", resp.Header.Get("Content-Type"))
+				s.Require().Equal("validation_failed", problem.Code)
+				s.Require().Equal("currency", problem.Errors[0].Field)
+				s.Require().Equal("wrong_currency", problem.Errors[0].Code)
 			})
 		})
 
@@ -860,23 +1019,23 @@ func (s *IntegrationTestSuite) TestWallets() {
 			s.Require().NotZero(respData.TransactionID)
 
 			s.Run("check first wallet", func() {
-				xr, err := s.converter.GetExchangeRate(trans.Currency, wallet1.Currency)
+				xr, err := s.converter.GetExchangeRate(context.Background(), trans.Currency, wallet1.Currency)
 				s.Require().NoError(err)
 
 				wallet := s.getWalletByID(wallet1.ID)
 
-				s.Require().Equal(wallet1.Balance-trans.Sum*xr, wallet.Balance)
+				s.Require().Equal(wallet1.Balance-trans.Sum*xr.Ask, wallet.Balance)
 
 				wallet1 = *wallet
 			})
 
 			s.Run("check second wallet", func() {
-				xr, err := s.converter.GetExchangeRate(trans.Currency, wallet2.Currency)
+				xr, err := s.converter.GetExchangeRate(context.Background(), trans.Currency, wallet2.Currency)
 				s.Require().NoError(err)
 
 				wallet := s.getWalletByID(wallet2.ID)
 
-				s.Require().Equal(wallet2.Balance+trans.Sum*xr, wallet.Balance)
+				s.Require().Equal(wallet2.Balance+trans.Sum*xr.Bid, wallet.Balance)
 
 				wallet2 = *wallet
 			})
@@ -1038,12 +1197,12 @@ func (s *IntegrationTestSuite) TestWallets() {
 			s.Require().NotZero(transferResponse.TransactionID)
 
 			s.Run("check wallet", func() {
-				xr, err := s.converter.GetExchangeRate(trans.Currency, wallet2.Currency)
+				xr, err := s.converter.GetExchangeRate(context.Background(), trans.Currency, wallet2.Currency)
 				s.Require().NoError(err)
 
 				wallet := s.getWalletByID(wallet2.ID)
 
-				s.Require().Equal(wallet2.Balance-trans.Sum*xr, wallet.Balance)
+				s.Require().Equal(wallet2.Balance-trans.Sum*xr.Ask, wallet.Balance)
 			})
 		})
 	})
@@ -1317,59 +1476,3551 @@ func (s *IntegrationTestSuite) TestWallets() {
 	})
 }
 
-func (s *IntegrationTestSuite) checkWalletPost(wallet *model.Wallet) {
-	var respWalletData model.Wallet
+func (s *IntegrationTestSuite) TestAdmin() {
+	user, err := s.str.CreateUser(context.Background(), model.User{Email: "customer@test.com"})
+	s.Require().NoError(err)
 
-	resp := s.sendRequest(context.Background(), http.MethodPost, walletEndpoint, wallet, &apiserver.HTTPResponse{Data: &respWalletData})
-	s.Require().Equal(http.StatusCreated, resp.StatusCode)
-	s.Require().Equal(wallet.Currency, respWalletData.Currency)
-	s.Require().Equal(wallet.OwnerID, respWalletData.OwnerID)
-	s.Require().Equal(wallet.Balance, respWalletData.Balance)
-	s.Require().Equal(wallet.Name, respWalletData.Name)
-	s.Require().NotZero(respWalletData.ID)
-	wallet.ID = respWalletData.ID
-}
+	userToken, err := s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
 
-func (s *IntegrationTestSuite) getWalletByID(id uuid.UUID) *model.Wallet {
-	var wallet model.Wallet
+	temp := s.authToken
+	s.authToken = userToken
 
-	resp := s.sendRequest(
-		context.Background(),
-		http.MethodGet,
-		walletEndpoint+"/"+id.String(),
-		nil,
-		&apiserver.HTTPResponse{Data: &wallet})
+	defer func() { s.authToken = temp }()
 
-	s.Require().Equal(http.StatusOK, resp.StatusCode)
-	s.Require().Equal(id, wallet.ID)
+	wallet := model.Wallet{
+		OwnerID:  user.ID,
+		Currency: currencyEUR,
+		Name:     standardName,
+	}
 
-	return &wallet
-}
+	s.checkWalletPost(&wallet)
 
-func (s *IntegrationTestSuite) sendRequest(ctx context.Context, method, endpoint string, body interface{}, dest interface{}) *http.Response {
-	s.T().Helper()
+	asAdmin := func() func() {
+		temp := s.authToken
+		s.authToken = s.adminAuthToken
 
-	reqBody, err := json.Marshal(body)
-	s.Require().NoError(err)
+		return func() { s.authToken = temp }
+	}
 
-	req, err := http.NewRequestWithContext(ctx, method, bindAddr+endpoint, bytes.NewReader(reqBody))
-	s.Require().NoError(err)
+	s.Run("403/not admin", func() {
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodGet,
+			adminEndpoint+"/users?email=test",
+			nil,
+			nil)
 
-	req.Header.Set("Content-Type", "application/json")
+		s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+	})
 
-	req.Header.Set("Authorization", "Bearer "+s.authToken)
+	s.Run("GET:/admin/users", func() {
+		defer asAdmin()()
 
-	resp, err := http.DefaultClient.Do(req)
-	s.Require().NoError(err)
+		var users []model.User
 
-	defer func() {
-		err = resp.Body.Close()
-		s.Require().NoError(err)
-	}()
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodGet,
+			adminEndpoint+"/users?email=customer",
+			nil,
+			&apiserver.HTTPResponse{Data: &users})
 
-	if dest != nil {
-		err = json.NewDecoder(resp.Body).Decode(&dest)
-		s.Require().NoError(err)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(1, len(users))
+		s.Require().Equal(user.ID, users[0].ID)
+	})
+
+	s.Run("GET:/admin/users/{id}/wallets", func() {
+		defer asAdmin()()
+
+		var wallets []model.Wallet
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodGet,
+			adminEndpoint+"/users/"+user.ID.String()+"/wallets",
+			nil,
+			&apiserver.HTTPResponse{Data: &wallets})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(1, len(wallets))
+		s.Require().Equal(wallet.ID, wallets[0].ID)
+	})
+
+	s.Run("400/sql in listing params", func() {
+		defer asAdmin()()
+
+		endpoints := []string{
+			adminEndpoint + "/users?email=customer",
+			adminEndpoint + "/users/" + user.ID.String() + "/wallets?limit=10",
+			adminEndpoint + "/users/" + user.ID.String() + "/transactions?limit=10",
+		}
+
+		for _, endpoint := range endpoints {
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				endpoint+"&sorting="+url.QueryEscape("(SELECT 1 FROM pg_sleep(0)); DROP TABLE users --"),
+				nil,
+				&problem)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode, endpoint)
+			s.Require().Equal("invalid_sorting", problem.Code)
+		}
+
+		s.Run("filter is a value", func() {
+			var wallets []model.Wallet
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				adminEndpoint+"/users/"+user.ID.String()+"/wallets?filter="+url.QueryEscape("' OR '1'='1"),
+				nil,
+				&apiserver.HTTPResponse{Data: &wallets})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Empty(wallets)
+
+			var transactions []model.Transaction
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				adminEndpoint+"/users/"+user.ID.String()+"/transactions?filter="+url.QueryEscape("' OR '1'='1"),
+				nil,
+				&apiserver.HTTPResponse{Data: &transactions})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Empty(transactions)
+		})
+	})
+
+	s.Run("PUT:/admin/wallets/{id}/adjust", func() {
+		s.Run("422/no reason", func() {
+			defer asAdmin()()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/adjust",
+				model.BalanceAdjustment{ID: uuid.New(), Sum: 50},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("200", func() {
+			defer asAdmin()()
+
+			var respData apiserver.TransferResponse
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/adjust",
+				model.BalanceAdjustment{ID: uuid.New(), Sum: 50, Reason: "compensation"},
+				&apiserver.HTTPResponse{Data: &respData})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().NotZero(respData.TransactionID)
+		})
+
+		s.Run("check wallet balance", func() {
+			s.Require().Equal(float64(50), s.getWalletByID(wallet.ID).Balance)
+		})
+	})
+
+	s.Run("PUT:/admin/wallets/{id}/freeze", func() {
+		s.Run("200", func() {
+			defer asAdmin()()
+
+			var respData model.Wallet
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/freeze",
+				model.AdminActionRequest{Reason: "suspicious activity"},
+				&apiserver.HTTPResponse{Data: &respData})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(model.WalletStatusFrozen, respData.Status)
+		})
+
+		s.Run("422/withdraw from frozen wallet", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				withdrawEndpoint,
+				model.Transaction{
+					ID:             uuid.New(),
+					TargetWalletID: &wallet.ID,
+					Currency:       wallet.Currency,
+					Sum:            10,
+				},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("422/adjust frozen wallet", func() {
+			defer asAdmin()()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/adjust",
+				model.BalanceAdjustment{ID: uuid.New(), Sum: -10, Reason: "correction"},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("200/unfreeze", func() {
+			defer asAdmin()()
+
+			var respData model.Wallet
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/unfreeze",
+				model.AdminActionRequest{Reason: "checked"},
+				&apiserver.HTTPResponse{Data: &respData})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(model.WalletStatusActive, respData.Status)
+		})
+	})
+
+	s.Run("PUT:/admin/wallets/{id}/status", func() {
+		s.Run("200/frozen for debits", func() {
+			defer asAdmin()()
+
+			var respData model.Wallet
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/status",
+				model.WalletStatusRequest{Status: model.WalletStatusFrozenDebit, Reason: "chargeback"},
+				&apiserver.HTTPResponse{Data: &respData})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(model.WalletStatusFrozenDebit, respData.Status)
+			s.Require().Equal("chargeback", respData.StatusReason)
+		})
+
+		s.Run("200/deposit into wallet frozen for debits", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				depositEndpoint,
+				model.Transaction{
+					ID:             uuid.New(),
+					TargetWalletID: &wallet.ID,
+					Currency:       wallet.Currency,
+					Sum:            10,
+				},
+				nil)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+		})
+
+		s.Run("422/withdraw from wallet frozen for debits", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				withdrawEndpoint,
+				model.Transaction{
+					ID:             uuid.New(),
+					TargetWalletID: &wallet.ID,
+					Currency:       wallet.Currency,
+					Sum:            10,
+				},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("409/closing is not a status change", func() {
+			defer asAdmin()()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/status",
+				model.WalletStatusRequest{Status: model.WalletStatusClosed, Reason: "closing"},
+				nil)
+
+			s.Require().Equal(http.StatusConflict, resp.StatusCode)
+		})
+	})
+
+	s.Run("DELETE:/wallets/{id}?sweepTo", func() {
+		sweepWallet := model.Wallet{
+			OwnerID:  user.ID,
+			Currency: currencyUSD,
+			Name:     secondaryName,
+		}
+
+		s.checkWalletPost(&sweepWallet)
+
+		balance := s.getWalletByID(wallet.ID).Balance
+
+		s.Run("422/wallet frozen for debits", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodDelete,
+				walletEndpoint+"/"+wallet.ID.String()+"?sweepTo="+sweepWallet.ID.String(),
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("422/close frozen wallet with zero balance", func() {
+			empty := model.Wallet{
+				OwnerID:  user.ID,
+				Currency: currencyUSD,
+				Name:     "frozen and empty",
+			}
+
+			s.checkWalletPost(&empty)
+
+			func() {
+				defer asAdmin()()
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPut,
+					adminEndpoint+"/wallets/"+empty.ID.String()+"/status",
+					model.WalletStatusRequest{Status: model.WalletStatusFrozen, Reason: "investigation"},
+					nil)
+
+				s.Require().Equal(http.StatusOK, resp.StatusCode)
+			}()
+
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodDelete,
+				walletEndpoint+"/"+empty.ID.String(),
+				nil,
+				&problem)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			s.Require().Equal("wallet_frozen", problem.Code)
+			s.Require().Equal(model.WalletStatusFrozen, s.getWalletByID(empty.ID).Status)
+		})
+
+		s.Run("204", func() {
+			func() {
+				defer asAdmin()()
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodPut,
+					adminEndpoint+"/wallets/"+wallet.ID.String()+"/status",
+					model.WalletStatusRequest{Status: model.WalletStatusActive, Reason: "resolved"},
+					nil)
+
+				s.Require().Equal(http.StatusOK, resp.StatusCode)
+			}()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodDelete,
+				walletEndpoint+"/"+wallet.ID.String()+"?sweepTo="+sweepWallet.ID.String(),
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+		})
+
+		s.Run("check swept balance", func() {
+			xr, err := s.converter.GetExchangeRate(context.Background(), wallet.Currency, sweepWallet.Currency)
+			s.Require().NoError(err)
+
+			s.Require().Equal(balance*xr.Bid, s.getWalletByID(sweepWallet.ID).Balance)
+		})
+
+		s.Run("close event in transactions", func() {
+			var transactions []model.Transaction
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				transactionsEndpoint+"?limit=100",
+				nil,
+				&apiserver.HTTPResponse{Data: &transactions})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			isCloseFound := false
+
+			for _, transaction := range transactions {
+				if transaction.Type == model.TransactionTypeClose && *transaction.AgentWalletID == wallet.ID {
+					isCloseFound = true
+				}
+			}
+
+			s.Require().True(isCloseFound)
+		})
+	})
+
+	s.Run("GET:/audit", func() {
+		s.Run("200/owner", func() {
+			var entries []model.AuditEntry
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				auditEndpoint+"?limit=100&entityId="+wallet.ID.String(),
+				nil,
+				&apiserver.HTTPResponse{Data: &entries})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			actions := make(map[string]bool)
+			for _, entry := range entries {
+				s.Require().Equal(wallet.ID, *entry.EntityID)
+
+				// admin actions on the wallet are listed without the admin's details
+				if entry.ActorID != nil {
+					s.Require().Equal(user.ID, *entry.ActorID)
+					s.Require().NotEmpty(entry.RequestID)
+				} else {
+					s.Require().Empty(entry.RequestID)
+					s.Require().Empty(entry.ClientIP)
+				}
+
+				actions[entry.Action] = true
+			}
+
+			s.Require().True(actions[model.AuditActionCreateWallet])
+			s.Require().True(actions[model.AuditActionAdjustBalance])
+			s.Require().True(actions[model.AuditActionChangeStatus])
+			s.Require().True(actions[model.AuditActionDeposit])
+			s.Require().True(actions[model.AuditActionCloseWallet])
+		})
+
+		s.Run("200/other user sees nothing", func() {
+			var entries []model.AuditEntry
+
+			temp := s.authToken
+			s.authToken = s.secondAuthToken
+
+			defer func() { s.authToken = temp }()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				auditEndpoint+"?entityId="+wallet.ID.String(),
+				nil,
+				&apiserver.HTTPResponse{Data: &entries})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Zero(len(entries))
+		})
+
+		s.Run("200/transfer recipient does not see sender details", func() {
+			senderWallet := model.Wallet{
+				OwnerID:  user.ID,
+				Currency: currencyEUR,
+				Name:     thirdName,
+			}
+
+			s.checkWalletPost(&senderWallet)
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				depositEndpoint,
+				model.Transaction{
+					ID:             uuid.New(),
+					TargetWalletID: &senderWallet.ID,
+					Currency:       senderWallet.Currency,
+					Sum:            100,
+				},
+				nil)
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			recipientWallet := model.Wallet{
+				OwnerID:  s.secondOwnerID,
+				Currency: currencyEUR,
+				Name:     thirdName,
+			}
+
+			func() {
+				temp := s.authToken
+				s.authToken = s.secondAuthToken
+
+				defer func() { s.authToken = temp }()
+
+				s.checkWalletPost(&recipientWallet)
+			}()
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				transferEndpoint,
+				model.Transaction{
+					ID:             uuid.New(),
+					AgentWalletID:  &senderWallet.ID,
+					TargetWalletID: &recipientWallet.ID,
+					Currency:       currencyEUR,
+					Sum:            10,
+				},
+				nil)
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			getEntries := func(token string, walletID uuid.UUID) map[string]model.AuditEntry {
+				temp := s.authToken
+				s.authToken = token
+
+				defer func() { s.authToken = temp }()
+
+				var entries []model.AuditEntry
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodGet,
+					auditEndpoint+"?limit=100&entityId="+walletID.String(),
+					nil,
+					&apiserver.HTTPResponse{Data: &entries})
+				s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+				byAction := make(map[string]model.AuditEntry)
+				for _, entry := range entries {
+					byAction[entry.Action] = entry
+				}
+
+				return byAction
+			}
+
+			received := getEntries(s.secondAuthToken, recipientWallet.ID)
+
+			transferIn, ok := received[model.AuditActionTransferIn]
+			s.Require().True(ok)
+			s.Require().Nil(transferIn.ActorID)
+			s.Require().Empty(transferIn.RequestID)
+			s.Require().Empty(transferIn.ClientIP)
+
+			created, ok := received[model.AuditActionCreateWallet]
+			s.Require().True(ok)
+			s.Require().Equal(s.secondOwnerID, *created.ActorID)
+			s.Require().NotEmpty(created.RequestID)
+
+			sent := getEntries(s.authToken, senderWallet.ID)
+
+			transferOut, ok := sent[model.AuditActionTransferOut]
+			s.Require().True(ok)
+			s.Require().Equal(user.ID, *transferOut.ActorID)
+			s.Require().NotEmpty(transferOut.RequestID)
+
+			s.Run("actor filter", func() {
+				temp := s.authToken
+				s.authToken = s.secondAuthToken
+
+				defer func() { s.authToken = temp }()
+
+				var entries []model.AuditEntry
+
+				resp := s.sendRequest(
+					context.Background(),
+					http.MethodGet,
+					auditEndpoint+"?actorId="+user.ID.String(),
+					nil,
+					&apiserver.HTTPResponse{Data: &entries})
+				s.Require().Equal(http.StatusOK, resp.StatusCode)
+				s.Require().Zero(len(entries))
+			})
+		})
+
+		s.Run("200/admin filter by action", func() {
+			defer asAdmin()()
+
+			var entries []model.AuditEntry
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				auditEndpoint+"?action="+model.AuditActionSearchUsers,
+				nil,
+				&apiserver.HTTPResponse{Data: &entries})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().NotZero(len(entries))
+
+			for _, entry := range entries {
+				s.Require().Equal(model.AuditActionSearchUsers, entry.Action)
+			}
+		})
+
+		s.Run("400", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				auditEndpoint+"?entityId="+badRequestString,
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+
+	s.Run("GET:/admin/reports/spread-income", func() {
+		s.Run("200", func() {
+			defer asAdmin()()
+
+			var reports []model.SpreadIncomeReport
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				adminEndpoint+"/reports/spread-income?from="+url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339)),
+				nil,
+				&apiserver.HTTPResponse{Data: &reports})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			for _, report := range reports {
+				s.Require().GreaterOrEqual(report.Amount, float64(0))
+				s.Require().NotZero(report.Conversions)
+			}
+		})
+
+		s.Run("403", func() {
+			resp := s.sendRequest(context.Background(), http.MethodGet, adminEndpoint+"/reports/spread-income", nil, nil)
+
+			s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+		})
+
+		s.Run("400", func() {
+			defer asAdmin()()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				adminEndpoint+"/reports/spread-income?from="+badRequestString,
+				nil,
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) TestWebhooks() {
+	user, err := s.str.CreateUser(context.Background(), model.User{Email: "merchant@test.com"})
+	s.Require().NoError(err)
+
+	userToken, err := s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
+
+	temp := s.authToken
+	s.authToken = userToken
+
+	defer func() { s.authToken = temp }()
+
+	wallet := model.Wallet{
+		OwnerID:  user.ID,
+		Currency: currencyEUR,
+		Name:     standardName,
+	}
+
+	s.checkWalletPost(&wallet)
+
+	type received struct {
+		signature string
+		body      []byte
+	}
+
+	deliveries := make(chan received, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		s.Require().NoError(err)
+
+		deliveries <- received{signature: r.Header.Get(webhook.SignatureHeader), body: body}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dispatcher := webhook.NewDispatcher(webhook.Config{
+		PollInterval: 50 * time.Millisecond,
+		BatchSize:    10,
+		Timeout:      time.Second,
+		MaxAttempts:  1,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   time.Second,
+
+		// the receivers listen on loopback
+		AllowPrivateNetworks: true,
+	}, s.str)
+
+	go func() {
+		err := dispatcher.Run(ctx)
+		s.Require().NoError(err)
+	}()
+
+	var subscription, failingSubscription model.WebhookSubscription
+
+	s.Run("POST:/webhooks", func() {
+		s.Run("422/bad url", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				webhooksEndpoint,
+				model.WebhookSubscription{URL: "ftp://example.com"},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("422/unknown event", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				webhooksEndpoint,
+				model.WebhookSubscription{URL: receiver.URL, Events: []string{badRequestString}},
+				nil)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("201", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				webhooksEndpoint,
+				model.WebhookSubscription{URL: receiver.URL, Events: []string{model.EventFundsDeposited}},
+				&apiserver.HTTPResponse{Data: &subscription})
+
+			s.Require().Equal(http.StatusCreated, resp.StatusCode)
+			s.Require().NotZero(subscription.ID)
+			s.Require().NotEmpty(subscription.Secret)
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				webhooksEndpoint,
+				model.WebhookSubscription{URL: failing.URL, Secret: "secret"},
+				&apiserver.HTTPResponse{Data: &failingSubscription})
+
+			s.Require().Equal(http.StatusCreated, resp.StatusCode)
+		})
+	})
+
+	s.Run("GET:/webhooks", func() {
+		var subscriptions []model.WebhookSubscription
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodGet,
+			webhooksEndpoint,
+			nil,
+			&apiserver.HTTPResponse{Data: &subscriptions})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(2, len(subscriptions))
+		s.Require().Empty(subscriptions[0].Secret)
+	})
+
+	s.Run("signed delivery", func() {
+		depositID := uuid.New()
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			depositEndpoint,
+			model.Transaction{
+				ID:             depositID,
+				TargetWalletID: &wallet.ID,
+				Currency:       wallet.Currency,
+				Sum:            100,
+			},
+			nil)
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		var delivery received
+
+		select {
+		case delivery = <-deliveries:
+		case <-time.After(5 * time.Second):
+			s.FailNow("webhook was not delivered")
+		}
+
+		var (
+			transaction model.Transaction
+			payload     = model.WebhookPayload{Data: &transaction}
+		)
+
+		s.Require().NoError(json.Unmarshal(delivery.body, &payload))
+		s.Require().Equal(model.EventFundsDeposited, payload.Event)
+		s.Require().Equal(depositID, transaction.ID)
+		s.Require().Equal(model.TransactionTypeDeposit, transaction.Type)
+
+		var timestamp int64
+
+		_, err := fmt.Sscanf(delivery.signature, "t=%d,", &timestamp)
+		s.Require().NoError(err)
+		s.Require().Equal(webhook.Sign(subscription.Secret, timestamp, delivery.body), delivery.signature)
+	})
+
+	s.Run("GET:/webhooks/{id}/deliveries", func() {
+		var log []model.WebhookDelivery
+
+		s.Require().Eventually(func() bool {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				webhooksEndpoint+"/"+subscription.ID.String()+"/deliveries",
+				nil,
+				&apiserver.HTTPResponse{Data: &log})
+
+			return resp.StatusCode == http.StatusOK &&
+				len(log) == 1 && log[0].Status == model.DeliveryStatusSucceeded
+		}, 5*time.Second, 100*time.Millisecond)
+
+		s.Require().Equal(http.StatusOK, log[0].LastStatusCode)
+		s.Require().Equal(1, log[0].Attempts)
+	})
+
+	s.Run("dead letter", func() {
+		var log []model.WebhookDelivery
+
+		s.Require().Eventually(func() bool {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodGet,
+				webhooksEndpoint+"/"+failingSubscription.ID.String()+"/deliveries?status=dead",
+				nil,
+				&apiserver.HTTPResponse{Data: &log})
+
+			return resp.StatusCode == http.StatusOK && len(log) == 1
+		}, 5*time.Second, 100*time.Millisecond)
+
+		s.Require().Equal(http.StatusInternalServerError, log[0].LastStatusCode)
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			webhooksEndpoint+"/deliveries/"+log[0].ID.String()+"/retry",
+			nil,
+			nil)
+
+		s.Require().Equal(http.StatusAccepted, resp.StatusCode)
+
+		temp := s.authToken
+		s.authToken = s.secondAuthToken
+
+		resp = s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			webhooksEndpoint+"/deliveries/"+log[0].ID.String()+"/retry",
+			nil,
+			nil)
+
+		s.authToken = temp
+
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("private destination", func() {
+		hit := make(chan struct{}, 1)
+
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hit <- struct{}{}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer target.Close()
+
+		attempt := s.dispatchOnce(webhook.Config{}, target.URL)
+
+		s.Require().False(attempt.Succeeded)
+		s.Require().Contains(attempt.Error, "not public")
+		s.Require().Empty(hit)
+
+		// shared address space is rejected before anything is dialled
+		attempt = s.dispatchOnce(webhook.Config{}, "http://100.64.0.1:8080")
+
+		s.Require().False(attempt.Succeeded)
+		s.Require().Contains(attempt.Error, "not public")
+	})
+
+	s.Run("redirect is not followed", func() {
+		hit := make(chan struct{}, 1)
+
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hit <- struct{}{}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer target.Close()
+
+		redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+		defer redirect.Close()
+
+		attempt := s.dispatchOnce(webhook.Config{AllowPrivateNetworks: true}, redirect.URL)
+
+		s.Require().False(attempt.Succeeded)
+		s.Require().Equal(http.StatusTemporaryRedirect, attempt.StatusCode)
+		s.Require().Empty(hit)
+	})
+
+	s.Run("DELETE:/webhooks/{id}", func() {
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodDelete,
+			webhooksEndpoint+"/"+failingSubscription.ID.String(),
+			nil,
+			nil)
+
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		resp = s.sendRequest(
+			context.Background(),
+			http.MethodDelete,
+			webhooksEndpoint+"/"+failingSubscription.ID.String(),
+			nil,
+			nil)
+
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("sweep on close is delivered", func() {
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPost,
+			webhooksEndpoint,
+			model.WebhookSubscription{URL: receiver.URL, Events: []string{model.EventTransferCompleted}},
+			nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		closing := model.Wallet{
+			OwnerID:  user.ID,
+			Currency: currencyEUR,
+			Name:     secondaryName,
+		}
+
+		s.checkWalletPost(&closing)
+
+		resp = s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			depositEndpoint,
+			model.Transaction{
+				ID:             uuid.New(),
+				TargetWalletID: &closing.ID,
+				Currency:       closing.Currency,
+				Sum:            15,
+			},
+			nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		resp = s.sendRequest(
+			context.Background(),
+			http.MethodDelete,
+			walletEndpoint+"/"+closing.ID.String()+"?sweepTo="+wallet.ID.String(),
+			nil,
+			nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		var (
+			transaction model.Transaction
+			payload     = model.WebhookPayload{Data: &transaction}
+		)
+
+		// the deposit above is delivered to the first subscription as well
+		for payload.Event != model.EventTransferCompleted {
+			select {
+			case delivery := <-deliveries:
+				s.Require().NoError(json.Unmarshal(delivery.body, &payload))
+			case <-time.After(5 * time.Second):
+				s.FailNow("sweep was not delivered")
+			}
+		}
+
+		s.Require().Equal(model.TransactionTypeSweep, transaction.Type)
+		s.Require().Equal(closing.ID, *transaction.AgentWalletID)
+		s.Require().Equal(wallet.ID, *transaction.TargetWalletID)
+		s.Require().Equal(float64(15), transaction.Sum)
+	})
+}
+
+// deliveryStore hands a single delivery to a dispatcher and records its attempt.
+type deliveryStore struct {
+	deliveries chan *model.WebhookDelivery
+	attempts   chan model.WebhookAttempt
+}
+
+func (d *deliveryStore) ClaimWebhookDeliveries(context.Context, int, time.Duration) ([]*model.WebhookDelivery, error) {
+	select {
+	case delivery := <-d.deliveries:
+		return []*model.WebhookDelivery{delivery}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (d *deliveryStore) RecordWebhookAttempt(_ context.Context, attempt model.WebhookAttempt) error {
+	d.attempts <- attempt
+
+	return nil
+}
+
+// dispatchOnce delivers one webhook to url with a dispatcher of its own
+// and returns the attempt.
+func (s *IntegrationTestSuite) dispatchOnce(cfg webhook.Config, url string) model.WebhookAttempt {
+	db := &deliveryStore{
+		deliveries: make(chan *model.WebhookDelivery, 1),
+		attempts:   make(chan model.WebhookAttempt, 1),
+	}
+
+	db.deliveries <- &model.WebhookDelivery{
+		ID:      uuid.New(),
+		Event:   model.EventFundsDeposited,
+		Payload: []byte("{}"),
+		URL:     url,
+		Secret:  "secret",
+	}
+
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.BatchSize = 1
+	cfg.Timeout = time.Second
+	cfg.MaxAttempts = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		err := webhook.NewDispatcher(cfg, db).Run(ctx)
+		s.Require().NoError(err)
+	}()
+
+	select {
+	case attempt := <-db.attempts:
+		return attempt
+	case <-time.After(5 * time.Second):
+		s.FailNow("webhook was not attempted")
+	}
+
+	return model.WebhookAttempt{}
+}
+
+func (s *IntegrationTestSuite) TestOutbox() {
+	// publish whatever earlier tests left behind
+	s.drainOutbox()
+
+	walletA := model.Wallet{
+		OwnerID:  s.testOwnerID,
+		Currency: currencyEUR,
+		Name:     standardName,
+	}
+
+	s.checkWalletPost(&walletA)
+
+	walletB := model.Wallet{
+		OwnerID:  s.testOwnerID,
+		Currency: currencyEUR,
+		Name:     secondaryName,
+	}
+
+	s.checkWalletPost(&walletB)
+
+	moveFunds := func(endpoint string, transaction model.Transaction, status int) {
+		transaction.ID = uuid.New()
+		transaction.Currency = currencyEUR
+
+		resp := s.sendRequest(context.Background(), http.MethodPut, endpoint, transaction, nil)
+		s.Require().Equal(status, resp.StatusCode)
+	}
+
+	moveFunds(depositEndpoint, model.Transaction{TargetWalletID: &walletA.ID, Sum: 100}, http.StatusOK)
+	moveFunds(withdrawEndpoint, model.Transaction{TargetWalletID: &walletA.ID, Sum: 30}, http.StatusOK)
+	moveFunds(transferEndpoint, model.Transaction{AgentWalletID: &walletA.ID, TargetWalletID: &walletB.ID, Sum: 20}, http.StatusOK)
+
+	// rolled back, so nothing may reach the outbox
+	moveFunds(withdrawEndpoint, model.Transaction{TargetWalletID: &walletA.ID, Sum: 1000}, http.StatusUnprocessableEntity)
+
+	resp := s.sendRequest(
+		context.Background(),
+		http.MethodDelete,
+		walletEndpoint+"/"+walletB.ID.String()+"?sweepTo="+walletA.ID.String(),
+		nil,
+		nil)
+	s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+	s.Run("events of every operation", func() {
+		events := s.walletEvents(s.drainOutbox(), walletA.ID, walletB.ID)
+
+		types := make([]string, 0, len(events))
+		for _, event := range events {
+			s.Require().Equal(model.EventSchemaVersion, event.Version)
+
+			types = append(types, event.Type)
+		}
+
+		s.Require().Equal([]string{
+			model.EventWalletCreated,
+			model.EventWalletCreated,
+			model.EventFundsDeposited,
+			model.EventFundsWithdrawn,
+			model.EventTransferCompleted,
+			model.EventTransferCompleted,
+			model.EventWalletClosed,
+		}, types)
+
+		var created model.WalletCreatedV1
+
+		s.Require().NoError(json.Unmarshal(events[0].Payload, &created))
+		s.Require().Equal(walletA.ID, events[0].AggregateID)
+		s.Require().Equal(model.WalletCreatedV1{
+			WalletID: walletA.ID,
+			OwnerID:  s.testOwnerID,
+			Currency: currencyEUR,
+			Name:     standardName,
+		}, created)
+
+		var deposited, withdrawn model.FundsMovedV1
+
+		s.Require().NoError(json.Unmarshal(events[2].Payload, &deposited))
+		s.Require().Equal(walletA.ID, deposited.WalletID)
+		s.Require().Equal(float64(100), deposited.Amount)
+		s.Require().Equal(float64(100), deposited.Balance)
+
+		s.Require().NoError(json.Unmarshal(events[3].Payload, &withdrawn))
+		s.Require().Equal(float64(30), withdrawn.Amount)
+		s.Require().Equal(float64(70), withdrawn.Balance)
+
+		var transferred model.TransferCompletedV1
+
+		s.Require().NoError(json.Unmarshal(events[4].Payload, &transferred))
+		s.Require().Equal(transferred.TransactionID, events[4].AggregateID)
+		s.Require().Equal(walletA.ID, transferred.FromWalletID)
+		s.Require().Equal(walletB.ID, transferred.ToWalletID)
+		s.Require().Equal(float64(20), transferred.SumWithdrawn)
+		s.Require().Equal(float64(50), transferred.FromBalance)
+		s.Require().Equal(float64(20), transferred.ToBalance)
+
+		var swept model.TransferCompletedV1
+
+		s.Require().NoError(json.Unmarshal(events[5].Payload, &swept))
+		s.Require().Equal(walletB.ID, swept.FromWalletID)
+		s.Require().Equal(walletA.ID, swept.ToWalletID)
+		s.Require().Equal(float64(20), swept.SumDeposited)
+		s.Require().Equal(float64(0), swept.FromBalance)
+		s.Require().Equal(float64(70), swept.ToBalance)
+
+		var closed model.WalletClosedV1
+
+		s.Require().NoError(json.Unmarshal(events[6].Payload, &closed))
+		s.Require().Equal(walletB.ID, closed.WalletID)
+		s.Require().Equal(walletA.ID, *closed.SweptTo)
+		s.Require().Equal(float64(20), closed.SweptAmount)
+	})
+
+	s.Run("failed publish keeps events", func() {
+		moveFunds(depositEndpoint, model.Transaction{TargetWalletID: &walletA.ID, Sum: 10}, http.StatusOK)
+
+		_, err := s.str.PublishOutbox(context.Background(), 100, func(context.Context, []model.Event) error {
+			return model.ErrPublishingEvents
+		})
+		s.Require().ErrorIs(err, model.ErrPublishingEvents)
+
+		events := s.walletEvents(s.drainOutbox(), walletA.ID)
+		s.Require().Equal(1, len(events))
+		s.Require().Equal(model.EventFundsDeposited, events[0].Type)
+
+		s.Require().Zero(len(s.drainOutbox()))
+	})
+
+	event := model.Event{
+		ID:          uuid.New(),
+		Sequence:    1,
+		Type:        model.EventWalletCreated,
+		Version:     model.EventSchemaVersion,
+		AggregateID: walletA.ID,
+		OccurredAt:  time.Now().UTC().Truncate(time.Millisecond),
+		Payload:     json.RawMessage(`{"walletId":"` + walletA.ID.String() + `"}`),
+	}
+
+	s.Run("file publisher", func() {
+		path := filepath.Join(s.T().TempDir(), "events.jsonl")
+
+		publisher := outbox.NewFilePublisher(path)
+		s.Require().NoError(publisher.Publish(context.Background(), []model.Event{event}))
+		s.Require().NoError(publisher.Publish(context.Background(), []model.Event{event}))
+
+		data, err := os.ReadFile(path)
+		s.Require().NoError(err)
+
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		s.Require().Equal(2, len(lines))
+
+		for _, line := range lines {
+			var written model.Event
+
+			s.Require().NoError(json.Unmarshal([]byte(line), &written))
+			s.Require().Equal(event.ID, written.ID)
+			s.Require().Equal(event.Type, written.Type)
+			s.Require().JSONEq(string(event.Payload), string(written.Payload))
+		}
+	})
+
+	s.Run("http publisher", func() {
+		var received []model.Event
+
+		sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer sink.Close()
+
+		err := outbox.NewHTTPPublisher(sink.URL).Publish(context.Background(), []model.Event{event})
+		s.Require().NoError(err)
+		s.Require().Equal(1, len(received))
+		s.Require().Equal(event.ID, received[0].ID)
+	})
+
+	s.Run("http publisher/sink fails", func() {
+		sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer sink.Close()
+
+		err := outbox.NewHTTPPublisher(sink.URL).Publish(context.Background(), []model.Event{event})
+		s.Require().ErrorIs(err, model.ErrPublishingEvents)
+	})
+}
+
+// drainOutbox publishes every unpublished event and returns them.
+func (s *IntegrationTestSuite) drainOutbox() []model.Event {
+	const batchSize = 100
+
+	var published []model.Event
+
+	for {
+		count, err := s.str.PublishOutbox(context.Background(), batchSize, func(_ context.Context, events []model.Event) error {
+			published = append(published, events...)
+
+			return nil
+		})
+		s.Require().NoError(err)
+
+		if count < batchSize {
+			return published
+		}
+	}
+}
+
+// walletEvents keeps the events that concern any of the wallets.
+func (s *IntegrationTestSuite) walletEvents(events []model.Event, walletIDs ...uuid.UUID) []model.Event {
+	filtered := make([]model.Event, 0, len(events))
+
+	for _, event := range events {
+		var payload map[string]any
+
+		s.Require().NoError(json.Unmarshal(event.Payload, &payload))
+
+		for _, walletID := range walletIDs {
+			if payload["walletId"] == walletID.String() || payload["fromWalletId"] == walletID.String() ||
+				payload["toWalletId"] == walletID.String() {
+				filtered = append(filtered, event)
+
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+func (s *IntegrationTestSuite) TestStream() {
+	user, err := s.str.CreateUser(context.Background(), model.User{Email: "mobile@test.com"})
+	s.Require().NoError(err)
+
+	userToken, err := s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
+
+	temp := s.authToken
+	s.authToken = userToken
+
+	defer func() { s.authToken = temp }()
+
+	wallet := model.Wallet{
+		OwnerID:  user.ID,
+		Currency: currencyEUR,
+		Name:     standardName,
+	}
+
+	s.checkWalletPost(&wallet)
+
+	deposit := func() {
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			depositEndpoint,
+			model.Transaction{
+				ID:             uuid.New(),
+				TargetWalletID: &wallet.ID,
+				Currency:       wallet.Currency,
+				Sum:            100,
+			},
+			nil)
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	}
+
+	var lastEventID string
+
+	s.Run("live", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		stream := s.openStream(ctx, "")
+
+		deposit()
+
+		id, event := s.readStreamEvent(stream)
+		s.Require().Equal(model.EventFundsDeposited, event.Type)
+		s.Require().Equal(strconv.FormatInt(event.Sequence, 10), id)
+
+		var payload model.FundsMovedV1
+
+		s.Require().NoError(json.Unmarshal(event.Payload, &payload))
+		s.Require().Equal(wallet.ID, payload.WalletID)
+		s.Require().Equal(float64(100), payload.Balance)
+
+		lastEventID = id
+	})
+
+	s.Run("resume", func() {
+		// missed while disconnected
+		deposit()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		stream := s.openStream(ctx, lastEventID)
+
+		_, event := s.readStreamEvent(stream)
+		s.Require().Equal(model.EventFundsDeposited, event.Type)
+
+		var payload model.FundsMovedV1
+
+		s.Require().NoError(json.Unmarshal(event.Payload, &payload))
+		s.Require().Equal(float64(200), payload.Balance)
+	})
+
+	s.Run("admin changes", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		stream := s.openStream(ctx, "")
+
+		func() {
+			temp := s.authToken
+			s.authToken = s.adminAuthToken
+
+			defer func() { s.authToken = temp }()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/adjust",
+				model.BalanceAdjustment{ID: uuid.New(), Sum: 50, Reason: "compensation"},
+				nil)
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			resp = s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+"/wallets/"+wallet.ID.String()+"/freeze",
+				model.AdminActionRequest{Reason: "suspicious activity"},
+				nil)
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+		}()
+
+		_, event := s.readStreamEvent(stream)
+		s.Require().Equal(model.EventBalanceAdjusted, event.Type)
+
+		var adjusted model.BalanceAdjustedV1
+
+		s.Require().NoError(json.Unmarshal(event.Payload, &adjusted))
+		s.Require().Equal(float64(250), adjusted.Balance)
+		s.Require().Equal("compensation", adjusted.Reason)
+
+		_, event = s.readStreamEvent(stream)
+		s.Require().Equal(model.EventStatusChanged, event.Type)
+
+		var changed model.WalletStatusChangedV1
+
+		s.Require().NoError(json.Unmarshal(event.Payload, &changed))
+		s.Require().Equal(model.WalletStatusFrozen, changed.Status)
+		s.Require().Equal(model.WalletStatusActive, changed.PreviousStatus)
+	})
+
+	s.Run("commits out of sequence order", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		stream := s.openStream(ctx, "")
+
+		insertEvent := func(tx pgx.Tx) int64 {
+			var sequence int64
+
+			err := tx.QueryRow(
+				ctx,
+				`INSERT INTO outbox (event_id, type, version, aggregate_id, payload)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id`,
+				uuid.New(), model.EventFundsDeposited, model.EventSchemaVersion, wallet.ID,
+				model.FundsMovedV1{TransactionID: uuid.New(), WalletID: wallet.ID, Currency: wallet.Currency},
+			).Scan(&sequence)
+			s.Require().NoError(err)
+
+			return sequence
+		}
+
+		first, second := s.connectDB(ctx), s.connectDB(ctx)
+
+		firstTx, err := first.Begin(ctx)
+		s.Require().NoError(err)
+
+		firstSequence := insertEvent(firstTx)
+
+		secondTx, err := second.Begin(ctx)
+		s.Require().NoError(err)
+
+		secondSequence := insertEvent(secondTx)
+		s.Require().Greater(secondSequence, firstSequence)
+
+		// the later sequence commits first and must wait for the earlier one
+		s.Require().NoError(secondTx.Commit(ctx))
+
+		time.Sleep(500 * time.Millisecond)
+
+		s.Require().NoError(firstTx.Commit(ctx))
+
+		firstID, _ := s.readStreamEvent(stream)
+		secondID, _ := s.readStreamEvent(stream)
+
+		s.Require().ElementsMatch(
+			[]string{strconv.FormatInt(firstSequence, 10), strconv.FormatInt(secondSequence, 10)},
+			[]string{firstID, secondID})
+	})
+
+	s.Run("400/bad Last-Event-ID", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, bindAddr+streamEndpoint, nil)
+		s.Require().NoError(err)
+
+		req.Header.Set("Authorization", "Bearer "+s.authToken)
+		req.Header.Set("Last-Event-ID", badRequestString)
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.Body.Close())
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+// connectDB opens a connection of its own to the test database,
+// for transactions the store does not expose.
+func testDSN() string {
+	cfg := config.New()
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.PGUser, cfg.PGPassword),
+		Host:     cfg.PGHost + ":" + cfg.PGPort,
+		Path:     cfg.PGDatabase,
+		RawQuery: "sslmode=disable",
+	}
+
+	return dsn.String()
+}
+
+func (s *IntegrationTestSuite) connectDB(ctx context.Context) *pgx.Conn {
+	conn, err := pgx.Connect(ctx, testDSN())
+	s.Require().NoError(err)
+
+	s.T().Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	return conn
+}
+
+func (s *IntegrationTestSuite) openStream(ctx context.Context, lastEventID string) *bufio.Reader {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bindAddr+streamEndpoint, nil)
+	s.Require().NoError(err)
+
+	req.Header.Set("Authorization", "Bearer "+s.authToken)
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	s.T().Cleanup(func() { _ = resp.Body.Close() })
+
+	return bufio.NewReader(resp.Body)
+}
+
+func (s *IntegrationTestSuite) readStreamEvent(stream *bufio.Reader) (string, model.Event) {
+	var (
+		id    string
+		event model.Event
+	)
+
+	for {
+		line, err := stream.ReadString('\n')
+		s.Require().NoError(err)
+
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			s.Require().NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		case line == "" && id != "":
+			return id, event
+		}
+	}
+}
+
+func (s *IntegrationTestSuite) TestGRPC() {
+	user, err := s.str.CreateUser(context.Background(), model.User{Email: "internal@test.com"})
+	s.Require().NoError(err)
+
+	userToken, err := s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
+
+	conn, err := grpc.Dial(s.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+
+	defer func() { s.Require().NoError(conn.Close()) }()
+
+	client := walletv1.NewWalletServiceClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+userToken)
+
+	requireCode := func(code codes.Code, err error) {
+		s.T().Helper()
+		s.Require().Error(err)
+		s.Require().Equal(code, status.Code(err))
+	}
+
+	var wallet *walletv1.Wallet
+
+	s.Run("unauthenticated", func() {
+		_, err := client.ListWallets(context.Background(), &walletv1.ListWalletsRequest{})
+		requireCode(codes.Unauthenticated, err)
+
+		badCtx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+badRequestString)
+
+		_, err = client.ListWallets(badCtx, &walletv1.ListWalletsRequest{})
+		requireCode(codes.Unauthenticated, err)
+	})
+
+	s.Run("CreateWallet", func() {
+		wallet, err = client.CreateWallet(ctx, &walletv1.CreateWalletRequest{Currency: currencyEUR, Name: standardName})
+		s.Require().NoError(err)
+		s.Require().Equal(user.ID.String(), wallet.GetOwnerId())
+		s.Require().Equal(string(model.WalletStatusActive), wallet.GetStatus())
+
+		_, err = client.CreateWallet(ctx, &walletv1.CreateWalletRequest{Currency: currencyEUR, Name: standardName})
+		requireCode(codes.AlreadyExists, err)
+	})
+
+	s.Run("GetWallet", func() {
+		got, err := client.GetWallet(ctx, &walletv1.GetWalletRequest{Id: wallet.GetId()})
+		s.Require().NoError(err)
+		s.Require().Equal(wallet.GetName(), got.GetName())
+
+		_, err = client.GetWallet(ctx, &walletv1.GetWalletRequest{Id: uuid.NewString()})
+		requireCode(codes.NotFound, err)
+
+		_, err = client.GetWallet(ctx, &walletv1.GetWalletRequest{Id: badRequestString})
+		requireCode(codes.InvalidArgument, err)
+	})
+
+	s.Run("Deposit and Withdraw", func() {
+		deposit := &walletv1.ExternalTransactionRequest{
+			Id:       uuid.NewString(),
+			WalletId: wallet.GetId(),
+			Currency: currencyEUR,
+			Sum:      100,
+		}
+
+		resp, err := client.Deposit(ctx, deposit)
+		s.Require().NoError(err)
+		s.Require().Equal(deposit.GetId(), resp.GetTransactionId())
+
+		_, err = client.Deposit(ctx, deposit)
+		requireCode(codes.AlreadyExists, err)
+
+		_, err = client.Withdraw(ctx, &walletv1.ExternalTransactionRequest{
+			Id:       uuid.NewString(),
+			WalletId: wallet.GetId(),
+			Currency: currencyEUR,
+			Sum:      1000,
+		})
+		requireCode(codes.FailedPrecondition, err)
+
+		_, err = client.Withdraw(ctx, &walletv1.ExternalTransactionRequest{
+			Id:       uuid.NewString(),
+			WalletId: wallet.GetId(),
+			Currency: currencyEUR,
+			Sum:      -1,
+		})
+		requireCode(codes.InvalidArgument, err)
+
+		_, err = client.Withdraw(ctx, &walletv1.ExternalTransactionRequest{
+			Id:       uuid.NewString(),
+			WalletId: wallet.GetId(),
+			Currency: currencyEUR,
+			Sum:      40,
+		})
+		s.Require().NoError(err)
+
+		got, err := client.GetWallet(ctx, &walletv1.GetWalletRequest{Id: wallet.GetId()})
+		s.Require().NoError(err)
+		s.Require().Equal(float64(60), got.GetBalance())
+	})
+
+	s.Run("Transfer", func() {
+		second, err := client.CreateWallet(ctx, &walletv1.CreateWalletRequest{Currency: currencyEUR, Name: secondaryName})
+		s.Require().NoError(err)
+
+		_, err = client.Transfer(ctx, &walletv1.TransferRequest{
+			Id:             uuid.NewString(),
+			AgentWalletId:  wallet.GetId(),
+			TargetWalletId: second.GetId(),
+			Currency:       currencyEUR,
+			Sum:            10,
+		})
+		s.Require().NoError(err)
+
+		got, err := client.GetWallet(ctx, &walletv1.GetWalletRequest{Id: second.GetId()})
+		s.Require().NoError(err)
+		s.Require().Equal(float64(10), got.GetBalance())
+	})
+
+	s.Run("ListWallets and ListTransactions", func() {
+		wallets, err := client.ListWallets(ctx, &walletv1.ListWalletsRequest{})
+		s.Require().NoError(err)
+		s.Require().Equal(2, len(wallets.GetWallets()))
+
+		transactions, err := client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{})
+		s.Require().NoError(err)
+		s.Require().Equal(3, len(transactions.GetTransactions()))
+	})
+
+	s.Run("UpdateWallet and DeleteWallet", func() {
+		newName := thirdName
+
+		updated, err := client.UpdateWallet(ctx, &walletv1.UpdateWalletRequest{Id: wallet.GetId(), Name: &newName})
+		s.Require().NoError(err)
+		s.Require().Equal(newName, updated.GetName())
+
+		_, err = client.DeleteWallet(ctx, &walletv1.DeleteWalletRequest{Id: wallet.GetId()})
+		requireCode(codes.FailedPrecondition, err)
+	})
+}
+
+func (s *IntegrationTestSuite) TestValidation() {
+	user, err := s.str.CreateUser(context.Background(), model.User{Email: "strict@test.com"})
+	s.Require().NoError(err)
+
+	userToken, err := s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
+
+	temp := s.authToken
+	s.authToken = userToken
+
+	defer func() { s.authToken = temp }()
+
+	wallet := model.Wallet{
+		OwnerID:  user.ID,
+		Currency: currencyEUR,
+		Name:     standardName,
+	}
+
+	s.checkWalletPost(&wallet)
+
+	requireViolations := func(resp *http.Response, problem apiserver.Problem, violations map[string]string) {
+		s.T().Helper()
+
+		s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		s.Require().Equal("validation_failed", problem.Code)
+
+		got := make(map[string]string, len(problem.Errors))
+		for _, fieldErr := range problem.Errors {
+			got[fieldErr.Field] = fieldErr.Code
+		}
+
+		s.Require().Equal(violations, got)
+	}
+
+	s.Run("POST:/wallets", func() {
+		s.Run("422/client id", func() {
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				walletEndpoint,
+				model.Wallet{ID: uuid.New(), Currency: currencyEUR, Name: secondaryName},
+				&problem)
+
+			requireViolations(resp, problem, map[string]string{"id": "unknown_field"})
+		})
+
+		s.Run("422/all violations", func() {
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				walletEndpoint,
+				model.NewWallet{Currency: "eur", Name: "  "},
+				&problem)
+
+			requireViolations(resp, problem, map[string]string{"currency": "wrong_currency", "name": "empty_name"})
+		})
+
+		s.Run("422/long name", func() {
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				walletEndpoint,
+				model.NewWallet{Currency: currencyEUR, Name: strings.Repeat("w", model.MaxWalletNameLength+1)},
+				&problem)
+
+			requireViolations(resp, problem, map[string]string{"name": "name_too_long"})
+		})
+
+		s.Run("413", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				walletEndpoint,
+				model.NewWallet{Currency: currencyEUR, Name: strings.Repeat("w", 2<<20)},
+				nil)
+
+			s.Require().Equal(http.StatusRequestEntityTooLarge, resp.StatusCode)
+		})
+	})
+
+	s.Run("PUT:/wallets/transfer/422", func() {
+		var problem apiserver.Problem
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			transferEndpoint,
+			model.Transaction{AgentWalletID: &wallet.ID, TargetWalletID: &wallet.ID, Currency: "X", Sum: -1},
+			&problem)
+
+		requireViolations(resp, problem, map[string]string{
+			"id":       "nil_uuid",
+			"currency": "wrong_currency",
+			"sum":      "negative_sum",
+		})
+	})
+
+	s.Run("PUT:/wallets/deposit/422/precision", func() {
+		var problem apiserver.Problem
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodPut,
+			depositEndpoint,
+			model.Transaction{ID: uuid.New(), TargetWalletID: &wallet.ID, Currency: currencyEUR, Sum: 10.005},
+			&problem)
+
+		requireViolations(resp, problem, map[string]string{"sum": "amount_precision"})
+	})
+}
+
+func (s *IntegrationTestSuite) TestCurrencies() {
+	user, err := s.str.CreateUser(context.Background(), model.User{Email: "pound@test.com"})
+	s.Require().NoError(err)
+
+	userToken, err := s.tokenGenerator.GetNewTokenString(*user)
+	s.Require().NoError(err)
+
+	temp := s.authToken
+	s.authToken = userToken
+
+	defer func() { s.authToken = temp }()
+
+	asAdmin := func() func() {
+		temp := s.authToken
+		s.authToken = s.adminAuthToken
+
+		return func() { s.authToken = temp }
+	}
+
+	pound := model.Currency{Code: "GBP", NumericCode: 826, MinorUnits: 2, Symbol: "£", IsEnabled: true}
+
+	s.Run("GET:/currencies", func() {
+		var currencies []*model.Currency
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodGet,
+			currenciesEndpoint,
+			nil,
+			&apiserver.HTTPResponse{Data: &currencies})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Contains(currencies, &model.Currency{
+			Code: currencyEUR, NumericCode: 978, MinorUnits: 2, Symbol: "€", IsEnabled: true,
+		})
+	})
+
+	s.Run("GET:/currencies/{code}/404", func() {
+		var problem apiserver.Problem
+
+		resp := s.sendRequest(
+			context.Background(),
+			http.MethodGet,
+			currenciesEndpoint+"/XXX",
+			nil,
+			&problem)
+
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+		s.Require().Equal("currency_not_found", problem.Code)
+	})
+
+	s.Run("PUT:/admin/currencies/{code}", func() {
+		s.Run("403/not admin", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+currenciesEndpoint+"/"+pound.Code,
+				pound,
+				nil)
+
+			s.Require().Equal(http.StatusForbidden, resp.StatusCode)
+		})
+
+		s.Run("422/invalid", func() {
+			defer asAdmin()()
+
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+currenciesEndpoint+"/gbp",
+				model.Currency{MinorUnits: 5},
+				&problem)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			s.Require().Len(problem.Errors, 3)
+		})
+
+		s.Run("200", func() {
+			defer asAdmin()()
+
+			var currency model.Currency
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+currenciesEndpoint+"/"+pound.Code,
+				pound,
+				&apiserver.HTTPResponse{Data: &currency})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(pound, currency)
+		})
+	})
+
+	wallet := model.Wallet{
+		OwnerID:  user.ID,
+		Currency: pound.Code,
+		Name:     standardName,
+	}
+
+	s.checkWalletPost(&wallet)
+
+	s.Run("disabled currency", func() {
+		disabled := pound
+		disabled.IsEnabled = false
+
+		func() {
+			defer asAdmin()()
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				adminEndpoint+currenciesEndpoint+"/"+pound.Code,
+				disabled,
+				nil)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+		}()
+
+		s.Run("POST:/wallets/422", func() {
+			var problem apiserver.Problem
+
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPost,
+				walletEndpoint,
+				model.NewWallet{Currency: pound.Code, Name: secondaryName},
+				&problem)
+
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			s.Require().Len(problem.Errors, 1)
+			s.Require().Equal("currency_disabled", problem.Errors[0].Code)
+		})
+
+		s.Run("PUT:/wallets/deposit/200", func() {
+			resp := s.sendRequest(
+				context.Background(),
+				http.MethodPut,
+				depositEndpoint,
+				model.Transaction{ID: uuid.New(), TargetWalletID: &wallet.ID, Currency: pound.Code, Sum: 10},
+				nil)
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(float64(10), s.getWalletByID(wallet.ID).Balance)
+		})
+	})
+}
+
+// fakeConverter quotes every pair with the number of lookups made so far,
+// so a fresh quote is told apart from a cached one.
+type fakeConverter struct {
+	mu         sync.Mutex
+	calls      int
+	batchSizes []int
+	err        error
+
+	// lookups wait for release when it is set
+	release chan struct{}
+}
+
+func (f *fakeConverter) lookup(pairs int) (float64, error) {
+	f.mu.Lock()
+	f.calls++
+	calls, release, err := f.calls, f.release, f.err
+
+	if pairs > 0 {
+		f.batchSizes = append(f.batchSizes, pairs)
+	}
+	f.mu.Unlock()
+
+	if release != nil {
+		<-release
+	}
+
+	return float64(calls), err
+}
+
+func (f *fakeConverter) GetExchangeRate(_ context.Context, _, _ string) (model.XRResponse, error) {
+	xr, err := f.lookup(0)
+	if err != nil {
+		return model.XRResponse{}, err
+	}
+
+	return model.XRResponse{XR: xr, Bid: xr, Ask: xr}, nil
+}
+
+func (f *fakeConverter) GetExchangeRates(_ context.Context, pairs []model.XRPair) ([]model.XRResponse, error) {
+	xr, err := f.lookup(len(pairs))
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make([]model.XRResponse, len(pairs))
+	for i := range rates {
+		rates[i] = model.XRResponse{XR: xr, Bid: xr, Ask: xr}
+	}
+
+	return rates, nil
+}
+
+func (f *fakeConverter) Ping(context.Context) error {
+	return nil
+}
+
+func (f *fakeConverter) set(err error, release chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.err, f.release = err, release
+}
+
+func (f *fakeConverter) stats() (int, []int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls, append([]int(nil), f.batchSizes...)
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+type cacheResults struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (r *cacheResults) TrackXRCache(result string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, result)
+}
+
+func (r *cacheResults) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.results[len(r.results)-1]
+}
+
+func (s *IntegrationTestSuite) TestXRCache() {
+	const (
+		ttl          = time.Minute
+		maxStaleness = time.Minute
+	)
+
+	setup := func() (*currconv.Cache, *fakeConverter, *fakeClock, *cacheResults) {
+		converter := &fakeConverter{}
+		clock := &fakeClock{now: time.Now()}
+		results := &cacheResults{}
+
+		cache := currconv.NewCache(
+			converter,
+			currconv.CacheConfig{TTL: ttl, MaxStaleness: maxStaleness, Now: clock.Now},
+			results)
+
+		return cache, converter, clock, results
+	}
+
+	getRate := func(cache *currconv.Cache) (float64, error) {
+		xr, err := cache.GetExchangeRate(context.Background(), currencyEUR, currencyUSD)
+
+		return xr.XR, err
+	}
+
+	s.Run("fresh for ttl", func() {
+		cache, converter, clock, results := setup()
+
+		xr, err := getRate(cache)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), xr)
+		s.Require().Equal(currconv.CacheMiss, results.last())
+
+		clock.advance(ttl - time.Second)
+
+		xr, err = getRate(cache)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), xr)
+		s.Require().Equal(currconv.CacheHit, results.last())
+
+		calls, _ := converter.stats()
+		s.Require().Equal(1, calls)
+	})
+
+	s.Run("stale while revalidating", func() {
+		cache, _, clock, results := setup()
+
+		_, err := getRate(cache)
+		s.Require().NoError(err)
+
+		clock.advance(ttl + time.Second)
+
+		xr, err := getRate(cache)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), xr)
+		s.Require().Equal(currconv.CacheStale, results.last())
+
+		s.Require().Eventually(func() bool {
+			xr, err := getRate(cache)
+
+			return err == nil && xr == 2 && results.last() == currconv.CacheHit
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	s.Run("stale despite failing refresh", func() {
+		cache, converter, clock, _ := setup()
+
+		_, err := getRate(cache)
+		s.Require().NoError(err)
+
+		converter.set(model.ErrGettingXR, nil)
+		clock.advance(ttl + maxStaleness - time.Second)
+
+		xr, err := getRate(cache)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), xr)
+	})
+
+	s.Run("max staleness", func() {
+		cache, converter, clock, results := setup()
+
+		_, err := getRate(cache)
+		s.Require().NoError(err)
+
+		clock.advance(ttl + maxStaleness + time.Second)
+
+		xr, err := getRate(cache)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(2), xr)
+		s.Require().Equal(currconv.CacheMiss, results.last())
+
+		converter.set(model.ErrGettingXR, nil)
+		clock.advance(ttl + maxStaleness + time.Second)
+
+		_, err = getRate(cache)
+		s.Require().ErrorIs(err, model.ErrGettingXR)
+	})
+
+	s.Run("concurrent misses share a lookup", func() {
+		cache, converter, _, _ := setup()
+
+		release := make(chan struct{})
+		converter.set(nil, release)
+
+		const lookups = 10
+
+		rates := make(chan float64, lookups)
+
+		for i := 0; i < lookups; i++ {
+			go func() {
+				xr, err := getRate(cache)
+				s.Require().NoError(err)
+
+				rates <- xr
+			}()
+		}
+
+		s.Require().Eventually(func() bool {
+			calls, _ := converter.stats()
+
+			return calls == 1
+		}, time.Second, 10*time.Millisecond)
+
+		// let the other lookups join the one in flight
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+
+		for i := 0; i < lookups; i++ {
+			s.Require().Equal(float64(1), <-rates)
+		}
+
+		calls, _ := converter.stats()
+		s.Require().Equal(1, calls)
+	})
+
+	s.Run("batches", func() {
+		cache, converter, _, results := setup()
+
+		pairs := []model.XRPair{
+			{BaseCurrency: currencyEUR, TargetCurrency: currencyUSD},
+			{BaseCurrency: currencyUSD, TargetCurrency: currencyEUR},
+		}
+
+		rates, err := cache.GetExchangeRates(context.Background(), pairs)
+		s.Require().NoError(err)
+		s.Require().Equal(2, len(rates))
+		s.Require().Equal(float64(1), rates[1].XR)
+
+		// rates fetched in a batch serve single lookups
+		xr, err := getRate(cache)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), xr)
+		s.Require().Equal(currconv.CacheHit, results.last())
+
+		// only the missing pair is fetched
+		rates, err = cache.GetExchangeRates(context.Background(), []model.XRPair{
+			pairs[0],
+			{BaseCurrency: currencyEUR, TargetCurrency: "RUB"},
+		})
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), rates[0].XR)
+		s.Require().Equal(float64(2), rates[1].XR)
+
+		_, batchSizes := converter.stats()
+		s.Require().Equal([]int{2, 1}, batchSizes)
+	})
+
+	s.Run("batch and single lookup of a pair at once", func() {
+		cache, converter, _, _ := setup()
+
+		release := make(chan struct{})
+		converter.set(nil, release)
+
+		single := make(chan float64, 1)
+
+		go func() {
+			xr, err := getRate(cache)
+			s.Require().NoError(err)
+
+			single <- xr
+		}()
+
+		batch := make(chan []model.XRResponse, 1)
+
+		go func() {
+			rates, err := cache.GetExchangeRates(
+				context.Background(),
+				[]model.XRPair{{BaseCurrency: currencyEUR, TargetCurrency: currencyUSD}})
+			s.Require().NoError(err)
+
+			batch <- rates
+		}()
+
+		// results of single and batch lookups differ in type, they must not be shared
+		s.Require().Eventually(func() bool {
+			calls, _ := converter.stats()
+
+			return calls == 2
+		}, time.Second, 10*time.Millisecond)
+
+		close(release)
+
+		s.Require().NotZero(<-single)
+
+		rates := <-batch
+		s.Require().Equal(1, len(rates))
+		s.Require().NotZero(rates[0].XR)
+	})
+}
+
+type requestCounter struct{}
+
+func (requestCounter) TrackExternalRequest(time.Time, string) {}
+
+// rateServer answers the n-th request, counting from 1, with the status
+// status returns, quoting a rate of 2 on 200.
+func (s *IntegrationTestSuite) rateServer(status func(n int64) int) (*httptest.Server, *atomic.Int64) {
+	hits := new(atomic.Int64)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		code := status(hits.Add(1))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+
+		if code == http.StatusOK {
+			err := json.NewEncoder(w).Encode(apiserver.HTTPResponse{Data: model.XRResponse{XR: 2}})
+			s.Require().NoError(err)
+		}
+	}))
+
+	s.T().Cleanup(server.Close)
+
+	return server, hits
+}
+
+func (s *IntegrationTestSuite) TestXRClient() {
+	const baseDelay = 20 * time.Millisecond
+
+	newClient := func(url string, attempts, threshold int, cooldown time.Duration) *currconv.RemoteCurrencyConverter {
+		client, err := currconv.New(currconv.Config{
+			URL:              url,
+			Timeout:          time.Second,
+			RetryAttempts:    attempts,
+			RetryBaseDelay:   baseDelay,
+			RetryMaxDelay:    4 * baseDelay,
+			BreakerThreshold: threshold,
+			BreakerCooldown:  cooldown,
+		}, requestCounter{})
+		s.Require().NoError(err)
+
+		return client
+	}
+
+	getRate := func(ctx context.Context, client *currconv.RemoteCurrencyConverter) error {
+		_, err := client.GetExchangeRate(ctx, currencyEUR, currencyUSD)
+
+		return err
+	}
+
+	s.Run("retries until the server recovers", func() {
+		server, hits := s.rateServer(func(n int64) int {
+			if n <= 2 {
+				return http.StatusServiceUnavailable
+			}
+
+			return http.StatusOK
+		})
+
+		start := time.Now()
+
+		xr, err := newClient(server.URL, 3, 0, 0).GetExchangeRate(context.Background(), currencyEUR, currencyUSD)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(2), xr.XR)
+		s.Require().Equal(int64(3), hits.Load())
+
+		// backoff of base, then 2*base, jittered down to half at most
+		s.Require().GreaterOrEqual(time.Since(start), (baseDelay+2*baseDelay)/2)
+	})
+
+	s.Run("gives up after the last attempt", func() {
+		server, hits := s.rateServer(func(int64) int { return http.StatusTooManyRequests })
+
+		err := getRate(context.Background(), newClient(server.URL, 3, 0, 0))
+		s.Require().ErrorIs(err, model.ErrGettingXR)
+		s.Require().Equal(int64(3), hits.Load())
+	})
+
+	s.Run("no retries of client errors", func() {
+		server, hits := s.rateServer(func(int64) int { return http.StatusNotFound })
+
+		err := getRate(context.Background(), newClient(server.URL, 3, 0, 0))
+		s.Require().ErrorIs(err, model.ErrGettingXR)
+		s.Require().Equal(int64(1), hits.Load())
+
+		server, hits = s.rateServer(func(int64) int { return http.StatusBadRequest })
+
+		err = getRate(context.Background(), newClient(server.URL, 3, 0, 0))
+		s.Require().ErrorIs(err, model.ErrWrongCurrency)
+		s.Require().Equal(int64(1), hits.Load())
+	})
+
+	s.Run("breaker", func() {
+		const cooldown = 200 * time.Millisecond
+
+		healthy := new(atomic.Bool)
+
+		server, hits := s.rateServer(func(int64) int {
+			if healthy.Load() {
+				return http.StatusOK
+			}
+
+			// not retryable, but a broken server all the same
+			return http.StatusNotFound
+		})
+
+		client := newClient(server.URL, 1, 2, cooldown)
+
+		s.Run("opens", func() {
+			for i := 0; i < 2; i++ {
+				s.Require().ErrorIs(getRate(context.Background(), client), model.ErrGettingXR)
+			}
+
+			s.Require().ErrorIs(getRate(context.Background(), client), model.ErrGettingXR)
+			s.Require().Equal(int64(2), hits.Load())
+		})
+
+		s.Run("failed trial opens it again", func() {
+			time.Sleep(cooldown)
+
+			s.Require().ErrorIs(getRate(context.Background(), client), model.ErrGettingXR)
+			s.Require().ErrorIs(getRate(context.Background(), client), model.ErrGettingXR)
+			s.Require().Equal(int64(3), hits.Load())
+		})
+
+		s.Run("successful trial closes it", func() {
+			time.Sleep(cooldown)
+			healthy.Store(true)
+
+			s.Require().NoError(getRate(context.Background(), client))
+			s.Require().NoError(getRate(context.Background(), client))
+			s.Require().Equal(int64(5), hits.Load())
+		})
+	})
+
+	s.Run("unknown currencies keep the breaker closed", func() {
+		server, hits := s.rateServer(func(int64) int { return http.StatusBadRequest })
+
+		client := newClient(server.URL, 1, 1, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			s.Require().ErrorIs(getRate(context.Background(), client), model.ErrWrongCurrency)
+		}
+
+		s.Require().Equal(int64(3), hits.Load())
+	})
+
+	s.Run("context cancellation", func() {
+		server, hits := s.rateServer(func(int64) int { return http.StatusServiceUnavailable })
+
+		client := newClient(server.URL, 100, 0, 0)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*baseDelay)
+		defer cancel()
+
+		start := time.Now()
+
+		err := getRate(ctx, client)
+		s.Require().ErrorIs(err, model.ErrGettingXR)
+		s.Require().ErrorIs(err, context.DeadlineExceeded)
+		s.Require().Less(time.Since(start), time.Second)
+		s.Require().Less(hits.Load(), int64(100))
+	})
+
+	s.Run("cancelled trial lets the next one through", func() {
+		const cooldown = 100 * time.Millisecond
+
+		slow := new(atomic.Bool)
+
+		server, _ := s.rateServer(func(n int64) int {
+			switch {
+			case n == 1:
+				return http.StatusNotFound
+			case slow.Load():
+				time.Sleep(200 * time.Millisecond)
+			}
+
+			return http.StatusOK
+		})
+
+		client := newClient(server.URL, 1, 1, cooldown)
+
+		s.Require().ErrorIs(getRate(context.Background(), client), model.ErrGettingXR)
+
+		time.Sleep(cooldown)
+		slow.Store(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		s.Require().ErrorIs(getRate(ctx, client), context.DeadlineExceeded)
+
+		slow.Store(false)
+
+		s.Require().NoError(getRate(context.Background(), client))
+	})
+}
+
+type fakeProvider struct {
+	name  string
+	xr    float64
+	err   error
+	calls atomic.Int64
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) GetExchangeRate(context.Context, string, string) (model.XRResponse, error) {
+	p.calls.Add(1)
+
+	if p.err != nil {
+		return model.XRResponse{}, p.err
+	}
+
+	return model.XRResponse{XR: p.xr, Bid: p.xr, Ask: p.xr}, nil
+}
+
+func (p *fakeProvider) GetExchangeRates(ctx context.Context, pairs []model.XRPair) ([]model.XRResponse, error) {
+	rates := make([]model.XRResponse, 0, len(pairs))
+
+	for _, pair := range pairs {
+		xr, err := p.GetExchangeRate(ctx, pair.BaseCurrency, pair.TargetCurrency)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, xr)
+	}
+
+	return rates, nil
+}
+
+func (p *fakeProvider) Ping(context.Context) error {
+	return p.err
+}
+
+type providerResults struct {
+	mu      sync.Mutex
+	results map[string][]string
+}
+
+func (r *providerResults) TrackXRProvider(provider, result string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results[provider] = append(r.results[provider], result)
+}
+
+func (s *IntegrationTestSuite) TestXRFailover() {
+	newFailover := func(maxDeviation float64, providers ...*fakeProvider) (*currconv.Failover, *providerResults) {
+		results := &providerResults{results: make(map[string][]string)}
+
+		list := make([]currconv.Provider, 0, len(providers))
+		for _, provider := range providers {
+			list = append(list, provider)
+		}
+
+		return currconv.NewFailover(list, maxDeviation, results), results
+	}
+
+	getRate := func(failover *currconv.Failover) (float64, error) {
+		xr, err := failover.GetExchangeRate(context.Background(), currencyEUR, currencyUSD)
+
+		return xr.XR, err
+	}
+
+	s.Run("first provider that answers", func() {
+		primary := &fakeProvider{name: "primary", xr: 1}
+		secondary := &fakeProvider{name: "secondary", xr: 2}
+
+		failover, _ := newFailover(0, primary, secondary)
+
+		xr, err := getRate(failover)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(1), xr)
+		s.Require().Zero(secondary.calls.Load())
+
+		primary.err = model.ErrGettingXR
+
+		xr, err = getRate(failover)
+		s.Require().NoError(err)
+		s.Require().Equal(float64(2), xr)
+		s.Require().Equal(int64(1), secondary.calls.Load())
+	})
+
+	s.Run("all providers down", func() {
+		failover, results := newFailover(
+			0,
+			&fakeProvider{name: "primary", err: model.ErrGettingXR},
+			&fakeProvider{name: "secondary", err: model.ErrGettingXR})
+
+		_, err := getRate(failover)
+		s.Require().ErrorIs(err, model.ErrGettingXR)
+		s.Require().Equal([]string{currconv.ProviderError}, results.results["primary"])
+		s.Require().Equal([]string{currconv.ProviderError}, results.results["secondary"])
+	})
+
+	s.Run("wrong currency", func() {
+		// an unknown currency is an answer, the next provider is not asked
+		next := &fakeProvider{name: "secondary", xr: 2}
+		failover, results := newFailover(0, &fakeProvider{name: "primary", err: model.ErrWrongCurrency}, next)
+
+		_, err := getRate(failover)
+		s.Require().ErrorIs(err, model.ErrWrongCurrency)
+		s.Require().Zero(next.calls.Load())
+		s.Require().Equal([]string{currconv.ProviderOK}, results.results["primary"])
+
+		failover, _ = newFailover(
+			0,
+			&fakeProvider{name: "primary", err: model.ErrGettingXR},
+			&fakeProvider{name: "secondary", err: model.ErrWrongCurrency})
+
+		_, err = getRate(failover)
+		s.Require().ErrorIs(err, model.ErrWrongCurrency)
+
+		failover, _ = newFailover(
+			0.05,
+			&fakeProvider{name: "primary", err: model.ErrWrongCurrency},
+			&fakeProvider{name: "secondary", xr: 2},
+			&fakeProvider{name: "tertiary", xr: 2})
+
+		_, err = getRate(failover)
+		s.Require().ErrorIs(err, model.ErrWrongCurrency)
+	})
+
+	s.Run("cross-check", func() {
+		s.Run("within deviation", func() {
+			failover, _ := newFailover(
+				0.05,
+				&fakeProvider{name: "primary", xr: 1},
+				&fakeProvider{name: "secondary", xr: 1.04})
+
+			xr, err := getRate(failover)
+			s.Require().NoError(err)
+			s.Require().Equal(float64(1), xr)
+		})
+
+		s.Run("rejected beyond deviation", func() {
+			failover, results := newFailover(
+				0.05,
+				&fakeProvider{name: "primary", xr: 1},
+				&fakeProvider{name: "secondary", xr: 1.1})
+
+			_, err := getRate(failover)
+			s.Require().ErrorIs(err, model.ErrGettingXR)
+			s.Require().Contains(results.results["primary"], currconv.ProviderDeviation)
+
+			_, err = failover.GetExchangeRates(context.Background(), []model.XRPair{
+				{BaseCurrency: currencyEUR, TargetCurrency: currencyUSD},
+			})
+			s.Require().ErrorIs(err, model.ErrGettingXR)
+		})
+
+		s.Run("checked against the next that answers", func() {
+			failover, _ := newFailover(
+				0.05,
+				&fakeProvider{name: "primary", err: model.ErrGettingXR},
+				&fakeProvider{name: "secondary", xr: 1},
+				&fakeProvider{name: "tertiary", xr: 2})
+
+			_, err := getRate(failover)
+			s.Require().ErrorIs(err, model.ErrGettingXR)
+		})
+
+		s.Run("single provider answers", func() {
+			failover, _ := newFailover(
+				0.05,
+				&fakeProvider{name: "primary", xr: 1},
+				&fakeProvider{name: "secondary", err: model.ErrGettingXR})
+
+			xr, err := getRate(failover)
+			s.Require().NoError(err)
+			s.Require().Equal(float64(1), xr)
+		})
+	})
+
+	s.Run("ping", func() {
+		failover, _ := newFailover(
+			0,
+			&fakeProvider{name: "primary", err: model.ErrGettingXR},
+			&fakeProvider{name: "secondary"})
+		s.Require().NoError(failover.Ping(context.Background()))
+
+		failover, _ = newFailover(0, &fakeProvider{name: "primary", err: model.ErrGettingXR})
+		s.Require().ErrorIs(failover.Ping(context.Background()), model.ErrGettingXR)
+	})
+
+	s.Run("ping keeps the path prefix", func() {
+		paths := make(chan string, 1)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths <- r.URL.Path
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":{"status":"ok","currencies":1}}`))
+		}))
+		defer server.Close()
+
+		client, err := currconv.New(currconv.Config{URL: server.URL + "/fx/xr", Timeout: time.Second}, requestCounter{})
+		s.Require().NoError(err)
+
+		s.Require().NoError(client.Ping(context.Background()))
+		s.Require().Equal("/fx/readyz", <-paths)
+	})
+}
+
+func (s *IntegrationTestSuite) TestXRPostgresStorage() {
+	ctx := context.Background()
+
+	// the rate storage shares the database with the wallet here
+	rateStorage, err := xrstorage.New(ctx, "postgres", "", testDSN())
+	s.Require().NoError(err)
+
+	defer rateStorage.Close()
+
+	s.Run("migrations are idempotent", func() {
+		again, err := xrstorage.New(ctx, "postgres", "", testDSN())
+		s.Require().NoError(err)
+
+		again.Close()
+	})
+
+	s.Run("wallet migrations stay apart", func() {
+		s.Require().NoError(s.str.CheckMigrations(ctx))
+	})
+
+	s.Run("changes", func() {
+		conn := s.connectDB(ctx)
+
+		_, err := conn.Exec(ctx, "DELETE FROM xr_rates WHERE code IN ('XAA', 'XAB')")
+		s.Require().NoError(err)
+
+		at := time.Now().UTC().Truncate(time.Second)
+
+		s.Require().NoError(rateStorage.Save(ctx, []model.XRRate{
+			{Code: "XAA", Rate: 1, EffectiveAt: at},
+			{Code: "XAB", Rate: 2, EffectiveAt: at},
+		}))
+		s.Require().NoError(rateStorage.Save(ctx, []model.XRRate{
+			{Code: "XAA", Rate: 3, EffectiveAt: at.Add(time.Hour)},
+		}))
+
+		changes, err := rateStorage.Changes(ctx, []string{"XAA"}, at.Add(time.Minute))
+		s.Require().NoError(err)
+		s.Require().Len(changes, 1)
+		s.Require().Equal(float64(1), changes[0].Rate)
+		s.Require().True(at.Equal(changes[0].EffectiveAt))
+
+		changes, err = rateStorage.Changes(ctx, []string{"XAA", "XAB"}, at.Add(time.Hour))
+		s.Require().NoError(err)
+		s.Require().Len(changes, 3)
+		s.Require().Equal(float64(3), changes[2].Rate)
+	})
+}
+
+// noopMetrics stands in for the prometheus metrics of extra servers, those
+// can be registered once only.
+type noopMetrics struct{}
+
+func (noopMetrics) TrackHTTPRequestStart()                              {}
+func (noopMetrics) TrackHTTPRequest(time.Time, *http.Request, int, int) {}
+func (noopMetrics) SetXRRates([]model.XRUpdate)                         {}
+func (noopMetrics) TrackXRFeed(string, string)                          {}
+func (noopMetrics) SetXRRatesStale(bool)                                {}
+func (noopMetrics) TrackOperation(string, string, float64, error)       {}
+func (noopMetrics) TrackConversion(string, string)                      {}
+func (noopMetrics) TrackWalletCreated(string)                           {}
+func (noopMetrics) TrackWalletsArchived(int)                            {}
+
+func (s *IntegrationTestSuite) TestXRServerFeedAnchor() {
+	rateStorage, err := xrstorage.NewFileStorage(filepath.Join(s.T().TempDir(), "rates.jsonl"))
+	s.Require().NoError(err)
+
+	feedPath := filepath.Join(s.T().TempDir(), "feed.csv")
+	s.Require().NoError(os.WriteFile(feedPath, []byte("code,rate\nEUR,1\nUSD,1.08\n"), 0o600))
+
+	xr := xrserver.New(
+		xrserver.Config{
+			BindAddr:     xrTestBindAddr,
+			FeedInterval: time.Hour,
+			FeedMaxAge:   time.Hour,
+			FeedAnchor:   "XXX",
+		},
+		rateStorage,
+		[]xrfeed.Provider{xrfeed.NewFileProvider(feedPath)},
+		noopMetrics{})
+
+	// an anchor the server has no rate for fails the start, not every refresh
+	err = xr.Run(context.Background())
+	s.Require().ErrorContains(err, `"XXX"`)
+}
+
+func (s *IntegrationTestSuite) TestTracing() {
+	const (
+		tracedAddr = ":8082"
+		traceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+		callerSpan = "00f067aa0ba902b7"
+	)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		s.Require().NoError(provider.Shutdown(context.Background()))
+	}()
+
+	// a rate server of its own, to see the trace context it is sent
+	traceparents := make(chan string, 1)
+
+	xr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case traceparents <- r.Header.Get("traceparent"):
+		default:
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(apiserver.HTTPResponse{Data: model.XRResponse{XR: 2}})
+		s.Require().NoError(err)
+	}))
+	defer xr.Close()
+
+	converter, err := currconv.New(currconv.Config{URL: xr.URL + "/xr", Timeout: time.Second}, requestCounter{})
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := apiserver.New(
+		apiserver.Config{BindAddress: tracedAddr},
+		service.New(s.str, converter, noopMetrics{}),
+		s.tokenGenerator.GetPublicKey(),
+		noopMetrics{})
+
+	go func() {
+		err := server.Run(ctx)
+		s.Require().NoError(err)
+	}()
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get("http://localhost" + tracedAddr + "/healthz")
+		if err != nil {
+			return false
+		}
+
+		_ = resp.Body.Close()
+
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	wallet := &model.Wallet{OwnerID: s.testOwnerID, Currency: currencyUSD, Name: "traced"}
+	s.checkWalletPost(wallet)
+
+	body, err := json.Marshal(model.Transaction{
+		ID:             uuid.New(),
+		TargetWalletID: &wallet.ID,
+		Currency:       currencyEUR,
+		Sum:            100,
+	})
+	s.Require().NoError(err)
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPut,
+		"http://localhost"+tracedAddr+"/api/v1"+depositEndpoint,
+		bytes.NewReader(body))
+	s.Require().NoError(err)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.authToken)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+callerSpan+"-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.Require().NoError(resp.Body.Close())
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var (
+		httpSpan sdktrace.ReadOnlySpan
+		spans    map[string]sdktrace.ReadOnlySpan
+	)
+
+	s.Require().Eventually(func() bool {
+		spans = make(map[string]sdktrace.ReadOnlySpan)
+
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID().String() != traceID {
+				continue
+			}
+
+			spans[span.SpanContext().SpanID().String()] = span
+
+			if span.SpanKind() == trace.SpanKindServer {
+				httpSpan = span
+			}
+		}
+
+		return httpSpan != nil
+	}, time.Second, 10*time.Millisecond)
+
+	s.Run("http span", func() {
+		s.Require().Equal("PUT /api/v1/wallets/deposit", httpSpan.Name())
+		s.Require().Equal(callerSpan, httpSpan.Parent().SpanID().String())
+		s.Require().True(httpSpan.Parent().IsRemote())
+		s.Require().Contains(httpSpan.Attributes(), semconv.HTTPStatusCode(http.StatusOK))
+		s.Require().Contains(httpSpan.Attributes(), semconv.HTTPRoute("/api/v1/wallets/deposit"))
+	})
+
+	// underHTTPSpan tells whether the span descends from the http span
+	underHTTPSpan := func(span sdktrace.ReadOnlySpan) bool {
+		for span != nil {
+			if span.Parent().SpanID() == httpSpan.SpanContext().SpanID() {
+				return true
+			}
+
+			span = spans[span.Parent().SpanID().String()]
+		}
+
+		return false
+	}
+
+	s.Run("pgx spans", func() {
+		queries := 0
+
+		for _, span := range spans {
+			if !strings.HasPrefix(span.Name(), "postgres ") {
+				continue
+			}
+
+			queries++
+
+			s.Require().Equal(trace.SpanKindClient, span.SpanKind())
+			s.Require().Contains(span.Attributes(), semconv.DBSystemPostgreSQL)
+			s.Require().True(underHTTPSpan(span), span.Name())
+		}
+
+		s.Require().NotZero(queries)
+	})
+
+	s.Run("traceparent sent to the rate server", func() {
+		var traceparent string
+
+		select {
+		case traceparent = <-traceparents:
+		default:
+			s.Require().Fail("the rate server was not asked")
+		}
+
+		parts := strings.Split(traceparent, "-")
+		s.Require().Len(parts, 4, traceparent)
+		s.Require().Equal(traceID, parts[1])
+
+		caller, ok := spans[parts[2]]
+		s.Require().True(ok, "the rate server is called from an unrecorded span")
+		s.Require().Equal("currconv.GetExchangeRate", caller.Name())
+		s.Require().True(underHTTPSpan(caller))
+	})
+}
+
+func (s *IntegrationTestSuite) TestXRServer() {
+	// a server of its own, the shared one serves the rates other tests rely on
+	ratesPath := filepath.Join(s.T().TempDir(), "rates.jsonl")
+
+	rateStorage, err := xrstorage.NewFileStorage(ratesPath)
+	s.Require().NoError(err)
+
+	feedPath := filepath.Join(s.T().TempDir(), "feed.csv")
+	s.Require().NoError(os.WriteFile(feedPath, []byte("code,rate\nRUB,1\nFED,3\n"), 0o600))
+
+	xr := xrserver.New(
+		xrserver.Config{
+			BindAddr:     xrTestBindAddr,
+			AdminToken:   xrTestAdminToken,
+			Spreads:      map[string]float64{"TST": 0.02},
+			FeedInterval: time.Hour,
+			FeedMaxAge:   time.Hour,
+			FeedAnchor:   "RUB",
+		},
+		rateStorage,
+		[]xrfeed.Provider{xrfeed.NewFileProvider(feedPath)},
+		prometrics.NewXRServer())
+
+	go func() {
+		err := xr.Run(*s.ctx)
+		s.Require().NoError(err)
+	}()
+
+	s.Require().Eventually(func() bool {
+		resp, err := http.Get("http://localhost" + xrTestBindAddr + "/readyz")
+		if err != nil {
+			return false
+		}
+
+		return resp.Body.Close() == nil && resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 50*time.Millisecond)
+
+	s.Run("feed", func() {
+		getFeedRate := func() float64 {
+			var xrResponse model.XRResponse
+
+			// the rate stays zero until the currency is known
+			s.sendXRRequest(http.MethodGet, "/xr?base=FED&target=RUB", "", nil, &apiserver.HTTPResponse{Data: &xrResponse})
+
+			return xrResponse.XR
+		}
+
+		s.Require().Eventually(func() bool { return getFeedRate() == 3 }, 5*time.Second, 50*time.Millisecond)
+
+		s.Run("GET:/xr/updates", func() {
+			var updates []model.XRUpdate
+
+			resp := s.sendXRRequest(http.MethodGet, "/xr/updates", "", nil, &apiserver.HTTPResponse{Data: &updates})
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			sources := make(map[string]string)
+			for _, update := range updates {
+				s.Require().NotZero(update.UpdatedAt)
+
+				sources[update.Code] = update.Source
+			}
+
+			s.Require().Equal("file:"+feedPath, sources["FED"])
+		})
+
+		s.Run("watched file", func() {
+			s.Require().NoError(os.WriteFile(feedPath, []byte("RUB,1\nFED,5\n"), 0o600))
+
+			s.Require().Eventually(func() bool { return getFeedRate() == 5 }, 5*time.Second, 50*time.Millisecond)
+		})
+
+		s.Run("invalid snapshot keeps the last one", func() {
+			s.Require().NoError(os.WriteFile(feedPath, []byte("RUB,1\nFED,-1\n"), 0o600))
+
+			time.Sleep(2 * time.Second)
+
+			s.Require().Equal(float64(5), getFeedRate())
+		})
+	})
+
+	s.Run("GET:/rates/401", func() {
+		resp := s.sendXRRequest(http.MethodGet, "/rates", "wrong token", nil, nil)
+		s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	s.Run("PUT:/rates/{code}", func() {
+		s.Run("422/invalid rate", func() {
+			resp := s.sendXRRequest(http.MethodPut, "/rates/TST", xrTestAdminToken, model.XRRate{Rate: -1}, nil)
+			s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+
+		s.Run("200", func() {
+			var rate model.XRRate
+
+			resp := s.sendXRRequest(
+				http.MethodPut,
+				"/rates/TST",
+				xrTestAdminToken,
+				model.XRRate{Rate: 2},
+				&apiserver.HTTPResponse{Data: &rate})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal("TST", rate.Code)
+			s.Require().Equal(float64(2), rate.Rate)
+			s.Require().NotZero(rate.EffectiveAt)
+		})
+
+		s.Run("GET:/xr", func() {
+			var xrResponse model.XRResponse
+
+			resp := s.sendXRRequest(http.MethodGet, "/xr?base=TST&target=RUB", "", nil, &apiserver.HTTPResponse{Data: &xrResponse})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(float64(2), xrResponse.XR)
+			s.Require().InDelta(1.98, xrResponse.Bid, 1e-9)
+			s.Require().InDelta(2.02, xrResponse.Ask, 1e-9)
+		})
+	})
+
+	s.Run("GET:/rates", func() {
+		var rates []model.XRRate
+
+		resp := s.sendXRRequest(http.MethodGet, "/rates", xrTestAdminToken, nil, &apiserver.HTTPResponse{Data: &rates})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		codes := make([]string, 0, len(rates))
+		for _, rate := range rates {
+			codes = append(codes, rate.Code)
+		}
+
+		s.Require().Contains(codes, "TST")
+	})
+
+	s.Run("GET:/xr/matrix", func() {
+		s.Run("200", func() {
+			var matrix model.XRMatrix
+
+			resp := s.sendXRRequest(http.MethodGet, "/xr/matrix?base=RUB", "", nil, &apiserver.HTTPResponse{Data: &matrix})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal("RUB", matrix.Base)
+			s.Require().Equal(float64(1), matrix.Rates["RUB"].XR)
+			s.Require().Equal(float64(2), matrix.Rates["TST"].XR)
+		})
+
+		s.Run("400", func() {
+			resp := s.sendXRRequest(http.MethodGet, "/xr/matrix?base=XXX", "", nil, nil)
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+
+	s.Run("POST:/xr/batch", func() {
+		s.Run("200", func() {
+			var batch model.XRBatchResponse
+
+			resp := s.sendXRRequest(
+				http.MethodPost,
+				"/xr/batch",
+				"",
+				model.XRBatchRequest{Pairs: []model.XRPair{
+					{BaseCurrency: "TST", TargetCurrency: "RUB"},
+					{BaseCurrency: "RUB", TargetCurrency: "TST"},
+				}},
+				&apiserver.HTTPResponse{Data: &batch})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Len(batch.Rates, 2)
+			s.Require().Equal(float64(2), batch.Rates[0].XR)
+			s.Require().Equal(0.5, batch.Rates[1].XR)
+		})
+
+		s.Run("400", func() {
+			resp := s.sendXRRequest(
+				http.MethodPost,
+				"/xr/batch",
+				"",
+				model.XRBatchRequest{Pairs: []model.XRPair{{BaseCurrency: "TST", TargetCurrency: "XXX"}}},
+				nil)
+
+			s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	})
+
+	s.Run("GET:/healthz", func() {
+		resp := s.sendXRRequest(http.MethodGet, "/healthz", "", nil, nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	s.Run("GET:/readyz", func() {
+		resp := s.sendXRRequest(http.MethodGet, "/readyz", "", nil, nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	s.Run("GET:/metrics", func() {
+		resp, err := http.Get("http://localhost" + xrTestBindAddr + "/metrics")
+		s.Require().NoError(err)
+
+		defer func() { s.Require().NoError(resp.Body.Close()) }()
+
+		body, err := io.ReadAll(resp.Body)
+		s.Require().NoError(err)
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Contains(string(body), `xr_http_requests_total{method="GET",route="/xr/matrix",status="200"}`)
+		s.Require().Contains(string(body), "xr_currencies")
+	})
+
+	s.Run("history", func() {
+		changedAt := time.Now()
+
+		resp := s.sendXRRequest(http.MethodPut, "/rates/TST", xrTestAdminToken, model.XRRate{Rate: 4}, nil)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+		s.Run("GET:/xr?at=", func() {
+			var xrResponse model.XRResponse
+
+			resp := s.sendXRRequest(
+				http.MethodGet,
+				"/xr?base=TST&target=RUB&at="+url.QueryEscape(changedAt.Format(time.RFC3339Nano)),
+				"",
+				nil,
+				&apiserver.HTTPResponse{Data: &xrResponse})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Equal(float64(2), xrResponse.XR)
+		})
+
+		s.Run("GET:/xr/history", func() {
+			var points []model.XRPoint
+
+			resp := s.sendXRRequest(
+				http.MethodGet,
+				"/xr/history?base=TST&target=RUB",
+				"",
+				nil,
+				&apiserver.HTTPResponse{Data: &points})
+
+			s.Require().Equal(http.StatusOK, resp.StatusCode)
+			s.Require().Len(points, 2)
+			s.Require().Equal(float64(2), points[0].XR)
+			s.Require().Equal(float64(4), points[1].XR)
+		})
+
+		s.Run("survives restart", func() {
+			restored, err := xrstorage.NewFileStorage(ratesPath)
+			s.Require().NoError(err)
+
+			changes, err := restored.Changes(context.Background(), []string{"TST"}, time.Now())
+			s.Require().NoError(err)
+			s.Require().Len(changes, 2)
+		})
+	})
+
+	s.Run("DELETE:/rates/{code}", func() {
+		resp := s.sendXRRequest(http.MethodDelete, "/rates/TST", xrTestAdminToken, nil, nil)
+		s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+		resp = s.sendXRRequest(http.MethodDelete, "/rates/TST", xrTestAdminToken, nil, nil)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+
+		resp = s.sendXRRequest(http.MethodGet, "/xr?base=TST&target=RUB", "", nil, nil)
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func (s *IntegrationTestSuite) TestMetrics() {
+	wallet := model.Wallet{OwnerID: s.testOwnerID, Currency: currencyEUR, Name: "metrics wallet"}
+	s.checkWalletPost(&wallet)
+
+	resp := s.sendRequest(
+		context.Background(),
+		http.MethodPut,
+		depositEndpoint,
+		model.Transaction{ID: uuid.New(), TargetWalletID: &wallet.ID, Currency: currencyEUR, Sum: 100},
+		nil)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	resp = s.sendRequest(
+		context.Background(),
+		http.MethodPut,
+		withdrawEndpoint,
+		model.Transaction{ID: uuid.New(), TargetWalletID: &wallet.ID, Currency: currencyEUR, Sum: 3000},
+		nil)
+	s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodGet,
+		strings.TrimSuffix(bindAddr, "/api/v1")+"/metrics",
+		nil)
+	s.Require().NoError(err)
+
+	resp, err = http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() { s.Require().NoError(resp.Body.Close()) }()
+
+	body, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	for _, series := range []string{
+		`wallets_created_total{currency="EUR"}`,
+		`wallet_operations_total{currency="EUR",operation="deposit",result="ok"}`,
+		`wallet_operation_amount_total{currency="EUR",operation="deposit"}`,
+		`wallet_operation_failures_total{operation="withdrawal",reason="not_enough_balance"}`,
+		`db_transaction_duration_seconds_count{method="ExternalTransaction"}`,
+		"db_pool_total_conns",
+		`http_requests_total{method="PUT",route="/api/v1/wallets/deposit",status="200"}`,
+		`http_requests_total{method="PUT",route="/api/v1/wallets/withdraw",status="422"}`,
+		`http_response_size_bytes_count{method="POST",route="/api/v1/wallets"}`,
+		"http_requests_in_flight",
+	} {
+		s.Require().Contains(string(body), series)
+	}
+}
+
+func (s *IntegrationTestSuite) TestOpenAPI() {
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodGet,
+		strings.TrimSuffix(bindAddr, "/v1")+"/openapi.json",
+		nil)
+	s.Require().NoError(err)
+
+	// the document is public
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() { s.Require().NoError(resp.Body.Close()) }()
+
+	body, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal("application/json", resp.Header.Get("Content-Type"))
+	s.Require().JSONEq(string(apiserver.OpenAPISpec()), string(body))
+}
+
+func (s *IntegrationTestSuite) TestHealth() {
+	get := func(endpoint string, dest any) *http.Response {
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			strings.TrimSuffix(bindAddr, "/api/v1")+endpoint,
+			nil)
+		s.Require().NoError(err)
+
+		// probes are public
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+
+		defer func() { s.Require().NoError(resp.Body.Close()) }()
+
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(dest))
+
+		return resp
+	}
+
+	s.Run("GET:/healthz", func() {
+		var health model.Readiness
+
+		resp := get("/healthz", &apiserver.HTTPResponse{Data: &health})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(model.HealthOK, health.Status)
+	})
+
+	s.Run("GET:/readyz", func() {
+		var readiness model.Readiness
+
+		resp := get("/readyz", &apiserver.HTTPResponse{Data: &readiness})
+
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal(model.HealthOK, readiness.Status)
+		s.Require().Equal(map[string]model.DependencyHealth{
+			"postgres":    {Status: model.HealthOK},
+			"migrations":  {Status: model.HealthOK},
+			"rate_server": {Status: model.HealthOK},
+		}, readiness.Dependencies)
+	})
+
+	s.Run("draining", func() {
+		const drainAddr = ":8081"
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := apiserver.New(
+			apiserver.Config{BindAddress: drainAddr, DrainDelay: 2 * time.Second},
+			s.srv,
+			s.tokenGenerator.GetPublicKey(),
+			noopMetrics{})
+
+		stopped := make(chan error, 1)
+
+		go func() { stopped <- server.Run(ctx) }()
+
+		readyz := func() (int, error) {
+			resp, err := http.Get("http://localhost" + drainAddr + "/readyz")
+			if err != nil {
+				return 0, err
+			}
+
+			defer func() { s.Require().NoError(resp.Body.Close()) }()
+
+			return resp.StatusCode, nil
+		}
+
+		s.Require().Eventually(func() bool {
+			status, err := readyz()
+
+			return err == nil && status == http.StatusOK
+		}, 5*time.Second, 10*time.Millisecond)
+
+		cancel()
+
+		// requests are still served, the probe tells to stop sending them
+		s.Require().Eventually(func() bool {
+			status, err := readyz()
+
+			return err == nil && status == http.StatusServiceUnavailable
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		select {
+		case err := <-stopped:
+			s.Require().Failf("stopped before the drain delay", "err: %v", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		select {
+		case err := <-stopped:
+			s.Require().NoError(err)
+		case <-time.After(3 * time.Second):
+			s.Require().Fail("not stopped after the drain delay")
+		}
+
+		_, err := readyz()
+		s.Require().Error(err)
+	})
+
+	s.Run("migrations", func() {
+		ctx := context.Background()
+		conn := s.connectDB(ctx)
+
+		s.Run("ahead after a newer deployment", func() {
+			_, err := conn.Exec(ctx,
+				"INSERT INTO gorp_migrations (id, applied_at) VALUES ('99990101000000_future.sql', now())")
+			s.Require().NoError(err)
+
+			defer func() {
+				_, err := conn.Exec(ctx, "DELETE FROM gorp_migrations WHERE id = '99990101000000_future.sql'")
+				s.Require().NoError(err)
+			}()
+
+			s.Require().NoError(s.str.CheckMigrations(ctx))
+		})
+
+		s.Run("behind", func() {
+			var (
+				latest    string
+				appliedAt time.Time
+			)
+
+			err := conn.QueryRow(ctx,
+				"DELETE FROM gorp_migrations WHERE id = (SELECT MAX(id) FROM gorp_migrations) RETURNING id, applied_at").
+				Scan(&latest, &appliedAt)
+			s.Require().NoError(err)
+
+			defer func() {
+				_, err := conn.Exec(ctx,
+					"INSERT INTO gorp_migrations (id, applied_at) VALUES ($1, $2)", latest, appliedAt)
+				s.Require().NoError(err)
+			}()
+
+			s.Require().Error(s.str.CheckMigrations(ctx))
+		})
+	})
+}
+
+func (s *IntegrationTestSuite) checkWalletPost(wallet *model.Wallet) {
+	var respWalletData model.Wallet
+
+	resp := s.sendRequest(
+		context.Background(),
+		http.MethodPost,
+		walletEndpoint,
+		model.NewWallet{Currency: wallet.Currency, Name: wallet.Name},
+		&apiserver.HTTPResponse{Data: &respWalletData})
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	s.Require().Equal(wallet.Currency, respWalletData.Currency)
+	s.Require().Equal(wallet.OwnerID, respWalletData.OwnerID)
+	s.Require().Equal(wallet.Balance, respWalletData.Balance)
+	s.Require().Equal(wallet.Name, respWalletData.Name)
+	s.Require().NotZero(respWalletData.ID)
+	wallet.ID = respWalletData.ID
+}
+
+func (s *IntegrationTestSuite) getWalletByID(id uuid.UUID) *model.Wallet {
+	var wallet model.Wallet
+
+	resp := s.sendRequest(
+		context.Background(),
+		http.MethodGet,
+		walletEndpoint+"/"+id.String(),
+		nil,
+		&apiserver.HTTPResponse{Data: &wallet})
+
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal(id, wallet.ID)
+
+	return &wallet
+}
+
+func (s *IntegrationTestSuite) sendRequest(ctx context.Context, method, endpoint string, body interface{}, dest interface{}) *http.Response {
+	s.T().Helper()
+
+	reqBody, err := json.Marshal(body)
+	s.Require().NoError(err)
+
+	req, err := http.NewRequestWithContext(ctx, method, bindAddr+endpoint, bytes.NewReader(reqBody))
+	s.Require().NoError(err)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	req.Header.Set("Authorization", "Bearer "+s.authToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() {
+		err = resp.Body.Close()
+		s.Require().NoError(err)
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+
+	s.checkConformance(req, resp, respBody)
+
+	if dest != nil {
+		err = json.Unmarshal(respBody, &dest)
+		s.Require().NoError(err)
+	}
+
+	return resp
+}
+
+// checkConformance asserts that the response matches the OpenAPI document.
+func (s *IntegrationTestSuite) checkConformance(req *http.Request, resp *http.Response, body []byte) {
+	s.T().Helper()
+
+	// the document's server is relative, match the path only
+	specReq := req.Clone(req.Context())
+	specReq.URL.Scheme, specReq.URL.Host = "", ""
+
+	route, pathParams, err := s.openAPIRouter.FindRoute(specReq)
+	if err != nil {
+		s.Require().Contains(
+			[]int{http.StatusNotFound, http.StatusMethodNotAllowed},
+			resp.StatusCode,
+			"%s %s is not documented", req.Method, specReq.URL.Path)
+
+		return
+	}
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    specReq,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Body:    io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	s.Require().NoError(err, "%s %s response does not conform", req.Method, specReq.URL.Path)
+}
+
+func (s *IntegrationTestSuite) sendXRRequest(method, endpoint, token string, body interface{}, dest interface{}) *http.Response {
+	s.T().Helper()
+
+	var reqBody io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		s.Require().NoError(err)
+
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		method,
+		"http://localhost"+xrTestBindAddr+endpoint,
+		reqBody)
+	s.Require().NoError(err)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() { s.Require().NoError(resp.Body.Close()) }()
+
+	if dest != nil {
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(dest))
 	}
 
 	return resp